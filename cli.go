@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/cli"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+)
+
+// toolFlags are the flags shared by the commands talking to a single target
+type toolFlags struct {
+	fs         *flag.FlagSet
+	configFile *string
+	configDir  *string
+	// metrics is only set for subscribe
+	metrics *bool
+}
+
+func newToolFlags(name string, args string) *toolFlags {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	f := &toolFlags{
+		fs:         fs,
+		configFile: fs.String("config.file", "config.yml", "Path to config file"),
+		configDir:  fs.String("config.dir", "", "Path to a directory of config files merged in lexical order, replaces -config.file"),
+	}
+
+	fs.Usage = func() {
+		fmt.Printf("Usage: openconfig-streaming-telemetry-exporter %s [ ... ] %s\n\nParameters:\n", name, args)
+		fmt.Println()
+		fs.PrintDefaults()
+	}
+
+	return f
+}
+
+// parse parses args allowing flags after positional arguments. It exits if the number of positional arguments is not n.
+func (f *toolFlags) parse(args []string, n int) []string {
+	var positional []string
+	for {
+		f.fs.Parse(args)
+		if f.fs.NArg() == 0 {
+			break
+		}
+
+		positional = append(positional, f.fs.Arg(0))
+		args = f.fs.Args()[1:]
+	}
+
+	if len(positional) != n {
+		f.fs.Usage()
+		os.Exit(2)
+	}
+
+	return positional
+}
+
+// client connects to the configured target hostname
+func (f *toolFlags) client(hostname string) (*cli.Client, *config.Target) {
+	cfg, err := loadConfig(*f.configFile, *f.configDir)
+	if err != nil {
+		log.Fatalf("could not load config file. %v", err)
+	}
+
+	var target *config.Target
+	for _, t := range cfg.Targets {
+		if t.Hostname == hostname {
+			target = t
+		}
+	}
+
+	if target == nil {
+		log.Fatalf("target %s not found in config", hostname)
+	}
+
+	conn, err := dialTarget(target)
+	if err != nil {
+		log.Fatalf("Unable to dial: %v", err)
+	}
+
+	c := cli.New(conn, os.Stdout)
+	if f.metrics != nil && *f.metrics {
+		// The stream is only inspected, never recorded
+		tconf := *target
+		tconf.Record = nil
+
+		col := newCollector(cfg)
+		c.EnableMetrics(col, col.AddTarget(&tconf, cfg.StringValueMapping, false))
+	}
+
+	return c, target
+}
+
+// runUnary runs an RPC with the timeout of target
+func runUnary(target *config.Target, rpc func(ctx context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(target.TimeoutS)*time.Second)
+	defer cancel()
+
+	err := rpc(ctx)
+	if err != nil {
+		log.Fatalf("%s: %v", target.Hostname, err)
+	}
+}
+
+// runSubscribe prints the stream of a path of a target
+func runSubscribe(args []string) {
+	f := newToolFlags("subscribe", "target path")
+	count := f.fs.Int("count", 0, "Number of messages to receive, 0 receives until interrupted")
+	f.metrics = f.fs.Bool("metrics", false, "Print the Prometheus exposition the collector produces once the stream ends instead of the stream")
+	a := f.parse(args, 2)
+
+	c, target := f.client(a[0])
+
+	// Use the settings of the path if it is configured for the target
+	path := config.NewPath(a[1])
+	for _, p := range target.Paths {
+		if p.Path == a[1] {
+			path = p
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := c.Subscribe(ctx, path, *count)
+	if err != nil {
+		log.Fatalf("%s: %v", target.Hostname, err)
+	}
+}
+
+// runSubscriptions prints the subscriptions of a target
+func runSubscriptions(args []string) {
+	f := newToolFlags("subscriptions", "target")
+	a := f.parse(args, 1)
+
+	c, target := f.client(a[0])
+	runUnary(target, c.Subscriptions)
+}
+
+// runCancel cancels a subscription of a target
+func runCancel(args []string) {
+	f := newToolFlags("cancel", "target id")
+	a := f.parse(args, 2)
+
+	id, err := strconv.ParseUint(a[1], 10, 32)
+	if err != nil {
+		log.Fatalf("invalid subscription id %q: %v", a[1], err)
+	}
+
+	c, target := f.client(a[0])
+	runUnary(target, func(ctx context.Context) error {
+		return c.Cancel(ctx, uint32(id))
+	})
+}
+
+// runOperationalState prints the operational state of the telemetry agent of a target
+func runOperationalState(args []string) {
+	f := newToolFlags("opstate", "target")
+	a := f.parse(args, 1)
+
+	c, target := f.client(a[0])
+	runUnary(target, c.OperationalState)
+}
+
+// runEncodings prints the data encodings supported by a target
+func runEncodings(args []string) {
+	f := newToolFlags("encodings", "target")
+	a := f.parse(args, 1)
+
+	c, target := f.client(a[0])
+	runUnary(target, c.Encodings)
+}
@@ -3,11 +3,15 @@ module github.com/exaring/openconfig-streaming-telemetry-exporter
 go 1.16
 
 require (
+	github.com/Shopify/sarama v1.28.0
 	github.com/StackExchange/wmi v0.0.0-20210224194228-fe8f1750fd46 // indirect
 	github.com/alecthomas/units v0.0.0-20210208195552-ff826a37aa15 // indirect
 	github.com/go-ole/go-ole v1.2.5 // indirect
 	github.com/golang/protobuf v1.5.1
+	github.com/golang/snappy v0.0.4
+	github.com/openconfig/goyang v1.0.0
 	github.com/prometheus/client_golang v1.10.0
+	github.com/prometheus/client_model v0.2.0
 	github.com/prometheus/common v0.19.0
 	github.com/q3k/statusz v0.0.0-20180806125932-924f04ea7114
 	github.com/shirou/gopsutil v3.21.2+incompatible // indirect
@@ -18,6 +22,7 @@ require (
 	golang.org/x/text v0.3.5 // indirect
 	google.golang.org/genproto v0.0.0-20210317182105-75c7a8546eb9 // indirect
 	google.golang.org/grpc v1.36.0
+	google.golang.org/protobuf v1.26.0
 	gopkg.in/yaml.v2 v2.4.0
-	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
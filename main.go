@@ -1,15 +1,14 @@
 package main
 
 import (
-	"bytes"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"time"
 
 	_ "net/http/pprof"
 
+	"github.com/prometheus/client_golang/prometheus"
 	_ "github.com/q3k/statusz"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
@@ -18,24 +17,56 @@ import (
 	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/collector"
 	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
 	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/frontend"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/influxdb"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/kafka"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/otlp"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/remotewrite"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/schema"
 )
 
 const version string = "0.0.0"
 
+// commands are the subcommands by name. The exporter runs if none is given.
+var commands = map[string]func(args []string){
+	"replay":        runReplay,
+	"simulate":      runSimulate,
+	"subscribe":     runSubscribe,
+	"subscriptions": runSubscriptions,
+	"cancel":        runCancel,
+	"opstate":       runOperationalState,
+	"encodings":     runEncodings,
+}
+
 var (
 	showVersion = flag.Bool("version", false, "Print version information.")
 	configFile  = flag.String("config.file", "config.yml", "Path to config file")
+	configDir   = flag.String("config.dir", "", "Path to a directory of config files merged in lexical order, replaces -config.file")
+	checkConfig = flag.Bool("config.check", false, "Validate the config file and exit")
 )
 
 func init() {
 	flag.Usage = func() {
-		fmt.Println("Usage: openconfig-streaming-telemetry-exporter [ ... ]\n\nParameters:")
+		fmt.Println("Usage: openconfig-streaming-telemetry-exporter [ ... ]")
+		fmt.Println("       openconfig-streaming-telemetry-exporter replay [ ... ] recording...")
+		fmt.Println("       openconfig-streaming-telemetry-exporter simulate [ ... ]")
+		fmt.Println("       openconfig-streaming-telemetry-exporter subscribe [ ... ] target path")
+		fmt.Println("       openconfig-streaming-telemetry-exporter subscriptions [ ... ] target")
+		fmt.Println("       openconfig-streaming-telemetry-exporter cancel [ ... ] target id")
+		fmt.Println("       openconfig-streaming-telemetry-exporter opstate [ ... ] target")
+		fmt.Println("       openconfig-streaming-telemetry-exporter encodings [ ... ] target\n\nParameters:")
 		fmt.Println()
 		flag.PrintDefaults()
 	}
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			cmd(os.Args[2:])
+			return
+		}
+	}
+
 	flag.Parse()
 
 	if *showVersion {
@@ -43,22 +74,44 @@ func main() {
 		os.Exit(0)
 	}
 
-	cfg, err := loadConfig()
+	cfg, err := loadConfig(*configFile, *configDir)
 	if err != nil {
 		log.Fatalf("could not load config file. %v", err)
 	}
 
-	col := collector.New(cfg)
+	if *checkConfig {
+		fmt.Println("config is valid")
+		os.Exit(0)
+	}
+
+	col := newCollector(cfg)
+	fe := frontend.New(cfg, col)
+	if cfg.InfluxDB != nil {
+		w, err := influxdb.New(cfg.InfluxDB)
+		if err != nil {
+			log.Fatalf("could not create InfluxDB writer. %v", err)
+		}
+
+		col.AddSink(w)
+		fe.AddStats(w.Stats())
+		w.Start()
+	}
+
+	if cfg.Kafka != nil {
+		p, err := kafka.New(cfg.Kafka)
+		if err != nil {
+			log.Fatalf("could not create Kafka producer. %v", err)
+		}
+
+		col.AddSink(p)
+		fe.AddStats(p.Stats())
+		p.Start()
+	}
+
 	for _, target := range cfg.Targets {
 		go func(target *config.Target) {
 			t := col.AddTarget(target, cfg.StringValueMapping, true)
-			conn, err := grpc.Dial(fmt.Sprintf("%s:%d", target.Hostname, target.Port),
-				grpc.WithInsecure(),
-				grpc.WithKeepaliveParams(keepalive.ClientParameters{
-					Time:    time.Second * time.Duration(target.KeepaliveS),
-					Timeout: time.Second * time.Duration(target.TimeoutS),
-				}),
-			)
+			conn, err := dialTarget(target)
 			if err != nil {
 				log.Errorf("Unable to dial: %v", err)
 				return
@@ -68,20 +121,66 @@ func main() {
 		}(target)
 	}
 
-	fe := frontend.New(cfg, col)
+	if cfg.RemoteWrite != nil {
+		reg := prometheus.NewRegistry()
+		// Aggregations between scrapes are reset by the Prometheus scrape only
+		reg.MustRegister(col.Peeking())
+		reg.MustRegister(col.Stats())
+
+		rw := remotewrite.New(cfg.RemoteWrite, reg)
+		fe.AddStats(rw.Stats())
+		rw.Start()
+	}
+
+	if cfg.OTLP != nil {
+		e, err := otlp.New(cfg.OTLP, col)
+		if err != nil {
+			log.Fatalf("could not create OTLP exporter. %v", err)
+		}
+
+		fe.AddStats(e.Stats())
+		e.Start()
+	}
+
 	go fe.Start()
 
 	select {}
 }
 
-func loadConfig() (*config.Config, error) {
-	log.Infoln("Loading config from", *configFile)
-	b, err := ioutil.ReadFile(*configFile)
-	if err != nil {
-		return nil, err
+// loadConfig loads the config from dir if set, from file otherwise
+func loadConfig(file string, dir string) (*config.Config, error) {
+	if dir != "" {
+		log.Infoln("Loading config from", dir)
+		return config.LoadDir(dir)
 	}
 
-	return config.Load(bytes.NewReader(b))
+	log.Infoln("Loading config from", file)
+	return config.LoadFile(file)
+}
+
+// newCollector creates a collector using the YANG schema if configured
+func newCollector(cfg *config.Config) *collector.Collector {
+	col := collector.New(cfg)
+	if cfg.YANGDir != "" {
+		s, err := schema.Load(cfg.YANGDir)
+		if err != nil {
+			log.Fatalf("could not load YANG modules. %v", err)
+		}
+
+		col.SetSchema(s)
+	}
+
+	return col
+}
+
+func dialTarget(target *config.Target) (*grpc.ClientConn, error) {
+	return grpc.Dial(fmt.Sprintf("%s:%d", target.Hostname, target.Port),
+		grpc.WithInsecure(),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:    time.Second * time.Duration(target.KeepaliveS),
+			Timeout: time.Second * time.Duration(target.TimeoutS),
+		}),
+	)
 }
 
 func printVersion() {
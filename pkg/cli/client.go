@@ -0,0 +1,232 @@
+// Package cli implements the operator commands running telemetry RPCs against a target
+package cli
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/collector"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	pb "github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/telemetry"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// allSubscriptions is the subscription id requesting all subscriptions
+const allSubscriptions = 0xFFFFFFFF
+
+// Client runs telemetry RPCs and prints the replies
+type Client struct {
+	client   pb.OpenConfigTelemetryClient
+	out      io.Writer
+	target   *collector.Target
+	gatherer prometheus.Gatherer
+}
+
+// New creates a client printing the decoded replies to out
+func New(conn *grpc.ClientConn, out io.Writer) *Client {
+	return &Client{
+		client: pb.NewOpenConfigTelemetryClient(conn),
+		out:    out,
+	}
+}
+
+// EnableMetrics makes Subscribe process the stream by target of col and print the resulting Prometheus exposition instead
+func (c *Client) EnableMetrics(col *collector.Collector, target *collector.Target) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(col)
+
+	c.target = target
+	c.gatherer = reg
+}
+
+// Subscribe prints the stream of path until ctx is done or count messages have been received. A count of 0 means no limit.
+// In metrics mode the exposition is printed once the stream ends.
+func (c *Client) Subscribe(ctx context.Context, path *config.Path, count int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.client.TelemetrySubscribe(ctx, collector.SubscriptionRequest([]*config.Path{path}))
+	if err != nil {
+		return err
+	}
+
+	for i := 0; count == 0 || i < count; i++ {
+		data, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			if status.Code(err) == codes.Canceled && ctx.Err() != nil {
+				break
+			}
+
+			return err
+		}
+
+		if c.target != nil {
+			c.target.Process(data)
+			continue
+		}
+
+		c.printData(data)
+	}
+
+	return c.printMetrics()
+}
+
+// Subscriptions prints the subscriptions of the target
+func (c *Client) Subscriptions(ctx context.Context) error {
+	reply, err := c.client.GetTelemetrySubscriptions(ctx, &pb.GetSubscriptionsRequest{
+		SubscriptionId: allSubscriptions,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, s := range reply.SubscriptionList {
+		fmt.Fprintf(c.out, "subscription %d\n", s.GetResponse().GetSubscriptionId())
+		for _, p := range s.PathList {
+			fmt.Fprintf(c.out, "  %s filter=%q sample_frequency_ms=%d max_silent_interval_ms=%d suppress_unchanged=%t\n",
+				p.Path, p.Filter, p.SampleFrequency, p.MaxSilentInterval, p.SuppressUnchanged)
+		}
+	}
+
+	return nil
+}
+
+// Cancel cancels the subscription id and prints the result
+func (c *Client) Cancel(ctx context.Context, id uint32) error {
+	reply, err := c.client.CancelTelemetrySubscription(ctx, &pb.CancelSubscriptionRequest{
+		SubscriptionId: id,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "subscription %d: %s", id, reply.Code)
+	if reply.CodeStr != "" {
+		fmt.Fprintf(c.out, " (%s)", reply.CodeStr)
+	}
+
+	fmt.Fprintln(c.out)
+	return nil
+}
+
+// OperationalState prints the operational state of the telemetry agent and all subscriptions
+func (c *Client) OperationalState(ctx context.Context) error {
+	reply, err := c.client.GetTelemetryOperationalState(ctx, &pb.GetOperationalStateRequest{
+		SubscriptionId: allSubscriptions,
+		Verbosity:      pb.VerbosityLevel_DETAIL,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.printKeyValues(reply.Kv)
+	return nil
+}
+
+// Encodings prints the data encodings supported by the target
+func (c *Client) Encodings(ctx context.Context) error {
+	reply, err := c.client.GetDataEncodings(ctx, &pb.DataEncodingRequest{})
+	if err != nil {
+		return err
+	}
+
+	for _, e := range reply.EncodingList {
+		fmt.Fprintln(c.out, e)
+	}
+
+	return nil
+}
+
+// printMetrics prints the exposition of the collector in metrics mode
+func (c *Client) printMetrics() error {
+	if c.gatherer == nil {
+		return nil
+	}
+
+	mfs, err := c.gatherer.Gather()
+	if err != nil {
+		return err
+	}
+
+	for _, mf := range mfs {
+		_, err = expfmt.MetricFamilyToText(c.out, mf)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) printData(data *pb.OpenConfigData) {
+	fmt.Fprintf(c.out, "system_id=%s component_id=%d sub_component_id=%d path=%s sequence=%d timestamp=%s\n",
+		data.SystemId, data.ComponentId, data.SubComponentId, data.Path, data.SequenceNumber,
+		time.Unix(0, int64(data.Timestamp)*int64(time.Millisecond)).UTC().Format(time.RFC3339Nano))
+
+	c.printKeyValues(data.Kv)
+
+	for _, d := range data.Delete {
+		fmt.Fprintf(c.out, "  delete %s\n", d.Path)
+	}
+
+	for _, e := range data.Eom {
+		fmt.Fprintf(c.out, "  eom %s\n", e.Path)
+	}
+
+	if data.SyncResponse {
+		fmt.Fprintln(c.out, "  sync_response")
+	}
+}
+
+// printKeyValues prints kv with the current prefix applied to the keys
+func (c *Client) printKeyValues(kv []*pb.KeyValue) {
+	prefix := ""
+	for _, x := range kv {
+		if x.Key == "__prefix__" {
+			prefix = x.GetStrValue()
+			continue
+		}
+
+		key := x.Key
+		if !strings.HasPrefix(key, "__") {
+			key = prefix + key
+		}
+
+		v, typ := formatValue(x)
+		fmt.Fprintf(c.out, "  %s = %s (%s)\n", key, v, typ)
+	}
+}
+
+// formatValue returns the value of kv and its type
+func formatValue(kv *pb.KeyValue) (string, string) {
+	switch x := kv.Value.(type) {
+	case *pb.KeyValue_DoubleValue:
+		return fmt.Sprint(x.DoubleValue), "double"
+	case *pb.KeyValue_IntValue:
+		return fmt.Sprint(x.IntValue), "int"
+	case *pb.KeyValue_UintValue:
+		return fmt.Sprint(x.UintValue), "uint"
+	case *pb.KeyValue_SintValue:
+		return fmt.Sprint(x.SintValue), "sint"
+	case *pb.KeyValue_BoolValue:
+		return fmt.Sprint(x.BoolValue), "bool"
+	case *pb.KeyValue_StrValue:
+		return fmt.Sprintf("%q", x.StrValue), "str"
+	case *pb.KeyValue_BytesValue:
+		return hex.EncodeToString(x.BytesValue), "bytes"
+	}
+
+	return "", "none"
+}
@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/collector"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/simulator"
+	pb "github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/telemetry"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func startSimulator(t *testing.T) *grpc.ClientConn {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := grpc.NewServer()
+	pb.RegisterOpenConfigTelemetryServer(s, simulator.NewServer("r1", simulator.Options{Interfaces: 2, Neighbors: 1, SampleFrequencyMS: 10}))
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func newMetricsClient(conn *grpc.ClientConn, out *bytes.Buffer) *Client {
+	cfg := config.New()
+	cfg.LoadDefaults()
+	col := collector.New(cfg)
+
+	c := New(conn, out)
+	c.EnableMetrics(col, col.AddTarget(&config.Target{Hostname: "r1"}, nil, false))
+	return c
+}
+
+func TestClient(t *testing.T) {
+	tests := []struct {
+		name     string
+		run      func(c *Client) error
+		expected string
+	}{
+		{
+			name: "Cancel unknown subscription",
+			run: func(c *Client) error {
+				return c.Cancel(context.Background(), 7)
+			},
+			expected: "subscription 7: NO_SUBSCRIPTION_ENTRY (subscription 7 not found)\n",
+		},
+		{
+			name: "Operational state",
+			run: func(c *Client) error {
+				return c.OperationalState(context.Background())
+			},
+			expected: "  interfaces = 2 (uint)\n  neighbors = 1 (uint)\n  subscriptions = 0 (uint)\n",
+		},
+		{
+			name: "Encodings",
+			run: func(c *Client) error {
+				return c.Encodings(context.Background())
+			},
+			expected: "PROTO3\n",
+		},
+	}
+
+	conn := startSimulator(t)
+	for _, test := range tests {
+		out := &bytes.Buffer{}
+		err := test.run(New(conn, out))
+		if err != nil {
+			t.Fatalf("%s: unexpected failure: %v", test.name, err)
+		}
+
+		assert.Equal(t, test.expected, out.String(), test.name)
+	}
+}
+
+func TestClientSubscribe(t *testing.T) {
+	conn := startSimulator(t)
+
+	out := &bytes.Buffer{}
+	err := New(conn, out).Subscribe(context.Background(), config.NewPath("/interfaces/"), 2)
+	if err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+
+	assert.Contains(t, out.String(), "system_id=r1 component_id=0 sub_component_id=0 path=/interfaces/ sequence=")
+	assert.Contains(t, out.String(), "  /interfaces/interface[name='et-0/0/1']/state/admin-status = \"UP\" (str)\n")
+
+	out.Reset()
+	err = newMetricsClient(conn, out).Subscribe(context.Background(), config.NewPath("/interfaces/"), 2)
+	if err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+
+	assert.Contains(t, out.String(), "# TYPE interfaces_interface_state_mtu gauge\n")
+	assert.Contains(t, out.String(), "interfaces_interface_state_mtu{customer=\"cust1\",interface_name=\"et-0/0/1\",role=\"access\"} 9192\n")
+}
+
+func TestClientSubscriptions(t *testing.T) {
+	conn := startSimulator(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := pb.NewOpenConfigTelemetryClient(conn).TelemetrySubscribe(ctx, &pb.SubscriptionRequest{
+		PathList: []*pb.Path{{Path: "/interfaces/", SampleFrequency: 1000}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The subscription is registered once data is received
+	_, err = stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &bytes.Buffer{}
+	err = New(conn, out).Subscriptions(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+
+	assert.Equal(t, "subscription 1\n  /interfaces/ filter=\"\" sample_frequency_ms=1000 max_silent_interval_ms=0 suppress_unchanged=false\n", out.String())
+}
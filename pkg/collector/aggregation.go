@@ -0,0 +1,142 @@
+package collector
+
+import (
+	"math"
+	"time"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/pathmatch"
+	log "github.com/sirupsen/logrus"
+)
+
+// Suffixes of the aggregated series
+var aggregateSuffixes = []string{"_min", "_max", "_avg"}
+
+type aggregationRule struct {
+	matcher *pathmatch.Matcher
+	window  time.Duration
+}
+
+// aggregationRules select the leafs whose values are aggregated between scrapes. The first matching rule is used.
+type aggregationRules []*aggregationRule
+
+func newAggregationRules(cfgs []*config.Aggregation) aggregationRules {
+	res := make(aggregationRules, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		matcher, err := pathmatch.Compile(cfg.Path)
+		if err != nil {
+			log.Errorf("Ignoring aggregation: %v", err)
+			continue
+		}
+
+		res = append(res, &aggregationRule{
+			matcher: matcher,
+			window:  time.Duration(cfg.WindowS) * time.Second,
+		})
+	}
+
+	return res
+}
+
+// lookup returns the window of the aggregation of path. ok is false if path is not aggregated.
+func (r aggregationRules) lookup(path string) (window time.Duration, ok bool) {
+	for _, rule := range r {
+		if rule.matcher.Match(path) {
+			return rule.window, true
+		}
+	}
+
+	return 0, false
+}
+
+type aggregateSample struct {
+	ts    time.Time
+	value float64
+}
+
+// aggregate keeps min, max and average of the values of a series. Without window the values since the last
+// result are aggregated, otherwise the values received within the window.
+type aggregate struct {
+	window  time.Duration
+	samples []aggregateSample
+	min     float64
+	max     float64
+	sum     float64
+	count   int
+	last    float64
+}
+
+func newAggregate(window time.Duration) *aggregate {
+	return &aggregate{
+		window: window,
+	}
+}
+
+func (a *aggregate) observe(v float64, now time.Time) {
+	a.last = v
+	if a.window > 0 {
+		a.expire(now)
+		a.samples = append(a.samples, aggregateSample{
+			ts:    now,
+			value: v,
+		})
+
+		return
+	}
+
+	if a.count == 0 {
+		a.min = v
+		a.max = v
+	} else {
+		a.min = math.Min(a.min, v)
+		a.max = math.Max(a.max, v)
+	}
+
+	a.sum += v
+	a.count++
+}
+
+// expire removes all samples older than the window
+func (a *aggregate) expire(now time.Time) {
+	i := 0
+	for i < len(a.samples) && now.Sub(a.samples[i].ts) > a.window {
+		i++
+	}
+
+	a.samples = a.samples[i:]
+}
+
+// result returns min, max and average. If there are no values to aggregate the last value is returned for all three.
+// Without window the aggregation is reset unless peek is set.
+func (a *aggregate) result(now time.Time, peek bool) (min float64, max float64, avg float64) {
+	if a.window > 0 {
+		a.expire(now)
+		if len(a.samples) == 0 {
+			return a.last, a.last, a.last
+		}
+
+		min, max = a.samples[0].value, a.samples[0].value
+		sum := 0.0
+		for _, s := range a.samples {
+			min = math.Min(min, s.value)
+			max = math.Max(max, s.value)
+			sum += s.value
+		}
+
+		return min, max, sum / float64(len(a.samples))
+	}
+
+	if a.count == 0 {
+		return a.last, a.last, a.last
+	}
+
+	min, max, avg = a.min, a.max, a.sum/float64(a.count)
+	if peek {
+		return min, max, avg
+	}
+
+	a.sum = 0
+	a.count = 0
+
+	return min, max, avg
+}
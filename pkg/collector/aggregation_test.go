@@ -0,0 +1,89 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregate(t *testing.T) {
+	start := time.Unix(1000, 0)
+
+	type observation struct {
+		offset time.Duration
+		value  float64
+	}
+
+	type result struct {
+		offset time.Duration
+		peek   bool
+		min    float64
+		max    float64
+		avg    float64
+	}
+
+	tests := []struct {
+		name         string
+		window       time.Duration
+		observations []observation
+		results      []result
+	}{
+		{
+			name:   "Since last scrape",
+			window: 0,
+			observations: []observation{
+				{offset: 0, value: 4},
+				{offset: 2 * time.Second, value: 10},
+				{offset: 4 * time.Second, value: 1},
+			},
+			results: []result{
+				{offset: 5 * time.Second, min: 1, max: 10, avg: 5},
+				// No updates since the last scrape
+				{offset: 10 * time.Second, min: 1, max: 1, avg: 1},
+			},
+		},
+		{
+			name:   "Since last scrape peeked",
+			window: 0,
+			observations: []observation{
+				{offset: 0, value: 4},
+				{offset: 2 * time.Second, value: 10},
+				{offset: 4 * time.Second, value: 1},
+			},
+			results: []result{
+				{offset: 5 * time.Second, peek: true, min: 1, max: 10, avg: 5},
+				{offset: 6 * time.Second, min: 1, max: 10, avg: 5},
+				{offset: 10 * time.Second, min: 1, max: 1, avg: 1},
+			},
+		},
+		{
+			name:   "Sliding window",
+			window: 5 * time.Second,
+			observations: []observation{
+				{offset: 0, value: 4},
+				{offset: 2 * time.Second, value: 10},
+				{offset: 4 * time.Second, value: 1},
+			},
+			results: []result{
+				{offset: 5 * time.Second, min: 1, max: 10, avg: 5},
+				{offset: 5 * time.Second, min: 1, max: 10, avg: 5},
+				{offset: 8 * time.Second, min: 1, max: 1, avg: 1},
+				// Window without updates
+				{offset: 20 * time.Second, min: 1, max: 1, avg: 1},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		a := newAggregate(test.window)
+		for _, o := range test.observations {
+			a.observe(o.value, start.Add(o.offset))
+		}
+
+		for _, r := range test.results {
+			min, max, avg := a.result(start.Add(r.offset), r.peek)
+			assert.Equal(t, []float64{r.min, r.max, r.avg}, []float64{min, max, avg}, test.name)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/pathmatch"
+	log "github.com/sirupsen/logrus"
+)
+
+type bytesDecoder struct {
+	matcher *pathmatch.Matcher
+	typ     string
+}
+
+// bytesDecoders convert bytes values to numbers. The first decoder matching the path of a value is used.
+type bytesDecoders []*bytesDecoder
+
+func newBytesDecoders(cfgs []*config.BytesValueDecoder) bytesDecoders {
+	res := make(bytesDecoders, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		matcher, err := pathmatch.Compile(cfg.Path)
+		if err != nil {
+			log.Errorf("Ignoring bytes value decoder: %v", err)
+			continue
+		}
+
+		res = append(res, &bytesDecoder{
+			matcher: matcher,
+			typ:     cfg.Type,
+		})
+	}
+
+	return res
+}
+
+// decode converts b received for path to a number. ok is false if the value is to be skipped.
+func (d bytesDecoders) decode(path string, b []byte) (v float64, ok bool, err error) {
+	for _, dec := range d {
+		if !dec.matcher.Match(path) {
+			continue
+		}
+
+		switch dec.typ {
+		case config.BytesValueUint:
+			u, err := decodeUint(b)
+			return float64(u), err == nil, err
+		case config.BytesValueInt:
+			u, err := decodeUint(b)
+			if err != nil {
+				return 0, false, err
+			}
+
+			shift := uint(64 - 8*len(b))
+			return float64(int64(u<<shift) >> shift), true, nil
+		case config.BytesValueFloat:
+			return decodeFloat(b)
+		default:
+			return 0, false, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+func decodeUint(b []byte) (uint64, error) {
+	if len(b) == 0 || len(b) > 8 {
+		return 0, fmt.Errorf("can not decode %d bytes as integer", len(b))
+	}
+
+	u := uint64(0)
+	for _, x := range b {
+		u = u<<8 | uint64(x)
+	}
+
+	return u, nil
+}
+
+func decodeFloat(b []byte) (float64, bool, error) {
+	switch len(b) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), true, nil
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), true, nil
+	}
+
+	return 0, false, fmt.Errorf("can not decode %d bytes as float", len(b))
+}
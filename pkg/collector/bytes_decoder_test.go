@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBytesDecodersDecode(t *testing.T) {
+	decoders := newBytesDecoders([]*config.BytesValueDecoder{
+		{
+			Path: "/uint",
+			Type: config.BytesValueUint,
+		},
+		{
+			Path: "/int",
+			Type: config.BytesValueInt,
+		},
+		{
+			Path: "/float",
+			Type: config.BytesValueFloat,
+		},
+		{
+			Path: "/**",
+			Type: config.BytesValueSkip,
+		},
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		input      []byte
+		expected   float64
+		expectedOK bool
+		wantFail   bool
+	}{
+		{
+			name:       "Unsigned",
+			path:       "/uint",
+			input:      []byte{0x01, 0x00},
+			expected:   256,
+			expectedOK: true,
+		},
+		{
+			name:       "Unsigned 64 bit",
+			path:       "/uint",
+			input:      []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			expected:   18446744073709551615,
+			expectedOK: true,
+		},
+		{
+			name:     "Unsigned too long",
+			path:     "/uint",
+			input:    make([]byte, 9),
+			wantFail: true,
+		},
+		{
+			name:       "Negative",
+			path:       "/int",
+			input:      []byte{0xff, 0xfe},
+			expected:   -2,
+			expectedOK: true,
+		},
+		{
+			name:       "Positive",
+			path:       "/int",
+			input:      []byte{0x00, 0x00, 0x00, 0x2a},
+			expected:   42,
+			expectedOK: true,
+		},
+		{
+			name:       "Float32",
+			path:       "/float",
+			input:      []byte{0xc0, 0x20, 0x00, 0x00},
+			expected:   -2.5,
+			expectedOK: true,
+		},
+		{
+			name:       "Float64",
+			path:       "/float",
+			input:      []byte{0x3f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			expected:   1.5,
+			expectedOK: true,
+		},
+		{
+			name:     "Float invalid length",
+			path:     "/float",
+			input:    []byte{0x3f, 0xf8},
+			wantFail: true,
+		},
+		{
+			name:       "Skip",
+			path:       "/foo/bar",
+			input:      []byte{0x01},
+			expectedOK: false,
+		},
+	}
+
+	for _, test := range tests {
+		v, ok, err := decoders.decode(test.path, test.input)
+		if err != nil {
+			if test.wantFail {
+				continue
+			}
+
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		if test.wantFail {
+			t.Errorf("Unexpected success for test %q", test.name)
+			continue
+		}
+
+		assert.Equal(t, test.expectedOK, ok, test.name)
+		assert.Equal(t, test.expected, v, test.name)
+	}
+}
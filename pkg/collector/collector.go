@@ -2,26 +2,41 @@ package collector
 
 import (
 	"fmt"
-	"strings"
 	"sync"
 
 	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/output"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/schema"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 // Collector is a streaming telemetry data collector
 type Collector struct {
-	cfg       *config.Config
-	targets   map[string]*Target
-	targetsMu sync.RWMutex
+	cfg           *config.Config
+	targets       map[string]*Target
+	targetsMu     sync.RWMutex
+	builtinMapper *stringValueMapper
+	schema        *schema.Schema
+	schemaMapper  *stringValueMapper
+	derived       *derivedMetrics
+	sinks         []output.Sink
+	stats         *stats
 }
 
 // New initializes a new Collector
 func New(cfg *config.Config) *Collector {
-	return &Collector{
+	c := &Collector{
 		cfg:     cfg,
 		targets: make(map[string]*Target),
+		derived: newDerivedMetrics(cfg.DerivedMetrics),
+		stats:   newStats(),
 	}
+
+	if !cfg.DisableBuiltinStringValueMapping {
+		c.builtinMapper = newStringValueMapper(config.BuiltinStringValueMapping(), nil)
+	}
+
+	return c
 }
 
 // Stop stops the collector
@@ -46,85 +61,130 @@ func (c *Collector) Dump() []string {
 	return nil
 }
 
+// SetSchema sets the YANG schema used to derive metric metadata and string value mappings.
+// It has to be called before any targets are added.
+func (c *Collector) SetSchema(s *schema.Schema) {
+	c.schema = s
+	c.schemaMapper = newStringValueMapper(s.StringValueMapping(), nil)
+
+	// The built-in mapping takes precedence as YANG enumerations without explicit values are numbered from 0
+	if c.builtinMapper != nil {
+		c.builtinMapper = newStringValueMapper(config.BuiltinStringValueMapping(), c.schemaMapper)
+	}
+}
+
+// AddSink adds a sink receiving all updates. It has to be called before any targets are added.
+func (c *Collector) AddSink(s output.Sink) {
+	c.sinks = append(c.sinks, s)
+}
+
+func (c *Collector) fallbackMapper() *stringValueMapper {
+	if c.builtinMapper != nil {
+		return c.builtinMapper
+	}
+
+	return c.schemaMapper
+}
+
 // AddTarget adds a target to the collector
-func (c *Collector) AddTarget(tconf *config.Target, stringValueMapping map[string]map[string]int, reconnect bool) *Target {
+func (c *Collector) AddTarget(tconf *config.Target, stringValueMapping config.StringValueMapping, reconnect bool) *Target {
 	c.targetsMu.Lock()
 	defer c.targetsMu.Unlock()
-	c.targets[tconf.Hostname] = newTarget(tconf, stringValueMapping, reconnect)
+	t := newTarget(tconf, stringValueMapping, c.fallbackMapper(), c.schema, reconnect)
+
+	relabelConfigs := make([]*config.RelabelConfig, 0, len(c.cfg.MetricRelabelConfigs)+len(tconf.MetricRelabelConfigs))
+	relabelConfigs = append(relabelConfigs, c.cfg.MetricRelabelConfigs...)
+	relabelConfigs = append(relabelConfigs, tconf.MetricRelabelConfigs...)
+	t.relabeler = newRelabeler(relabelConfigs)
+	t.descParsers = newDescriptionParsers(c.cfg.DescriptionParsers)
+	t.bytesDecoders = newBytesDecoders(c.cfg.BytesValueDecoders)
+	t.derived = c.derived
+	t.aggregations = newAggregationRules(c.cfg.Aggregations)
+	t.counters = newCounterRules(c.cfg.Counters)
+	t.sinks = c.sinks
+	t.stats = c.stats
+	// The tree depends on the relabeler, the aggregations and the counters
+	t.setTree(t.newTree())
+
+	c.targets[tconf.Hostname] = t
 
 	return c.targets[tconf.Hostname]
 }
 
-// Describe is required by prometheus interface
-func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
-}
-
-// Collect collects data from the collector and send it to prometheus
-func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+// SetRecording starts or stops recording the stream of target
+func (c *Collector) SetRecording(target string, enabled bool) error {
 	c.targetsMu.RLock()
 	defer c.targetsMu.RUnlock()
 
-	var wg sync.WaitGroup
-	for _, t := range c.targets {
-		wg.Add(1)
-		go t.collect(ch, &wg)
+	t, ok := c.targets[target]
+	if !ok {
+		return fmt.Errorf("unknown target %q", target)
 	}
 
-	wg.Wait()
+	if t.recorder == nil {
+		return fmt.Errorf("recording is not configured for target %q", target)
+	}
+
+	return t.recorder.SetEnabled(enabled)
 }
 
-func parseKey(input string) (string, []label, error) {
-	data := []rune(input)
+// Recordings returns whether recording is enabled for all targets with recording configured
+func (c *Collector) Recordings() map[string]bool {
+	c.targetsMu.RLock()
+	defer c.targetsMu.RUnlock()
 
-	key := make([]rune, 0, 40)
-	labelStrings := make([]string, 0, 3)
-	withinAngledBraces := false
-	tmp := make([]rune, 0, 10)
+	res := make(map[string]bool)
+	for name, t := range c.targets {
+		if t.recorder != nil {
+			res[name] = t.recorder.Enabled()
+		}
+	}
 
-	for i := 0; i < len(data); i++ {
-		if !withinAngledBraces {
-			if data[i] == '[' {
-				withinAngledBraces = true
-				continue
-			}
+	return res
+}
 
-			key = append(key, data[i])
-			continue
-		}
+// Stats returns a prometheus collector exposing the internal metrics of the collector
+func (c *Collector) Stats() prometheus.Collector {
+	return c.stats
+}
 
-		if data[i] == ']' {
-			labelStrings = append(labelStrings, string(tmp))
-			withinAngledBraces = false
-			tmp = make([]rune, 0)
-			continue
-		}
+// Describe is required by prometheus interface
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+}
 
-		tmp = append(tmp, data[i])
-	}
+// Collect collects data from the collector and send it to prometheus
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.collect(ch, false)
+}
 
-	retLabels := make([]label, len(labelStrings))
-	for i, labelStr := range labelStrings {
-		l, err := parseLabel(labelStr)
-		if err != nil {
-			return "", nil, fmt.Errorf("Unable to parse label: %v", err)
-		}
+func (c *Collector) collect(ch chan<- prometheus.Metric, peek bool) {
+	c.targetsMu.RLock()
+	defer c.targetsMu.RUnlock()
 
-		retLabels[i] = *l
+	var wg sync.WaitGroup
+	for _, t := range c.targets {
+		wg.Add(1)
+		go t.collect(ch, &wg, peek)
 	}
 
-	return string(key), retLabels, nil
+	wg.Wait()
+}
 
+// Peeking returns a prometheus collector of the same metrics which does not reset the aggregations between scrapes.
+// It is used by readers other than the Prometheus scrape, e.g. remote write.
+func (c *Collector) Peeking() prometheus.Collector {
+	return peekingCollector{c: c}
 }
 
-func parseLabel(input string) (*label, error) {
-	parts := strings.Split(input, "=")
+type peekingCollector struct {
+	c *Collector
+}
 
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("Unexpected length of split string: %q: %d", input, len(parts))
-	}
+// Describe is required by prometheus interface
+func (p peekingCollector) Describe(ch chan<- *prometheus.Desc) {
+}
 
-	return &label{
-		key:   parts[0],
-		value: strings.Replace(parts[1], "'", "", -1),
-	}, nil
+// Collect collects data from the collector without resetting aggregations
+func (p peekingCollector) Collect(ch chan<- prometheus.Metric) {
+	p.c.collect(ch, true)
 }
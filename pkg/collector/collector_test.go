@@ -12,12 +12,14 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
 	"github.com/prometheus/common/log"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/test/bufconn"
 
 	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/schema"
 	pb "github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/telemetry"
 )
 
@@ -69,10 +71,12 @@ func TestIntegration(t *testing.T) {
 		{
 			name: "Test #1",
 			config: &config.Config{
-				StringValueMapping: map[string]map[string]int{
-					"/interfaces/interface/state/oper-state": map[string]int{
-						"UP":   100,
-						"DOWN": 200,
+				StringValueMapping: config.StringValueMapping{
+					"/interfaces/interface/state/oper-state": {
+						Values: map[string]float64{
+							"UP":   100,
+							"DOWN": 200,
+						},
 					},
 				},
 				Targets: []*config.Target{
@@ -120,10 +124,12 @@ func TestIntegration(t *testing.T) {
 		{
 			name: "Test #2",
 			config: &config.Config{
-				StringValueMapping: map[string]map[string]int{
-					"/interfaces/interface/state/oper-state": map[string]int{
-						"UP":   100,
-						"DOWN": 200,
+				StringValueMapping: config.StringValueMapping{
+					"/interfaces/interface/state/oper-state": {
+						Values: map[string]float64{
+							"UP":   100,
+							"DOWN": 200,
+						},
 					},
 				},
 				Targets: []*config.Target{
@@ -171,10 +177,12 @@ func TestIntegration(t *testing.T) {
 		{
 			name: "Test #3",
 			config: &config.Config{
-				StringValueMapping: map[string]map[string]int{
-					"/interfaces/interface/state/oper-state": map[string]int{
-						"UP":   100,
-						"DOWN": 200,
+				StringValueMapping: config.StringValueMapping{
+					"/interfaces/interface/state/oper-state": {
+						Values: map[string]float64{
+							"UP":   100,
+							"DOWN": 200,
+						},
 					},
 				},
 				Targets: []*config.Target{
@@ -240,10 +248,12 @@ func TestIntegration(t *testing.T) {
 		{
 			name: "Test #4",
 			config: &config.Config{
-				StringValueMapping: map[string]map[string]int{
-					"/interfaces/interface/state/oper-state": map[string]int{
-						"UP":   100,
-						"DOWN": 200,
+				StringValueMapping: config.StringValueMapping{
+					"/interfaces/interface/state/oper-state": {
+						Values: map[string]float64{
+							"UP":   100,
+							"DOWN": 200,
+						},
 					},
 				},
 				Targets: []*config.Target{
@@ -303,10 +313,12 @@ func TestIntegration(t *testing.T) {
 		{
 			name: "Test #5",
 			config: &config.Config{
-				StringValueMapping: map[string]map[string]int{
-					"/interfaces/interface/state/oper-state": map[string]int{
-						"UP":   100,
-						"DOWN": 200,
+				StringValueMapping: config.StringValueMapping{
+					"/interfaces/interface/state/oper-state": {
+						Values: map[string]float64{
+							"UP":   100,
+							"DOWN": 200,
+						},
 					},
 				},
 				Targets: []*config.Target{
@@ -354,10 +366,12 @@ func TestIntegration(t *testing.T) {
 		{
 			name: "Test #6",
 			config: &config.Config{
-				StringValueMapping: map[string]map[string]int{
-					"/interfaces/interface/state/oper-state": map[string]int{
-						"UP":   100,
-						"DOWN": 200,
+				StringValueMapping: config.StringValueMapping{
+					"/interfaces/interface/state/oper-state": {
+						Values: map[string]float64{
+							"UP":   100,
+							"DOWN": 200,
+						},
 					},
 				},
 				Targets: []*config.Target{
@@ -456,7 +470,9 @@ func TestIntegration(t *testing.T) {
 					return lis.Dial()
 				}), grpc.WithInsecure())
 				if err != nil {
-					t.Fatalf("Failed to dial bufnet: %v", err)
+					t.Errorf("Failed to dial bufnet: %v", err)
+					serveWG.Done()
+					return
 				}
 				defer conn.Close()
 
@@ -512,70 +528,496 @@ func (m *mockHTTPResponseWriter) Header() http.Header {
 	return http.Header{}
 }
 
-func TestExtractLabels(t *testing.T) {
+func exposition(t *testing.T, c prometheus.Collector) string {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Errorf("Gather failed: %v", err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	for _, mf := range mfs {
+		expfmt.MetricFamilyToText(buf, mf)
+	}
+
+	return buf.String()
+}
+
+func TestCollectSchema(t *testing.T) {
+	s, err := schema.Load("../schema/testdata")
+	if err != nil {
+		t.Fatalf("Unable to load schema: %v", err)
+	}
+
 	tests := []struct {
-		name           string
-		input          string
-		expectedPrefix string
-		expectedLabels []label
-		wantFail       bool
+		name     string
+		cfg      *config.Config
+		expected string
 	}{
 		{
-			name:           "Test #1",
-			input:          "/interfaces/interface[name='xe-0/0/34:0']/",
-			expectedPrefix: "/interfaces/interface/",
-			expectedLabels: []label{
+			name: "Built-in mapping",
+			cfg:  &config.Config{},
+			// admin-status is numbered implicitly by the model, the built-in mapping wins
+			expected: "# HELP interfaces_interface_state_admin_status The desired state of the interface\n# TYPE interfaces_interface_state_admin_status gauge\ninterfaces_interface_state_admin_status{device=\"test\",interface_name=\"xe-0/0/0\"} 2\n# HELP interfaces_interface_state_counters_in_octets The total number of octets received on the interface.\n# TYPE interfaces_interface_state_counters_in_octets counter\ninterfaces_interface_state_counters_in_octets{device=\"test\",interface_name=\"xe-0/0/0\"} 1000\n# HELP interfaces_interface_state_counters_last_clear_seconds Time the counters were last cleared\n# TYPE interfaces_interface_state_counters_last_clear_seconds gauge\ninterfaces_interface_state_counters_last_clear_seconds{device=\"test\",interface_name=\"xe-0/0/0\"} 2.5\n# HELP interfaces_interface_state_oper_status The current operational state of the interface\n# TYPE interfaces_interface_state_oper_status gauge\ninterfaces_interface_state_oper_status{device=\"test\",interface_name=\"xe-0/0/0\"} 7\n",
+		},
+		{
+			name: "Built-in mapping disabled",
+			cfg: &config.Config{
+				DisableBuiltinStringValueMapping: true,
+			},
+			expected: "# HELP interfaces_interface_state_admin_status The desired state of the interface\n# TYPE interfaces_interface_state_admin_status gauge\ninterfaces_interface_state_admin_status{device=\"test\",interface_name=\"xe-0/0/0\"} 1\n# HELP interfaces_interface_state_counters_in_octets The total number of octets received on the interface.\n# TYPE interfaces_interface_state_counters_in_octets counter\ninterfaces_interface_state_counters_in_octets{device=\"test\",interface_name=\"xe-0/0/0\"} 1000\n# HELP interfaces_interface_state_counters_last_clear_seconds Time the counters were last cleared\n# TYPE interfaces_interface_state_counters_last_clear_seconds gauge\ninterfaces_interface_state_counters_last_clear_seconds{device=\"test\",interface_name=\"xe-0/0/0\"} 2.5\n# HELP interfaces_interface_state_oper_status The current operational state of the interface\n# TYPE interfaces_interface_state_oper_status gauge\ninterfaces_interface_state_oper_status{device=\"test\",interface_name=\"xe-0/0/0\"} 7\n",
+		},
+	}
+
+	for _, test := range tests {
+		c := New(test.cfg)
+		c.SetSchema(s)
+		ta := c.AddTarget(&config.Target{Hostname: "test"}, test.cfg.StringValueMapping, false)
+		ta.processOpenConfigData(&pb.OpenConfigData{
+			Kv: []*pb.KeyValue{
+				{
+					Key: "__prefix__",
+					Value: &pb.KeyValue_StrValue{
+						StrValue: "/interfaces/interface[name='xe-0/0/0']/",
+					},
+				},
+				{
+					Key: "state/counters/in-octets",
+					Value: &pb.KeyValue_UintValue{
+						UintValue: 1000,
+					},
+				},
 				{
-					key:   "name",
-					value: "xe-0/0/34:0",
+					Key: "state/counters/last-clear",
+					Value: &pb.KeyValue_UintValue{
+						UintValue: 2500000000,
+					},
+				},
+				{
+					Key: "state/oper-status",
+					Value: &pb.KeyValue_StrValue{
+						StrValue: "LOWER_LAYER_DOWN",
+					},
+				},
+				{
+					Key: "state/admin-status",
+					Value: &pb.KeyValue_StrValue{
+						StrValue: "DOWN",
+					},
+				},
+			},
+		})
+
+		assert.Equal(t, test.expected, exposition(t, c), test.name)
+	}
+}
+
+func TestCollectRelabel(t *testing.T) {
+	cfg := &config.Config{
+		MetricRelabelConfigs: []*config.RelabelConfig{
+			{
+				SourceLabels: []string{"__name__"},
+				Separator:    ";",
+				Regex:        "interfaces_interface_state_(.*)",
+				TargetLabel:  "__name__",
+				Replacement:  "interface_$1",
+				Action:       config.RelabelReplace,
+			},
+		},
+	}
+
+	c := New(cfg)
+	ta := c.AddTarget(&config.Target{
+		Hostname: "test",
+		MetricRelabelConfigs: []*config.RelabelConfig{
+			{
+				Separator:   ";",
+				Regex:       "interface_name",
+				Replacement: "$1",
+				Action:      config.RelabelLabelDrop,
+			},
+			{
+				SourceLabels: []string{"__name__"},
+				Separator:    ";",
+				Regex:        "interface_pkts",
+				Replacement:  "$1",
+				Action:       config.RelabelDrop,
+			},
+		},
+	}, cfg.StringValueMapping, false)
+
+	ta.processOpenConfigData(&pb.OpenConfigData{
+		Kv: []*pb.KeyValue{
+			{
+				Key: "__prefix__",
+				Value: &pb.KeyValue_StrValue{
+					StrValue: "/interfaces/interface[name='xe-0/0/0']/",
+				},
+			},
+			{
+				Key: "state/counters/in-octets",
+				Value: &pb.KeyValue_UintValue{
+					UintValue: 1000,
+				},
+			},
+			{
+				Key: "state/description",
+				Value: &pb.KeyValue_StrValue{
+					StrValue: "customer=foo",
+				},
+			},
+			{
+				Key: "state/pkts",
+				Value: &pb.KeyValue_UintValue{
+					UintValue: 10,
 				},
 			},
 		},
-		{
-			name:           "Test #2",
-			input:          "state/counters/out-queue[queue-number=0]/bytes/",
-			expectedPrefix: "state/counters/out-queue/bytes/",
-			expectedLabels: []label{
+	})
+
+	expected := "# HELP interface_counters_in_octets interfaces/interface/state/counters/in-octets\n# TYPE interface_counters_in_octets counter\ninterface_counters_in_octets{customer=\"foo\",device=\"test\"} 1000\n"
+	assert.Equal(t, expected, exposition(t, c))
+}
+
+func TestCollectBytesValues(t *testing.T) {
+	cfg := &config.Config{
+		BytesValueDecoders: []*config.BytesValueDecoder{
+			{
+				Path: "/system/state/temperature",
+				Type: config.BytesValueInt,
+			},
+			{
+				Path: "/system/state/*-power",
+				Type: config.BytesValueFloat,
+			},
+			{
+				Path: "/system/state/serial",
+				Type: config.BytesValueSkip,
+			},
+		},
+	}
+
+	c := New(cfg)
+	ta := c.AddTarget(&config.Target{Hostname: "test"}, cfg.StringValueMapping, false)
+	ta.processOpenConfigData(&pb.OpenConfigData{
+		Kv: []*pb.KeyValue{
+			{
+				Key: "/system/state/temperature",
+				Value: &pb.KeyValue_BytesValue{
+					BytesValue: []byte{0xff, 0xf6},
+				},
+			},
+			{
+				Key: "/system/state/input-power",
+				Value: &pb.KeyValue_BytesValue{
+					BytesValue: []byte{0x3f, 0xc0, 0x00, 0x00},
+				},
+			},
+			{
+				Key: "/system/state/output-power",
+				Value: &pb.KeyValue_BytesValue{
+					BytesValue: []byte{0x3f, 0xc0},
+				},
+			},
+			{
+				Key: "/system/state/serial",
+				Value: &pb.KeyValue_BytesValue{
+					BytesValue: []byte("ABC123"),
+				},
+			},
+			{
+				Key: "/system/state/firmware",
+				Value: &pb.KeyValue_BytesValue{
+					BytesValue: []byte{0x01},
+				},
+			},
+			{
+				Key: "/system/fan[name='fan0']/state/speed",
+				Value: &pb.KeyValue_UintValue{
+					UintValue: 3000,
+				},
+			},
+			{
+				Key: "/system/fan[name='fan0']/state/description",
+				Value: &pb.KeyValue_StrValue{
+					StrValue: "fan_name=duplicate",
+				},
+			},
+		},
+	})
+
+	expected := "# HELP system_state_input_power system/state/input-power\n# TYPE system_state_input_power gauge\nsystem_state_input_power{device=\"test\"} 1.5\n# HELP system_state_temperature system/state/temperature\n# TYPE system_state_temperature gauge\nsystem_state_temperature{device=\"test\"} -10\n"
+	assert.Equal(t, expected, exposition(t, c))
+
+	expected = "# HELP openconfig_exporter_collect_errors_total Number of values skipped on collect because they could not be converted to a metric\n# TYPE openconfig_exporter_collect_errors_total counter\nopenconfig_exporter_collect_errors_total{reason=\"bytes_decode\",target=\"test\"} 1\nopenconfig_exporter_collect_errors_total{reason=\"invalid_metric\",target=\"test\"} 1\n"
+	assert.Equal(t, expected, exposition(t, c.Stats()))
+}
+
+func TestCollectDerived(t *testing.T) {
+	cfg := &config.Config{
+		DerivedMetrics: []*config.DerivedMetric{
+			{
+				Name:   "interface_port_speed_bits",
+				Help:   "Port speed in bits per second",
+				Type:   config.DerivedMetricGauge,
+				Expr:   "/interfaces/interface/ethernet/state/port-speed * 1000000",
+				Labels: map[string]string{"source": "derived"},
+			},
+			{
+				Name: "interfaces_in_octets_total",
+				Help: "Received octets of all interfaces",
+				Type: config.DerivedMetricCounter,
+				Expr: "sum by (device) (/interfaces/**/in-octets)",
+			},
+			{
+				Name: "broken",
+				Type: config.DerivedMetricGauge,
+				Expr: "/interfaces/interface/state/counters/in-octets / ignoring(interface_name) /interfaces/interface/state/counters/in-octets",
+			},
+		},
+		MetricRelabelConfigs: []*config.RelabelConfig{
+			{
+				SourceLabels: []string{"__name__"},
+				Separator:    ";",
+				Regex:        "interfaces_interface_ethernet_state_port_speed",
+				Replacement:  "$1",
+				Action:       config.RelabelDrop,
+			},
+		},
+	}
+
+	c := New(cfg)
+	ta := c.AddTarget(&config.Target{Hostname: "test"}, cfg.StringValueMapping, false)
+	ta.processOpenConfigData(&pb.OpenConfigData{
+		Kv: []*pb.KeyValue{
+			{
+				Key: "/interfaces/interface[name='xe-0/0/0']/state/counters/in-octets",
+				Value: &pb.KeyValue_UintValue{
+					UintValue: 500,
+				},
+			},
+			{
+				Key: "/interfaces/interface[name='xe-0/0/0']/ethernet/state/port-speed",
+				Value: &pb.KeyValue_UintValue{
+					UintValue: 10000,
+				},
+			},
+			{
+				Key: "/interfaces/interface[name='xe-0/0/1']/state/counters/in-octets",
+				Value: &pb.KeyValue_UintValue{
+					UintValue: 1500,
+				},
+			},
+		},
+	})
+
+	expected := "# HELP interface_port_speed_bits Port speed in bits per second\n# TYPE interface_port_speed_bits gauge\ninterface_port_speed_bits{device=\"test\",interface_name=\"xe-0/0/0\",source=\"derived\"} 1e+10\n# HELP interfaces_in_octets_total Received octets of all interfaces\n# TYPE interfaces_in_octets_total counter\ninterfaces_in_octets_total{device=\"test\"} 2000\n# HELP interfaces_interface_state_counters_in_octets interfaces/interface/state/counters/in-octets\n# TYPE interfaces_interface_state_counters_in_octets counter\ninterfaces_interface_state_counters_in_octets{device=\"test\",interface_name=\"xe-0/0/0\"} 500\ninterfaces_interface_state_counters_in_octets{device=\"test\",interface_name=\"xe-0/0/1\"} 1500\n"
+	assert.Equal(t, expected, exposition(t, c))
+
+	expected = "# HELP openconfig_exporter_collect_errors_total Number of values skipped on collect because they could not be converted to a metric\n# TYPE openconfig_exporter_collect_errors_total counter\nopenconfig_exporter_collect_errors_total{reason=\"derived\",target=\"test\"} 1\n"
+	assert.Equal(t, expected, exposition(t, c.Stats()))
+}
+
+func TestCollectDerivedTargets(t *testing.T) {
+	cfg := &config.Config{
+		DerivedMetrics: []*config.DerivedMetric{
+			{
+				Name: "interfaces_in_octets_total",
+				Help: "Received octets of all interfaces",
+				Type: config.DerivedMetricCounter,
+				Expr: "sum (/interfaces/**/in-octets)",
+			},
+		},
+		MetricRelabelConfigs: []*config.RelabelConfig{
+			{
+				SourceLabels: []string{"__name__"},
+				Separator:    ";",
+				Regex:        "interfaces_.*",
+				Replacement:  "$1",
+				Action:       config.RelabelDrop,
+			},
+		},
+	}
+
+	c := New(cfg)
+	for i, hostname := range []string{"r1", "r2"} {
+		ta := c.AddTarget(&config.Target{Hostname: hostname}, cfg.StringValueMapping, false)
+		ta.processOpenConfigData(&pb.OpenConfigData{
+			Kv: []*pb.KeyValue{
 				{
-					key:   "queue-number",
-					value: "0",
+					Key: "/interfaces/interface[name='xe-0/0/0']/state/counters/in-octets",
+					Value: &pb.KeyValue_UintValue{
+						UintValue: uint64(100 * (i + 1)),
+					},
 				},
 			},
+		})
+	}
+
+	// The sum drops the device label, it is added back so the targets do not collide
+	expected := "# HELP interfaces_in_octets_total Received octets of all interfaces\n# TYPE interfaces_in_octets_total counter\ninterfaces_in_octets_total{device=\"r1\"} 100\ninterfaces_in_octets_total{device=\"r2\"} 200\n"
+	assert.Equal(t, expected, exposition(t, c))
+}
+
+func TestCollectAggregation(t *testing.T) {
+	cfg := &config.Config{
+		Aggregations: []*config.Aggregation{
+			{
+				Path: "/system/state/cpu-*",
+			},
 		},
-		{
-			name:           "Test #3",
-			input:          "/network-instances/network-instance[instance-name='master']/protocols/protocol/bgp/neighbors/neighbor[neighbor-address='1.2.3.4']/",
-			expectedPrefix: "/network-instances/network-instance/protocols/protocol/bgp/neighbors/neighbor/",
-			expectedLabels: []label{
+	}
+
+	c := New(cfg)
+	ta := c.AddTarget(&config.Target{Hostname: "test"}, cfg.StringValueMapping, false)
+	for _, v := range []uint64{10, 70, 40} {
+		ta.processOpenConfigData(&pb.OpenConfigData{
+			Kv: []*pb.KeyValue{
 				{
-					key:   "instance-name",
-					value: "master",
+					Key: "/system/state/cpu-utilization",
+					Value: &pb.KeyValue_UintValue{
+						UintValue: v,
+					},
 				},
 				{
-					key:   "neighbor-address",
-					value: "1.2.3.4",
+					Key: "/system/state/memory-utilization",
+					Value: &pb.KeyValue_UintValue{
+						UintValue: v,
+					},
 				},
 			},
+		})
+	}
+
+	expected := "# HELP system_state_cpu_utilization system/state/cpu-utilization\n# TYPE system_state_cpu_utilization gauge\nsystem_state_cpu_utilization{device=\"test\"} 40\n# HELP system_state_cpu_utilization_avg system/state/cpu-utilization\n# TYPE system_state_cpu_utilization_avg gauge\nsystem_state_cpu_utilization_avg{device=\"test\"} 40\n# HELP system_state_cpu_utilization_max system/state/cpu-utilization\n# TYPE system_state_cpu_utilization_max gauge\nsystem_state_cpu_utilization_max{device=\"test\"} 70\n# HELP system_state_cpu_utilization_min system/state/cpu-utilization\n# TYPE system_state_cpu_utilization_min gauge\nsystem_state_cpu_utilization_min{device=\"test\"} 10\n# HELP system_state_memory_utilization system/state/memory-utilization\n# TYPE system_state_memory_utilization gauge\nsystem_state_memory_utilization{device=\"test\"} 40\n"
+	// Other readers like remote write do not reset the aggregation
+	assert.Equal(t, expected, exposition(t, c.Peeking()))
+	assert.Equal(t, expected, exposition(t, c))
+
+	// The aggregation is reset by the scrape
+	expected = "# HELP system_state_cpu_utilization system/state/cpu-utilization\n# TYPE system_state_cpu_utilization gauge\nsystem_state_cpu_utilization{device=\"test\"} 40\n# HELP system_state_cpu_utilization_avg system/state/cpu-utilization\n# TYPE system_state_cpu_utilization_avg gauge\nsystem_state_cpu_utilization_avg{device=\"test\"} 40\n# HELP system_state_cpu_utilization_max system/state/cpu-utilization\n# TYPE system_state_cpu_utilization_max gauge\nsystem_state_cpu_utilization_max{device=\"test\"} 40\n# HELP system_state_cpu_utilization_min system/state/cpu-utilization\n# TYPE system_state_cpu_utilization_min gauge\nsystem_state_cpu_utilization_min{device=\"test\"} 40\n# HELP system_state_memory_utilization system/state/memory-utilization\n# TYPE system_state_memory_utilization gauge\nsystem_state_memory_utilization{device=\"test\"} 40\n"
+	assert.Equal(t, expected, exposition(t, c))
+}
+
+func TestCollectCounters(t *testing.T) {
+	cfg := &config.Config{
+		Counters: []*config.Counter{
+			{
+				Path:       "/interfaces/interface/state/counters/*",
+				Width:      32,
+				Accumulate: true,
+			},
 		},
 	}
 
-	for _, test := range tests {
-		prefix, labels, err := parseKey(test.input)
-		if test.wantFail {
-			if err != nil {
+	c := New(cfg)
+	ta := c.AddTarget(&config.Target{Hostname: "test"}, cfg.StringValueMapping, false)
+	for _, v := range []uint64{1<<32 - 100, 50, 10} {
+		ta.processOpenConfigData(&pb.OpenConfigData{
+			Kv: []*pb.KeyValue{
+				{
+					Key: "/interfaces/interface[name='xe-0/0/0']/state/counters/in-octets",
+					Value: &pb.KeyValue_UintValue{
+						UintValue: v,
+					},
+				},
+			},
+		})
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+
+	values := make(map[string]float64)
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			if m.Counter != nil {
+				values[mf.GetName()] = m.Counter.GetValue()
 				continue
 			}
 
-			t.Errorf("Unexpected success for test %q", test.name)
-			continue
+			values[mf.GetName()] = m.Gauge.GetValue()
 		}
+	}
+
+	// Wrap from 2^32-100 to 50 (+150) followed by a reset to 10 (+10)
+	assert.Equal(t, float64(1<<32-100+150+10), values["interfaces_interface_state_counters_in_octets"])
+	assert.InDelta(t, float64(time.Now().Unix()), values["interfaces_interface_state_counters_in_octets_created"], 60)
+
+	expected := "# HELP openconfig_exporter_counter_discontinuities_total Number of detected resets and wraps of configured counters\n# TYPE openconfig_exporter_counter_discontinuities_total counter\nopenconfig_exporter_counter_discontinuities_total{kind=\"reset\",target=\"test\"} 1\nopenconfig_exporter_counter_discontinuities_total{kind=\"wrap\",target=\"test\"} 1\n"
+	assert.Equal(t, expected, exposition(t, c.Stats()))
+}
 
+func TestCollectCountersReconnect(t *testing.T) {
+	cfg := &config.Config{
+		Counters: []*config.Counter{
+			{
+				Path:       "/interfaces/interface/state/counters/*",
+				Width:      32,
+				Accumulate: true,
+			},
+		},
+	}
+
+	c := New(cfg)
+	ta := c.AddTarget(&config.Target{Hostname: "test"}, cfg.StringValueMapping, false)
+	update := func(v uint64) {
+		ta.processOpenConfigData(&pb.OpenConfigData{
+			Kv: []*pb.KeyValue{
+				{
+					Key: "/interfaces/interface[name='xe-0/0/0']/state/counters/in-octets",
+					Value: &pb.KeyValue_UintValue{
+						UintValue: v,
+					},
+				},
+			},
+		})
+	}
+
+	gather := func() map[string]float64 {
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(c)
+		mfs, err := reg.Gather()
 		if err != nil {
-			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
-			continue
+			t.Fatalf("Gather failed: %v", err)
+		}
+
+		values := make(map[string]float64)
+		for _, mf := range mfs {
+			for _, m := range mf.Metric {
+				if m.Counter != nil {
+					values[mf.GetName()] = m.Counter.GetValue()
+					continue
+				}
+
+				values[mf.GetName()] = m.Gauge.GetValue()
+			}
 		}
 
-		assert.Equal(t, test.expectedPrefix, prefix, test.name)
-		assert.Equal(t, test.expectedLabels, labels, test.name)
+		return values
 	}
+
+	update(1<<32 - 100)
+	before := gather()
+
+	// The stream fails and the tree is rebuilt by the reconnect
+	time.Sleep(time.Second)
+	ta.setTree(ta.newTree())
+	update(50)
+	after := gather()
+
+	assert.Equal(t, float64(1<<32-100+150), after["interfaces_interface_state_counters_in_octets"])
+	assert.Equal(t, before["interfaces_interface_state_counters_in_octets_created"], after["interfaces_interface_state_counters_in_octets_created"])
+
+	// States not adopted by the following tree are dropped
+	ta.setTree(ta.newTree())
+	ta.setTree(ta.newTree())
+	update(10)
+	assert.Equal(t, float64(10), gather()["interfaces_interface_state_counters_in_octets"])
 }
@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/pathmatch"
+	log "github.com/sirupsen/logrus"
+)
+
+// Kinds of counter discontinuities
+const (
+	counterReset = "reset"
+	counterWrap  = "wrap"
+)
+
+type counterRule struct {
+	matcher    *pathmatch.Matcher
+	width      uint
+	accumulate bool
+}
+
+// counterRules select the leafs handled as counters. The first matching rule is used.
+type counterRules []*counterRule
+
+func newCounterRules(cfgs []*config.Counter) counterRules {
+	res := make(counterRules, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		matcher, err := pathmatch.Compile(cfg.Path)
+		if err != nil {
+			log.Errorf("Ignoring counter: %v", err)
+			continue
+		}
+
+		res = append(res, &counterRule{
+			matcher:    matcher,
+			width:      cfg.Width,
+			accumulate: cfg.Accumulate,
+		})
+	}
+
+	return res
+}
+
+// lookup returns the rule matching path or nil
+func (r counterRules) lookup(path string) *counterRule {
+	for _, rule := range r {
+		if rule.matcher.Match(path) {
+			return rule
+		}
+	}
+
+	return nil
+}
+
+// counterState tracks a counter across resets and wraps
+type counterState struct {
+	rule *counterRule
+	prev uint64
+	// total is the sum of all increases including the first value
+	total   uint64
+	created time.Time
+}
+
+func newCounterState(rule *counterRule, v uint64, now time.Time) *counterState {
+	return &counterState{
+		rule:    rule,
+		prev:    v,
+		total:   v,
+		created: now,
+	}
+}
+
+// observe records the new counter value v. It returns the value to expose and the kind of a detected discontinuity.
+func (c *counterState) observe(v uint64, now time.Time) (uint64, string) {
+	discontinuity := ""
+	delta := v - c.prev
+	switch {
+	case v >= c.prev:
+	case c.rule.width == 32 && c.prev >= 1<<31 && c.prev < 1<<32 && v < 1<<32:
+		delta = v + 1<<32 - c.prev
+		discontinuity = counterWrap
+	default:
+		// The counter restarted from 0
+		delta = v
+		discontinuity = counterReset
+		if !c.rule.accumulate {
+			c.created = now
+		}
+	}
+
+	c.prev = v
+	c.total += delta
+
+	if c.rule.accumulate {
+		return c.total, discontinuity
+	}
+
+	return v, discontinuity
+}
+
+// counterStates keeps the states of the counters of a target by path, so counters continue when the tree is
+// rebuilt after a reconnect. States not adopted by the tree following the one that created them are dropped.
+type counterStates struct {
+	mu   sync.Mutex
+	cur  map[string]*counterState
+	prev map[string]*counterState
+}
+
+func newCounterStates() *counterStates {
+	return &counterStates{
+		cur: make(map[string]*counterState),
+	}
+}
+
+// rotate starts a new generation for a new tree
+func (c *counterStates) rotate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prev = c.cur
+	c.cur = make(map[string]*counterState)
+}
+
+// adopt returns a copy of the state of the counter at path of the previous tree or nil
+func (c *counterStates) adopt(path string) *counterState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := c.prev[path]
+	if state == nil {
+		return nil
+	}
+
+	// The previous tree may still be collected, so its state is not shared
+	cp := *state
+	delete(c.prev, path)
+	c.cur[path] = &cp
+	return &cp
+}
+
+// set stores the state of the counter at path of the current tree
+func (c *counterStates) set(path string, state *counterState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cur[path] = state
+}
@@ -0,0 +1,78 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounterState(t *testing.T) {
+	start := time.Unix(1000, 0)
+
+	tests := []struct {
+		name          string
+		rule          *counterRule
+		values        []uint64
+		expected      []uint64
+		discontinuity []string
+		createdOffset time.Duration
+	}{
+		{
+			name:          "Reset",
+			rule:          &counterRule{width: 64},
+			values:        []uint64{100, 150, 20, 30},
+			expected:      []uint64{150, 20, 30},
+			discontinuity: []string{"", counterReset, ""},
+			// The reset is the third value
+			createdOffset: 2 * time.Second,
+		},
+		{
+			name:          "Reset accumulated",
+			rule:          &counterRule{width: 64, accumulate: true},
+			values:        []uint64{100, 150, 20, 30},
+			expected:      []uint64{150, 170, 180},
+			discontinuity: []string{"", counterReset, ""},
+			createdOffset: 0,
+		},
+		{
+			name:          "32 bit wrap accumulated",
+			rule:          &counterRule{width: 32, accumulate: true},
+			values:        []uint64{1<<32 - 10, 5, 15},
+			expected:      []uint64{1<<32 + 5, 1<<32 + 15},
+			discontinuity: []string{counterWrap, ""},
+			createdOffset: 0,
+		},
+		{
+			name:          "32 bit reset",
+			rule:          &counterRule{width: 32},
+			values:        []uint64{1000, 5},
+			expected:      []uint64{5},
+			discontinuity: []string{counterReset},
+			createdOffset: time.Second,
+		},
+		{
+			name:          "64 bit precision",
+			rule:          &counterRule{width: 64, accumulate: true},
+			values:        []uint64{1<<60 + 1, 1<<60 + 2, 1},
+			expected:      []uint64{1<<60 + 2, 1<<60 + 3},
+			discontinuity: []string{"", counterReset},
+			createdOffset: 0,
+		},
+	}
+
+	for _, test := range tests {
+		c := newCounterState(test.rule, test.values[0], start)
+		values := make([]uint64, 0)
+		discontinuity := make([]string, 0)
+		for i, v := range test.values[1:] {
+			res, d := c.observe(v, start.Add(time.Duration(i+1)*time.Second))
+			values = append(values, res)
+			discontinuity = append(discontinuity, d)
+		}
+
+		assert.Equal(t, test.expected, values, test.name)
+		assert.Equal(t, test.discontinuity, discontinuity, test.name)
+		assert.Equal(t, start.Add(test.createdOffset), c.created, test.name)
+	}
+}
@@ -0,0 +1,203 @@
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/derived"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// derivedMetrics computes the configured derived metrics from the series of a target at collect time
+type derivedMetrics struct {
+	metrics []*derivedMetric
+	// selectors caches the selectors matching the path of a series by metric name
+	selectors   map[string][]*derived.Selector
+	selectorsMu sync.RWMutex
+}
+
+type derivedMetric struct {
+	name      string
+	help      string
+	valueType prometheus.ValueType
+	labels    map[string]string
+	expr      *derived.Expr
+	descs     map[string]*prometheus.Desc
+	descsMu   sync.Mutex
+}
+
+// newDerivedMetrics compiles cfgs. It returns nil if there is nothing to do.
+func newDerivedMetrics(cfgs []*config.DerivedMetric) *derivedMetrics {
+	res := &derivedMetrics{
+		metrics:   make([]*derivedMetric, 0, len(cfgs)),
+		selectors: make(map[string][]*derived.Selector),
+	}
+
+	for _, cfg := range cfgs {
+		expr, err := derived.Parse(cfg.Expr)
+		if err != nil {
+			log.Errorf("Ignoring derived metric %s: %v", cfg.Name, err)
+			continue
+		}
+
+		valueType := prometheus.GaugeValue
+		if cfg.Type == config.DerivedMetricCounter {
+			valueType = prometheus.CounterValue
+		}
+
+		res.metrics = append(res.metrics, &derivedMetric{
+			name:      cfg.Name,
+			help:      cfg.Help,
+			valueType: valueType,
+			labels:    cfg.Labels,
+			expr:      expr,
+			descs:     make(map[string]*prometheus.Desc),
+		})
+	}
+
+	if len(res.metrics) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// selectorsFor returns all selectors matching the path of the series with metric name name
+func (d *derivedMetrics) selectorsFor(name string) []*derived.Selector {
+	d.selectorsMu.RLock()
+	sels, ok := d.selectors[name]
+	d.selectorsMu.RUnlock()
+
+	if ok {
+		return sels
+	}
+
+	path := "/" + name
+	for _, m := range d.metrics {
+		for _, sel := range m.expr.Selectors() {
+			if sel.MatchPath(path) {
+				sels = append(sels, sel)
+			}
+		}
+	}
+
+	d.selectorsMu.Lock()
+	d.selectors[name] = sels
+	d.selectorsMu.Unlock()
+
+	return sels
+}
+
+// derivedInput collects the samples selected by the selectors of the derived metrics during one collect
+type derivedInput struct {
+	d       *derivedMetrics
+	samples map[*derived.Selector][]derived.Sample
+}
+
+func (d *derivedMetrics) newInput() *derivedInput {
+	if d == nil {
+		return nil
+	}
+
+	return &derivedInput{
+		d:       d,
+		samples: make(map[*derived.Selector][]derived.Sample),
+	}
+}
+
+// add adds the value v of the series described by meta to all selectors matching its path
+func (in *derivedInput) add(meta *seriesMeta, v float64) {
+	sels := in.d.selectorsFor(meta.metric.name)
+	if len(sels) == 0 {
+		return
+	}
+
+	labels := make(map[string]string, len(meta.sourceLabels))
+	for _, l := range meta.sourceLabels {
+		labels[labelKeyReplacer.Replace(l.key)] = labelValueReplacer.Replace(l.value)
+	}
+
+	s := derived.Sample{
+		Labels: labels,
+		Value:  v,
+	}
+
+	for _, sel := range sels {
+		in.samples[sel] = append(in.samples[sel], s)
+	}
+}
+
+func (in *derivedInput) lookup(sel *derived.Selector) []derived.Sample {
+	return in.samples[sel]
+}
+
+func (t *Target) collectDerived(ch chan<- prometheus.Metric, in *derivedInput) {
+	for _, m := range in.d.metrics {
+		samples, err := m.expr.Eval(in.lookup)
+		if err != nil {
+			t.collectFailed(collectErrorDerived, fmt.Errorf("unable to evaluate derived metric %s: %v", m.name, err))
+			continue
+		}
+
+		for _, s := range samples {
+			keys, values := m.labelPairs(s.Labels, t.devName)
+			cm, err := prometheus.NewConstMetric(m.describe(keys), m.valueType, s.Value, values...)
+			if err != nil {
+				t.collectFailed(collectErrorInvalidMetric, fmt.Errorf("invalid derived metric %s: %v", m.name, err))
+				continue
+			}
+
+			ch <- cm
+		}
+	}
+}
+
+// labelPairs returns the sorted label names and values of a sample of m of target device. Configured labels override
+// sample labels. The device label is always set so series of different targets do not collide.
+func (m *derivedMetric) labelPairs(sampleLabels map[string]string, device string) ([]string, []string) {
+	labels := make(map[string]string, len(sampleLabels)+len(m.labels)+1)
+	for k, v := range sampleLabels {
+		labels[k] = v
+	}
+
+	labels["device"] = device
+
+	for k, v := range m.labels {
+		labels[k] = v
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	values := make([]string, len(keys))
+	for i, k := range keys {
+		values[i] = labels[k]
+	}
+
+	return keys, values
+}
+
+// describe returns the cached desc of m with label names keys
+func (m *derivedMetric) describe(keys []string) *prometheus.Desc {
+	descKey := strings.Join(keys, "\xff")
+
+	m.descsMu.Lock()
+	defer m.descsMu.Unlock()
+
+	if d, ok := m.descs[descKey]; ok {
+		return d
+	}
+
+	d := prometheus.NewDesc(m.name, m.help, keys, nil)
+	m.descs[descKey] = d
+
+	return d
+}
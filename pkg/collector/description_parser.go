@@ -0,0 +1,199 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/pathmatch"
+	"github.com/prometheus/common/model"
+	log "github.com/sirupsen/logrus"
+)
+
+var invalidLabelCharRegexp = regexp.MustCompile("[^a-zA-Z0-9_]")
+
+type descriptionParser struct {
+	cfg     *config.DescriptionParser
+	matcher *pathmatch.Matcher
+	regex   *regexp.Regexp
+	allowed map[string]struct{}
+}
+
+// descriptionParsers extract labels from descriptions. The first parser matching the path of a description is used.
+type descriptionParsers []*descriptionParser
+
+func newDescriptionParsers(cfgs []*config.DescriptionParser) descriptionParsers {
+	res := make(descriptionParsers, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		dp, err := newDescriptionParser(cfg)
+		if err != nil {
+			log.Errorf("Ignoring description parser: %v", err)
+			continue
+		}
+
+		res = append(res, dp)
+	}
+
+	return res
+}
+
+func newDescriptionParser(cfg *config.DescriptionParser) (*descriptionParser, error) {
+	matcher, err := pathmatch.Compile(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	dp := &descriptionParser{
+		cfg:     cfg,
+		matcher: matcher,
+	}
+
+	if cfg.Type == config.DescriptionParserRegex {
+		dp.regex, err = regexp.Compile(cfg.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %v", cfg.Regex, err)
+		}
+	}
+
+	if len(cfg.Labels) > 0 {
+		dp.allowed = make(map[string]struct{}, len(cfg.Labels))
+		for _, l := range cfg.Labels {
+			dp.allowed[l] = struct{}{}
+		}
+	}
+
+	return dp, nil
+}
+
+// parse extracts labels from the description received for path
+func (dps descriptionParsers) parse(path string, description string) []label {
+	for _, dp := range dps {
+		if dp.matcher.Match(path) {
+			return dp.parse(description)
+		}
+	}
+
+	return labelStringToLabels(description)
+}
+
+func (dp *descriptionParser) parse(description string) []label {
+	var kvs []label
+	switch dp.cfg.Type {
+	case config.DescriptionParserRegex:
+		kvs = dp.parseRegex(description)
+	case config.DescriptionParserJSON:
+		kvs = parseJSONDescription(description)
+	default:
+		kvs = parseKVDescription(description, dp.cfg.PairSeparator, dp.cfg.KeyValueSeparator)
+	}
+
+	res := make([]label, 0, len(kvs))
+	for _, kv := range kvs {
+		if dp.allowed != nil {
+			if _, ok := dp.allowed[kv.key]; !ok {
+				continue
+			}
+		}
+
+		key := sanitizeLabelName(kv.key)
+		if key == "" || kv.value == "" {
+			continue
+		}
+
+		res = append(res, label{
+			key:   key,
+			value: kv.value,
+		})
+	}
+
+	if len(res) == 0 && dp.cfg.FallbackLabel != "" && description != "" {
+		res = append(res, label{
+			key:   dp.cfg.FallbackLabel,
+			value: description,
+		})
+	}
+
+	return res
+}
+
+func (dp *descriptionParser) parseRegex(description string) []label {
+	match := dp.regex.FindStringSubmatch(description)
+	if match == nil {
+		return nil
+	}
+
+	res := make([]label, 0, len(match))
+	for i, name := range dp.regex.SubexpNames() {
+		if name == "" {
+			continue
+		}
+
+		res = append(res, label{
+			key:   name,
+			value: match[i],
+		})
+	}
+
+	return res
+}
+
+func parseJSONDescription(description string) []label {
+	obj := make(map[string]interface{})
+	err := json.Unmarshal([]byte(description), &obj)
+	if err != nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	res := make([]label, 0, len(obj))
+	for _, k := range keys {
+		switch v := obj[k].(type) {
+		case string, float64, bool:
+			res = append(res, label{
+				key:   k,
+				value: fmt.Sprint(v),
+			})
+		}
+	}
+
+	return res
+}
+
+func parseKVDescription(description string, pairSeparator string, keyValueSeparator string) []label {
+	res := make([]label, 0)
+	for _, pair := range strings.Split(description, pairSeparator) {
+		kv := strings.SplitN(pair, keyValueSeparator, 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		res = append(res, label{
+			key:   strings.TrimSpace(kv[0]),
+			value: strings.TrimSpace(kv[1]),
+		})
+	}
+
+	return res
+}
+
+// sanitizeLabelName turns name into a valid Prometheus label name or returns an empty string
+func sanitizeLabelName(name string) string {
+	name = invalidLabelCharRegexp.ReplaceAllString(name, "_")
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+
+	if !model.LabelName(name).IsValid() || strings.HasPrefix(name, "__") {
+		return ""
+	}
+
+	return name
+}
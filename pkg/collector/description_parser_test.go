@@ -0,0 +1,134 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescriptionParsersParse(t *testing.T) {
+	dps := newDescriptionParsers([]*config.DescriptionParser{
+		{
+			Path:          "/interfaces/interface/state/description",
+			Type:          config.DescriptionParserRegex,
+			Regex:         `CUST:(?P<customer>[^;]+);CKT:(?P<circuit>[^;]+)(;\[(?P<role>[^\]]+)\])?`,
+			FallbackLabel: "description",
+		},
+		{
+			Path:              "/interfaces/interface/subinterfaces/**/description",
+			Type:              config.DescriptionParserKV,
+			PairSeparator:     ";",
+			KeyValueSeparator: ":",
+			Labels:            []string{"CUST", "CKT"},
+		},
+		{
+			Path: "/lacp/**/description",
+			Type: config.DescriptionParserJSON,
+		},
+	})
+
+	tests := []struct {
+		name        string
+		path        string
+		description string
+		expected    []label
+	}{
+		{
+			name:        "Regex",
+			path:        "/interfaces/interface/state/description",
+			description: "CUST:acme;CKT:12345;[core]",
+			expected: []label{
+				{
+					key:   "customer",
+					value: "acme",
+				},
+				{
+					key:   "circuit",
+					value: "12345",
+				},
+				{
+					key:   "role",
+					value: "core",
+				},
+			},
+		},
+		{
+			name:        "Regex with empty group",
+			path:        "/interfaces/interface/state/description",
+			description: "CUST:acme;CKT:12345",
+			expected: []label{
+				{
+					key:   "customer",
+					value: "acme",
+				},
+				{
+					key:   "circuit",
+					value: "12345",
+				},
+			},
+		},
+		{
+			name:        "Regex fallback",
+			path:        "/interfaces/interface/state/description",
+			description: "uplink to core",
+			expected: []label{
+				{
+					key:   "description",
+					value: "uplink to core",
+				},
+			},
+		},
+		{
+			name:        "KV with allowlist",
+			path:        "/interfaces/interface/subinterfaces/subinterface/state/description",
+			description: "CUST:acme;CKT:12345;[core];FOO:bar",
+			expected: []label{
+				{
+					key:   "CUST",
+					value: "acme",
+				},
+				{
+					key:   "CKT",
+					value: "12345",
+				},
+			},
+		},
+		{
+			name:        "JSON",
+			path:        "/lacp/interfaces/interface/state/description",
+			description: `{"customer": "acme", "circuit-id": 12345, "nested": {"foo": "bar"}}`,
+			expected: []label{
+				{
+					key:   "circuit_id",
+					value: "12345",
+				},
+				{
+					key:   "customer",
+					value: "acme",
+				},
+			},
+		},
+		{
+			name:        "Invalid JSON",
+			path:        "/lacp/interfaces/interface/state/description",
+			description: `{"customer": `,
+			expected:    []label{},
+		},
+		{
+			name:        "Default",
+			path:        "/components/component/state/description",
+			description: "foo,bar=baz",
+			expected: []label{
+				{
+					key:   "bar",
+					value: "baz",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, dps.parse(test.path, test.description), test.name)
+	}
+}
@@ -2,27 +2,73 @@ package collector
 
 import "sync"
 
+// idCache caches the identifiers of paths. Identifiers are interned, so equal identifiers share one pointer.
 type idCache struct {
-	cache   map[string][]identifier
-	cacheMu sync.RWMutex
+	cache    map[string][]*identifier
+	interned map[identifierKey]*identifier
+	cacheMu  sync.RWMutex
+}
+
+type identifierKey struct {
+	name   string
+	labels string
 }
 
 func newIDCache() *idCache {
 	return &idCache{
-		cache: make(map[string][]identifier),
+		cache:    make(map[string][]*identifier),
+		interned: make(map[identifierKey]*identifier),
 	}
 }
 
-func (c *idCache) lookup(p string) []identifier {
+func (c *idCache) lookup(p string) []*identifier {
 	c.cacheMu.RLock()
 	defer c.cacheMu.RUnlock()
 
 	return c.cache[p]
 }
 
-func (c *idCache) set(p string, ids []identifier) {
+func (c *idCache) set(p string, ids []*identifier) {
 	c.cacheMu.Lock()
 	defer c.cacheMu.Unlock()
 
 	c.cache[p] = ids
 }
+
+// get returns the interned identifier for name and labels or nil
+func (c *idCache) get(name string, labels string) *identifier {
+	c.cacheMu.RLock()
+	defer c.cacheMu.RUnlock()
+
+	return c.interned[identifierKey{
+		name:   name,
+		labels: labels,
+	}]
+}
+
+// intern returns the identifier for name and labels (canonical list keys)
+func (c *idCache) intern(name string, labels string) *identifier {
+	k := identifierKey{
+		name:   name,
+		labels: labels,
+	}
+
+	c.cacheMu.RLock()
+	id := c.interned[k]
+	c.cacheMu.RUnlock()
+
+	if id != nil {
+		return id
+	}
+
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+
+	id = c.interned[k]
+	if id == nil {
+		id = newIdentifier(name, labels)
+		c.interned[k] = id
+	}
+
+	return id
+}
@@ -0,0 +1,58 @@
+package collector
+
+import (
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/pathmatch"
+)
+
+// leafFilter decides which leafs are stored in the tree
+type leafFilter struct {
+	include []*pathmatch.Matcher
+	exclude []*pathmatch.Matcher
+}
+
+// newLeafFilter creates a leaf filter. It returns nil if include and exclude are empty.
+// Patterns are expected to be validated by the config package.
+func newLeafFilter(include []string, exclude []string) *leafFilter {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil
+	}
+
+	f := &leafFilter{
+		include: make([]*pathmatch.Matcher, 0, len(include)),
+		exclude: make([]*pathmatch.Matcher, 0, len(exclude)),
+	}
+
+	for _, p := range include {
+		f.include = append(f.include, pathmatch.MustCompile(p))
+	}
+
+	for _, p := range exclude {
+		f.exclude = append(f.exclude, pathmatch.MustCompile(p))
+	}
+
+	return f
+}
+
+// admit reports whether a leaf with schema path path (without list keys) is to be stored.
+// A leaf is stored if it matches any include pattern (or there are none) and no exclude pattern.
+func (f *leafFilter) admit(path string) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.include) > 0 && !matchAny(f.include, path) {
+		return false
+	}
+
+	return !matchAny(f.exclude, path)
+}
+
+func matchAny(matchers []*pathmatch.Matcher, path string) bool {
+	for _, m := range matchers {
+		if m.Match(path) {
+			return true
+		}
+	}
+
+	return false
+}
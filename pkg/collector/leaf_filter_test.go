@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeafFilterAdmit(t *testing.T) {
+	tests := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		path     string
+		expected bool
+	}{
+		{
+			name:     "No filter",
+			path:     "/interfaces/interface/state/counters/in-octets",
+			expected: true,
+		},
+		{
+			name:     "Included",
+			include:  []string{"/interfaces/**"},
+			path:     "/interfaces/interface/state/counters/in-octets",
+			expected: true,
+		},
+		{
+			name:     "Not included",
+			include:  []string{"/interfaces/**"},
+			path:     "/network-instances/network-instance/state/type",
+			expected: false,
+		},
+		{
+			name:     "Excluded",
+			exclude:  []string{"/interfaces/interface/state/counters/*-pkts"},
+			path:     "/interfaces/interface/state/counters/in-unicast-pkts",
+			expected: false,
+		},
+		{
+			name:     "Included but excluded",
+			include:  []string{"/interfaces/**"},
+			exclude:  []string{"~.*/subinterfaces/.*"},
+			path:     "/interfaces/interface/subinterfaces/subinterface/state/counters/in-octets",
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		f := newLeafFilter(test.include, test.exclude)
+		assert.Equal(t, test.expected, f.admit(test.path), test.name)
+	}
+}
@@ -0,0 +1,156 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+)
+
+const (
+	limitMaxNodes           = "max_nodes"
+	limitMaxSeriesPerMetric = "max_series_per_metric"
+	limitMaxLabelValues     = "max_label_values"
+)
+
+// limitError is returned if an insert has been refused because of a limit
+type limitError struct {
+	limit string
+	path  string
+}
+
+func (e *limitError) Error() string {
+	return fmt.Sprintf("%s reached, refusing to insert %s", e.limit, e.path)
+}
+
+// treeLimits tracks the cardinality of a tree. Limits of 0 are unlimited.
+type treeLimits struct {
+	maxNodes           int
+	maxSeriesPerMetric int
+	maxLabelValues     int
+	nodes              int
+	series             map[string]int
+	labelValues        map[string]map[string]struct{}
+}
+
+func newTreeLimits(cfg *config.Limits) *treeLimits {
+	if cfg == nil {
+		return nil
+	}
+
+	l := &treeLimits{
+		maxNodes:           limitValue(cfg.MaxNodes),
+		maxSeriesPerMetric: limitValue(cfg.MaxSeriesPerMetric),
+		maxLabelValues:     limitValue(cfg.MaxLabelValues),
+		series:             make(map[string]int),
+		labelValues:        make(map[string]map[string]struct{}),
+	}
+
+	if l.maxNodes == 0 && l.maxSeriesPerMetric == 0 && l.maxLabelValues == 0 {
+		return nil
+	}
+
+	return l
+}
+
+// limitValue returns the value of the limit v, 0 (unlimited) if it is unset
+func limitValue(v *int) int {
+	if v == nil {
+		return 0
+	}
+
+	return *v
+}
+
+// admit checks if a path can be inserted into root. leaf is true if the path will become a series.
+// If the path is admitted the new nodes are accounted for.
+func (l *treeLimits) admit(root *node, ids []*identifier, leaf bool) error {
+	depth, n := root.lookup(ids)
+	missing := ids[depth:]
+	newSeries := leaf && (len(missing) > 0 || !n.real)
+
+	if l.maxNodes > 0 && l.nodes+len(missing) > l.maxNodes {
+		return &limitError{limit: limitMaxNodes, path: identifiersToPath(ids)}
+	}
+
+	metricName := ""
+	if newSeries && l.maxSeriesPerMetric > 0 {
+		metricName = identifiersToSchemaPath(ids)
+		if l.series[metricName] >= l.maxSeriesPerMetric {
+			return &limitError{limit: limitMaxSeriesPerMetric, path: identifiersToPath(ids)}
+		}
+	}
+
+	newLabels := make([]label, 0)
+	if l.maxLabelValues > 0 {
+		for _, id := range missing {
+			for _, lbl := range id.keys {
+				values := l.labelValues[lbl.key]
+				if _, ok := values[lbl.value]; ok {
+					continue
+				}
+
+				if len(values) >= l.maxLabelValues {
+					return &limitError{limit: limitMaxLabelValues, path: identifiersToPath(ids)}
+				}
+
+				newLabels = append(newLabels, lbl)
+			}
+		}
+	}
+
+	l.nodes += len(missing)
+	if metricName != "" {
+		l.series[metricName]++
+	}
+
+	for _, lbl := range newLabels {
+		if l.labelValues[lbl.key] == nil {
+			l.labelValues[lbl.key] = make(map[string]struct{})
+		}
+
+		l.labelValues[lbl.key][lbl.value] = struct{}{}
+	}
+
+	return nil
+}
+
+// lookup follows path as far as it exists. It returns the number of existing elements and the last node found.
+func (n *node) lookup(path []*identifier) (int, *node) {
+	depth := 0
+	cur := n
+	for _, id := range path {
+		next := cur.child(id)
+		if next == nil {
+			break
+		}
+
+		cur = next
+		depth++
+	}
+
+	return depth, cur
+}
+
+func identifiersToSchemaPath(ids []*identifier) string {
+	var sb strings.Builder
+	for _, id := range ids {
+		sb.WriteString("/")
+		sb.WriteString(id.name)
+	}
+
+	return sb.String()
+}
+
+func identifiersToPath(ids []*identifier) string {
+	var sb strings.Builder
+	for _, id := range ids {
+		sb.WriteString("/")
+		sb.WriteString(id.name)
+		if id.labels != "" {
+			sb.WriteString("[" + id.labels + "]")
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,117 @@
+package collector
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func intAddr(v int) *int {
+	return &v
+}
+
+func TestTreeLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		limits   *config.Limits
+		paths    []string
+		expected []string
+	}{
+		{
+			name: "Max nodes",
+			limits: &config.Limits{
+				MaxNodes: intAddr(5),
+			},
+			paths: []string{
+				"/interfaces/interface[name='xe-0/0/0']/state/pkts",
+				"/interfaces/interface[name='xe-0/0/0']/state/bytes",
+				"/interfaces/interface[name='xe-0/0/1']/state/pkts",
+			},
+			expected: []string{
+				"",
+				"",
+				limitMaxNodes,
+			},
+		},
+		{
+			name: "Max series per metric",
+			limits: &config.Limits{
+				MaxSeriesPerMetric: intAddr(2),
+			},
+			paths: []string{
+				"/interfaces/interface[name='xe-0/0/0']/state/pkts",
+				"/interfaces/interface[name='xe-0/0/1']/state/pkts",
+				"/interfaces/interface[name='xe-0/0/1']/state/pkts",
+				"/interfaces/interface[name='xe-0/0/2']/state/bytes",
+				"/interfaces/interface[name='xe-0/0/2']/state/pkts",
+			},
+			expected: []string{
+				"",
+				"",
+				"",
+				"",
+				limitMaxSeriesPerMetric,
+			},
+		},
+		{
+			name: "Max label values",
+			limits: &config.Limits{
+				MaxLabelValues: intAddr(2),
+			},
+			paths: []string{
+				"/interfaces/interface[name='xe-0/0/0']/state/pkts",
+				"/interfaces/interface[name='xe-0/0/1']/state/pkts",
+				"/interfaces/interface[name='xe-0/0/0']/state/bytes",
+				"/interfaces/interface[name='xe-0/0/2']/state/pkts",
+				"/firewall/filter[name='xe-0/0/2']/state/pkts",
+			},
+			expected: []string{
+				"",
+				"",
+				"",
+				limitMaxLabelValues,
+				"",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		tr := newTree("test")
+		tr.limits = newTreeLimits(test.limits)
+
+		for i, p := range test.paths {
+			_, err := tr.insert(p, doubleValue(float64(i)))
+			if test.expected[i] == "" {
+				assert.NoError(t, err, "%s: %s", test.name, p)
+				continue
+			}
+
+			if assert.IsType(t, &limitError{}, err, "%s: %s", test.name, p) {
+				assert.Equal(t, test.expected[i], err.(*limitError).limit, "%s: %s", test.name, p)
+			}
+		}
+	}
+}
+
+func TestTreeLimitsRefusedPathsNotCached(t *testing.T) {
+	tr := newTree("test")
+	tr.limits = newTreeLimits(&config.Limits{MaxLabelValues: intAddr(1)})
+
+	_, err := tr.insert("/flows/flow[id='0']/state/pkts", uintValue(1))
+	assert.NoError(t, err)
+
+	cached := len(tr.idCache.cache)
+	interned := len(tr.idCache.interned)
+	for i := 1; i < 100; i++ {
+		_, err = tr.insert(fmt.Sprintf("/flows/flow[id='%d']/state/pkts", i), uintValue(1))
+		assert.IsType(t, &limitError{}, err)
+
+		err = tr.setDescription(fmt.Sprintf("/flows/flow[id='%d']/", i), "foo", nil)
+		assert.IsType(t, &limitError{}, err)
+	}
+
+	assert.Equal(t, cached, len(tr.idCache.cache))
+	assert.Equal(t, interned, len(tr.idCache.interned))
+}
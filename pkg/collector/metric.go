@@ -1,14 +1,18 @@
 package collector
 
 import (
+	"strings"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/schema"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 type metric struct {
 	name   string
 	labels []label
-	value  interface{}
+	value  value
 	desc   *prometheus.Desc
+	meta   *schema.Leaf
 }
 
 type label struct {
@@ -17,11 +21,53 @@ type label struct {
 }
 
 func (m *metric) promName() string {
-	return metricNameReplacer.Replace(m.name)
+	name := metricNameReplacer.Replace(m.name)
+	if m.meta == nil {
+		return name
+	}
+
+	suffix := m.meta.UnitSuffix()
+	if suffix == "" || strings.HasSuffix(name, "_"+suffix) {
+		return name
+	}
+
+	return name + "_" + suffix
+}
+
+func (m *metric) help() string {
+	if m.meta == nil || m.meta.Description == "" {
+		return m.name
+	}
+
+	return m.meta.Description
+}
+
+func (m *metric) valueType() prometheus.ValueType {
+	if m.meta != nil {
+		if m.meta.Counter {
+			return prometheus.CounterValue
+		}
+
+		return prometheus.GaugeValue
+	}
+
+	if strings.Contains(m.name, "counters") {
+		return prometheus.CounterValue
+	}
+
+	return prometheus.GaugeValue
+}
+
+func (m *metric) scale() float64 {
+	if m.meta == nil {
+		return 1
+	}
+
+	return m.meta.Scale()
 }
 
 func (m *metric) describe() *prometheus.Desc {
-	return prometheus.NewDesc(m.promName(), m.name, m.promLabelKeys(), nil)
+	return prometheus.NewDesc(m.promName(), m.help(), m.promLabelKeys(), nil)
 }
 
 func (m *metric) promLabelValues() []string {
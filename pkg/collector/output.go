@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"strings"
+	"time"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/output"
+	pb "github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/telemetry"
+)
+
+// newUpdate creates the update sent to sinks for the value v received at path of series sr
+func (t *Target) newUpdate(sr *series, path string, v interface{}, ts time.Time, seq uint64) *output.Update {
+	meta, _ := sr.get()
+
+	labels := make(map[string]string, len(meta.sourceLabels)+1)
+	labels["device"] = t.devName
+	for _, l := range meta.sourceLabels {
+		labels[labelKeyReplacer.Replace(l.key)] = l.value
+	}
+
+	return &output.Update{
+		Target:    t.devName,
+		Path:      "/" + meta.metric.name,
+		FullPath:  "/" + strings.TrimPrefix(path, "/"),
+		Labels:    labels,
+		Value:     outputValue(v),
+		Timestamp: ts,
+		Sequence:  seq,
+	}
+}
+
+// outputValue returns the typed value of a received value
+func outputValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case *pb.KeyValue_DoubleValue:
+		return x.DoubleValue
+	case *pb.KeyValue_IntValue:
+		return x.IntValue
+	case *pb.KeyValue_UintValue:
+		return x.UintValue
+	case *pb.KeyValue_SintValue:
+		return x.SintValue
+	case *pb.KeyValue_BoolValue:
+		return x.BoolValue
+	case *pb.KeyValue_StrValue:
+		return x.StrValue
+	case *pb.KeyValue_BytesValue:
+		return x.BytesValue
+	}
+
+	return nil
+}
+
+// timestamp converts a device timestamp (ms since epoch) to a time. Missing timestamps are replaced by the current time.
+func timestamp(timestampMS uint64) time.Time {
+	if timestampMS == 0 {
+		return time.Now()
+	}
+
+	return time.Unix(0, int64(timestampMS)*int64(time.Millisecond))
+}
@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/relabel"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	log "github.com/sirupsen/logrus"
+)
+
+type relabeler struct {
+	rules   []*relabel.Rule
+	descs   map[string]*prometheus.Desc
+	descsMu sync.Mutex
+}
+
+// newRelabeler creates a relabeler for cfgs. It returns nil if there is nothing to do.
+func newRelabeler(cfgs []*config.RelabelConfig) *relabeler {
+	if len(cfgs) == 0 {
+		return nil
+	}
+
+	rules, err := relabel.Compile(cfgs)
+	if err != nil {
+		log.Errorf("Ignoring metric_relabel_configs: %v", err)
+		return nil
+	}
+
+	return &relabeler{
+		rules: rules,
+		descs: make(map[string]*prometheus.Desc),
+	}
+}
+
+// relabel applies the relabel rules to m and returns the resulting metric name. It returns false if m has been dropped.
+func (r *relabeler) relabel(m *metric) (string, bool) {
+	keys := m.promLabelKeys()
+	values := m.promLabelValues()
+
+	ls := make(relabel.Labels, 0, len(keys)+1)
+	ls = append(ls, relabel.Label{Name: model.MetricNameLabel, Value: m.promName()})
+	for i := range keys {
+		ls = ls.Set(keys[i], values[i])
+	}
+
+	ls = relabel.Process(ls, r.rules)
+	if ls == nil {
+		return "", false
+	}
+
+	name := ls.Get(model.MetricNameLabel)
+	if name == "" {
+		return "", false
+	}
+
+	labels := make([]label, 0, len(ls))
+	for _, l := range ls {
+		// Labels starting with __ are internal and removed after relabeling
+		if strings.HasPrefix(l.Name, "__") {
+			continue
+		}
+
+		labels = append(labels, label{
+			key:   l.Name,
+			value: l.Value,
+		})
+	}
+
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].key < labels[j].key
+	})
+
+	m.labels = labels
+	m.desc = r.describe(name, m)
+
+	return name, true
+}
+
+// describe returns the cached desc of the relabeled metric m
+func (r *relabeler) describe(name string, m *metric) *prometheus.Desc {
+	keys := m.labelKeys()
+	descKey := name + "\xff" + strings.Join(keys, "\xff")
+
+	r.descsMu.Lock()
+	defer r.descsMu.Unlock()
+
+	if d, ok := r.descs[descKey]; ok {
+		return d
+	}
+
+	d := prometheus.NewDesc(name, m.help(), keys, nil)
+	r.descs[descKey] = d
+
+	return d
+}
@@ -0,0 +1,175 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// series is the scrape cache entry of a leaf. It is maintained on insert, so scrapes neither walk the tree
+// nor recompute labels.
+type series struct {
+	mu    sync.Mutex
+	meta  *seriesMeta
+	value value
+	agg   *aggregate
+	ctr   *counterState
+}
+
+// seriesMeta is the precomputed, immutable description of a series
+type seriesMeta struct {
+	metric metric
+	// name is the metric name after relabeling
+	name string
+	// sourceLabels are the labels before relabeling
+	sourceLabels []label
+	labelValues  []string
+	valueType    prometheus.ValueType
+	scale        float64
+	// dropped is true if the series has been dropped by relabeling
+	dropped bool
+	// aggregateDescs are the descs of the min, max and avg series if the series is aggregated
+	aggregateDescs []*prometheus.Desc
+	window         time.Duration
+	// counter is the counter rule of the series. createdDesc is the desc of its _created series.
+	counter     *counterRule
+	createdDesc *prometheus.Desc
+}
+
+func newSeriesMeta(m metric, r *relabeler) *seriesMeta {
+	m.desc = m.describe()
+	source := m.labels
+	name := m.promName()
+	if r != nil {
+		relabeled, ok := r.relabel(&m)
+		if !ok {
+			return &seriesMeta{
+				metric:       m,
+				sourceLabels: source,
+				valueType:    m.valueType(),
+				scale:        m.scale(),
+				dropped:      true,
+			}
+		}
+
+		name = relabeled
+	}
+
+	return &seriesMeta{
+		metric:       m,
+		name:         name,
+		sourceLabels: source,
+		labelValues:  m.promLabelValues(),
+		valueType:    m.valueType(),
+		scale:        m.scale(),
+	}
+}
+
+// enableAggregation makes the series expose min, max and avg of its values within window (or since the last scrape)
+func (m *seriesMeta) enableAggregation(window time.Duration) {
+	if m.dropped {
+		return
+	}
+
+	keys := m.metric.promLabelKeys()
+	m.aggregateDescs = make([]*prometheus.Desc, len(aggregateSuffixes))
+	for i, suffix := range aggregateSuffixes {
+		m.aggregateDescs[i] = prometheus.NewDesc(m.name+suffix, m.metric.help(), keys, nil)
+	}
+
+	m.window = window
+}
+
+// enableCounter makes the series handle resets and wraps according to rule and expose a _created series
+func (m *seriesMeta) enableCounter(rule *counterRule) {
+	if m.dropped {
+		return
+	}
+
+	m.counter = rule
+	m.createdDesc = prometheus.NewDesc(m.name+"_created", m.metric.help(), m.metric.promLabelKeys(), nil)
+}
+
+// set sets the value of s. It returns the kind of a detected counter discontinuity or an empty string.
+func (s *series) set(v value) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.meta.counter == nil && s.meta.aggregateDescs == nil {
+		s.value = v
+		return ""
+	}
+
+	now := time.Now()
+	discontinuity := ""
+	if s.meta.counter != nil {
+		v, discontinuity = s.observeCounter(v, now)
+	}
+
+	s.value = v
+	if s.meta.aggregateDescs == nil || !v.numeric() {
+		return discontinuity
+	}
+
+	if s.agg == nil {
+		s.agg = newAggregate(s.meta.window)
+	}
+
+	s.agg.observe(v.num, now)
+	return discontinuity
+}
+
+func (s *series) observeCounter(v value, now time.Time) (value, string) {
+	u, ok := v.counter()
+	if !ok {
+		return v, ""
+	}
+
+	if s.ctr == nil {
+		s.ctr = newCounterState(s.meta.counter, u, now)
+		return uintValue(u), ""
+	}
+
+	u, discontinuity := s.ctr.observe(u, now)
+	return uintValue(u), discontinuity
+}
+
+func (s *series) setMeta(meta *seriesMeta) {
+	s.mu.Lock()
+	s.meta = meta
+	s.mu.Unlock()
+}
+
+func (s *series) get() (*seriesMeta, value) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.meta, s.value
+}
+
+// created returns the time the counter s started counting from 0. ok is false if s is not a counter.
+func (s *series) created() (created time.Time, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctr == nil {
+		return time.Time{}, false
+	}
+
+	return s.ctr.created, true
+}
+
+// aggregate returns min, max and avg of the values of s. ok is false if s is not aggregated.
+// Aggregations without window are reset unless peek is set.
+func (s *series) aggregate(now time.Time, peek bool) (min float64, max float64, avg float64, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.agg == nil {
+		return 0, 0, 0, false
+	}
+
+	min, max, avg = s.agg.result(now, peek)
+	return min, max, avg, true
+}
@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TargetSnapshot are the current series of a target
+type TargetSnapshot struct {
+	Target string
+	Series []*Series
+}
+
+// Series is the current value of a series
+type Series struct {
+	// Name is the metric name after relabeling
+	Name string
+	Help string
+	// Counter is true for counters and false for gauges
+	Counter bool
+	// Labels are the labels after relabeling without the device label
+	Labels map[string]string
+	Value  float64
+	// Start is the time a counter started counting
+	Start time.Time
+}
+
+// Snapshot returns the current series of all targets. Series dropped by relabeling or without numeric
+// representation are omitted.
+func (c *Collector) Snapshot() []*TargetSnapshot {
+	c.targetsMu.RLock()
+	defer c.targetsMu.RUnlock()
+
+	res := make([]*TargetSnapshot, 0, len(c.targets))
+	for _, t := range c.targets {
+		res = append(res, t.snapshot())
+	}
+
+	return res
+}
+
+func (t *Target) snapshot() *TargetSnapshot {
+	tr := t.currentTree()
+	res := &TargetSnapshot{
+		Target: t.devName,
+	}
+
+	for _, sr := range tr.snapshot() {
+		meta, val := sr.get()
+		if meta.dropped || val.kind == valueNone {
+			continue
+		}
+
+		v, ok := t.seriesValue(meta, val)
+		if !ok {
+			continue
+		}
+
+		labels := make(map[string]string, len(meta.metric.labels))
+		for i, k := range meta.metric.promLabelKeys() {
+			if k == "device" {
+				continue
+			}
+
+			labels[k] = meta.labelValues[i]
+		}
+
+		start := tr.created
+		if created, ok := sr.created(); ok {
+			start = created
+		}
+
+		res.Series = append(res.Series, &Series{
+			Name:    meta.name,
+			Help:    meta.metric.help(),
+			Counter: meta.valueType == prometheus.CounterValue,
+			Labels:  labels,
+			Value:   v,
+			Start:   start,
+		})
+	}
+
+	return res
+}
@@ -0,0 +1,117 @@
+package collector
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Reasons for collect errors
+const (
+	collectErrorUnknownType   = "unknown_type"
+	collectErrorBytesDecode   = "bytes_decode"
+	collectErrorInvalidMetric = "invalid_metric"
+	collectErrorDerived       = "derived"
+)
+
+const (
+	statsNamespace = "openconfig_exporter"
+	logInterval    = time.Minute
+)
+
+// stats are the internal metrics of the collector
+type stats struct {
+	rejectedInserts        *prometheus.CounterVec
+	collectErrors          *prometheus.CounterVec
+	invalidPaths           *prometheus.CounterVec
+	ingestionLag           *prometheus.GaugeVec
+	counterDiscontinuities *prometheus.CounterVec
+}
+
+func newStats() *stats {
+	return &stats{
+		rejectedInserts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: statsNamespace,
+			Name:      "rejected_inserts_total",
+			Help:      "Number of updates not stored because a limit has been reached",
+		}, []string{"target", "limit"}),
+		collectErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: statsNamespace,
+			Name:      "collect_errors_total",
+			Help:      "Number of values skipped on collect because they could not be converted to a metric",
+		}, []string{"target", "reason"}),
+		invalidPaths: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: statsNamespace,
+			Name:      "invalid_paths_total",
+			Help:      "Number of updates dropped because their path could not be parsed",
+		}, []string{"target"}),
+		ingestionLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: statsNamespace,
+			Name:      "ingestion_lag_seconds",
+			Help:      "Time between the device timestamp of the last update and the end of its processing",
+		}, []string{"target"}),
+		counterDiscontinuities: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: statsNamespace,
+			Name:      "counter_discontinuities_total",
+			Help:      "Number of detected resets and wraps of configured counters",
+		}, []string{"target", "kind"}),
+	}
+}
+
+// Describe is required by prometheus interface
+func (s *stats) Describe(ch chan<- *prometheus.Desc) {
+	s.rejectedInserts.Describe(ch)
+	s.collectErrors.Describe(ch)
+	s.invalidPaths.Describe(ch)
+	s.ingestionLag.Describe(ch)
+	s.counterDiscontinuities.Describe(ch)
+}
+
+// Collect collects the internal metrics
+func (s *stats) Collect(ch chan<- prometheus.Metric) {
+	s.rejectedInserts.Collect(ch)
+	s.collectErrors.Collect(ch)
+	s.invalidPaths.Collect(ch)
+	s.ingestionLag.Collect(ch)
+	s.counterDiscontinuities.Collect(ch)
+}
+
+// logLimiter limits log messages to one per key and interval
+type logLimiter struct {
+	interval   time.Duration
+	last       map[string]time.Time
+	suppressed map[string]int
+	mu         sync.Mutex
+}
+
+func newLogLimiter(interval time.Duration) *logLimiter {
+	return &logLimiter{
+		interval:   interval,
+		last:       make(map[string]time.Time),
+		suppressed: make(map[string]int),
+	}
+}
+
+// warnf logs a warning unless a warning with the same key has been logged within the interval
+func (l *logLimiter) warnf(key string, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.last[key]) < l.interval {
+		l.suppressed[key]++
+		return
+	}
+
+	msg := fmt.Sprintf(format, args...)
+	if l.suppressed[key] > 0 {
+		msg = fmt.Sprintf("%s (%d similar messages suppressed)", msg, l.suppressed[key])
+	}
+
+	log.Warning(msg)
+	l.last[key] = now
+	l.suppressed[key] = 0
+}
@@ -0,0 +1,150 @@
+package collector
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/pathmatch"
+	log "github.com/sirupsen/logrus"
+)
+
+type stringValueMapper struct {
+	exact    map[string]*stringValueMap
+	patterns []*stringValueMap
+	fallback *stringValueMapper
+	cache    map[string]*stringValueMap
+	cacheMu  sync.RWMutex
+}
+
+type stringValueMap struct {
+	matcher         *pathmatch.Matcher
+	values          map[string]float64
+	defaultValue    *float64
+	caseInsensitive bool
+}
+
+// newStringValueMapper creates a mapper for mapping. Paths not covered by mapping are looked up in fallback.
+func newStringValueMapper(mapping config.StringValueMapping, fallback *stringValueMapper) *stringValueMapper {
+	m := &stringValueMapper{
+		exact:    make(map[string]*stringValueMap),
+		patterns: make([]*stringValueMap, 0),
+		fallback: fallback,
+		cache:    make(map[string]*stringValueMap),
+	}
+
+	for pattern, svm := range mapping {
+		if svm == nil {
+			continue
+		}
+
+		matcher, err := pathmatch.Compile(pattern)
+		if err != nil {
+			log.Errorf("Ignoring string value mapping: %v", err)
+			continue
+		}
+
+		compiled := newStringValueMap(matcher, svm)
+		if matcher.IsLiteral() {
+			m.exact[strings.TrimSuffix(pattern, "/")] = compiled
+			continue
+		}
+
+		m.patterns = append(m.patterns, compiled)
+	}
+
+	// The most specific (longest) pattern wins
+	sort.Slice(m.patterns, func(i, j int) bool {
+		a, b := m.patterns[i].matcher.String(), m.patterns[j].matcher.String()
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+
+		return a < b
+	})
+
+	return m
+}
+
+func newStringValueMap(matcher *pathmatch.Matcher, svm *config.StringValueMap) *stringValueMap {
+	res := &stringValueMap{
+		matcher:         matcher,
+		values:          make(map[string]float64, len(svm.Values)),
+		defaultValue:    svm.Default,
+		caseInsensitive: svm.CaseInsensitive,
+	}
+
+	for k, v := range svm.Values {
+		if svm.CaseInsensitive {
+			k = strings.ToLower(k)
+		}
+
+		res.values[k] = v
+	}
+
+	return res
+}
+
+// lookup maps the string value v received for path to a numeric value
+func (m *stringValueMapper) lookup(path string, v string) (float64, bool) {
+	svm := m.mapFor(path)
+	if svm == nil {
+		return 0, false
+	}
+
+	return svm.lookup(v)
+}
+
+func (m *stringValueMapper) mapFor(path string) *stringValueMap {
+	if svm, ok := m.exact[path]; ok {
+		return svm
+	}
+
+	m.cacheMu.RLock()
+	svm, ok := m.cache[path]
+	m.cacheMu.RUnlock()
+	if ok {
+		return svm
+	}
+
+	for _, p := range m.patterns {
+		if p.matcher.Match(path) {
+			svm = p
+			break
+		}
+	}
+
+	if svm == nil && m.fallback != nil {
+		svm = m.fallback.mapFor(path)
+	}
+
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+	m.cache[path] = svm
+
+	return svm
+}
+
+func (svm *stringValueMap) lookup(v string) (float64, bool) {
+	if svm.caseInsensitive {
+		v = strings.ToLower(v)
+	}
+
+	if res, ok := svm.values[v]; ok {
+		return res, true
+	}
+
+	// Identities may be sent with their module prefix, e.g. openconfig-platform-types:ACTIVE
+	if i := strings.LastIndex(v, ":"); i >= 0 {
+		if res, ok := svm.values[v[i+1:]]; ok {
+			return res, true
+		}
+	}
+
+	if svm.defaultValue != nil {
+		return *svm.defaultValue, true
+	}
+
+	return 0, false
+}
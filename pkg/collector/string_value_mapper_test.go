@@ -0,0 +1,171 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func floatAddr(v float64) *float64 {
+	return &v
+}
+
+func TestStringValueMapperLookup(t *testing.T) {
+	mapping := config.StringValueMapping{
+		"/interfaces/interface/state/oper-status": {
+			Values: map[string]float64{
+				"UP":   1,
+				"DOWN": 0,
+			},
+		},
+		"/interfaces/**/oper-status": {
+			Values: map[string]float64{
+				"UP":   10,
+				"DOWN": 20,
+			},
+			Default: floatAddr(-1),
+		},
+		"/interfaces/interface/subinterfaces/**/oper-status": {
+			Values: map[string]float64{
+				"UP": 100,
+			},
+		},
+		"~/network-instances/.*/session-state": {
+			Values: map[string]float64{
+				"ESTABLISHED": 6.5,
+			},
+			CaseInsensitive: true,
+		},
+	}
+
+	tests := []struct {
+		name          string
+		path          string
+		value         string
+		expected      float64
+		expectedFound bool
+	}{
+		{
+			name:          "Exact path",
+			path:          "/interfaces/interface/state/oper-status",
+			value:         "UP",
+			expected:      1,
+			expectedFound: true,
+		},
+		{
+			name:          "Exact path, unknown value",
+			path:          "/interfaces/interface/state/oper-status",
+			value:         "TESTING",
+			expectedFound: false,
+		},
+		{
+			name:          "Most specific glob",
+			path:          "/interfaces/interface/subinterfaces/subinterface/state/oper-status",
+			value:         "UP",
+			expected:      100,
+			expectedFound: true,
+		},
+		{
+			name:          "Most specific glob without default",
+			path:          "/interfaces/interface/subinterfaces/subinterface/state/oper-status",
+			value:         "DOWN",
+			expectedFound: false,
+		},
+		{
+			name:          "Glob",
+			path:          "/interfaces/interface/aggregation/members/oper-status",
+			value:         "DOWN",
+			expected:      20,
+			expectedFound: true,
+		},
+		{
+			name:          "Glob default",
+			path:          "/interfaces/interface/aggregation/members/oper-status",
+			value:         "DORMANT",
+			expected:      -1,
+			expectedFound: true,
+		},
+		{
+			name:          "Case insensitive regular expression",
+			path:          "/network-instances/network-instance/protocols/protocol/bgp/neighbors/neighbor/state/session-state",
+			value:         "Established",
+			expected:      6.5,
+			expectedFound: true,
+		},
+		{
+			name:          "No mapping",
+			path:          "/components/component/state/oper-status",
+			value:         "ACTIVE",
+			expectedFound: false,
+		},
+	}
+
+	m := newStringValueMapper(mapping, nil)
+	for _, test := range tests {
+		v, found := m.lookup(test.path, test.value)
+		assert.Equal(t, test.expectedFound, found, test.name)
+		assert.Equal(t, test.expected, v, test.name)
+	}
+}
+
+func TestStringValueMapperFallback(t *testing.T) {
+	mapping := config.StringValueMapping{
+		"/interfaces/interface/state/oper-status": {
+			Values: map[string]float64{
+				"UP":   1,
+				"DOWN": 0,
+			},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		path          string
+		value         string
+		expected      float64
+		expectedFound bool
+	}{
+		{
+			name:          "Configured mapping overrides built-in mapping",
+			path:          "/interfaces/interface/state/oper-status",
+			value:         "DOWN",
+			expected:      0,
+			expectedFound: true,
+		},
+		{
+			name:          "Configured mapping hides built-in values",
+			path:          "/interfaces/interface/state/oper-status",
+			value:         "LOWER_LAYER_DOWN",
+			expectedFound: false,
+		},
+		{
+			name:          "Built-in mapping",
+			path:          "/interfaces/interface/subinterfaces/subinterface/state/oper-status",
+			value:         "LOWER_LAYER_DOWN",
+			expected:      7,
+			expectedFound: true,
+		},
+		{
+			name:          "Built-in BGP session state",
+			path:          "/network-instances/network-instance/protocols/protocol/bgp/neighbors/neighbor/state/session-state",
+			value:         "ESTABLISHED",
+			expected:      6,
+			expectedFound: true,
+		},
+		{
+			name:          "Built-in identity with module prefix",
+			path:          "/components/component/state/oper-status",
+			value:         "openconfig-platform-types:DISABLED",
+			expected:      2,
+			expectedFound: true,
+		},
+	}
+
+	m := newStringValueMapper(mapping, newStringValueMapper(config.BuiltinStringValueMapping(), nil))
+	for _, test := range tests {
+		v, found := m.lookup(test.path, test.value)
+		assert.Equal(t, test.expectedFound, found, test.name)
+		assert.Equal(t, test.expected, v, test.name)
+	}
+}
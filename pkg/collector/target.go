@@ -8,6 +8,9 @@ import (
 	"time"
 
 	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/output"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/recorder"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/schema"
 	pb "github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/telemetry"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
@@ -21,40 +24,101 @@ const (
 
 // Target represents a streaming telemetry exporting network device
 type Target struct {
-	address            string
-	devName            string
-	con                *grpc.ClientConn
-	client             pb.OpenConfigTelemetryClient
-	paths              []*config.Path
-	metrics            *tree
-	stringValueMapping map[string]map[string]int
-	stopCh             chan struct{}
-	reconnect          bool
-	maxReads           int
-}
-
-func newTarget(tconf *config.Target, stringValueMapping map[string]map[string]int, reconnect bool) *Target {
+	address           string
+	devName           string
+	con               *grpc.ClientConn
+	client            pb.OpenConfigTelemetryClient
+	paths             []*config.Path
+	metrics           *tree
+	metricsMu         sync.RWMutex
+	schema            *schema.Schema
+	stringValueMapper *stringValueMapper
+	relabeler         *relabeler
+	descParsers       descriptionParsers
+	leafFilter        *leafFilter
+	bytesDecoders     bytesDecoders
+	derived           *derivedMetrics
+	aggregations      aggregationRules
+	counters          counterRules
+	counterStates     *counterStates
+	sinks             []output.Sink
+	recorder          *recorder.Recorder
+	limits            *config.Limits
+	stats             *stats
+	logLimiter        *logLimiter
+	stopCh            chan struct{}
+	reconnect         bool
+	maxReads          int
+}
+
+func newTarget(tconf *config.Target, stringValueMapping config.StringValueMapping, fallbackMapper *stringValueMapper, s *schema.Schema, reconnect bool) *Target {
 	t := &Target{
-		address:            fmt.Sprintf("%s:%d", tconf.Hostname, tconf.Port),
-		devName:            tconf.Hostname,
-		paths:              tconf.Paths,
-		metrics:            newTree(tconf.Hostname),
-		stringValueMapping: stringValueMapping,
-		reconnect:          reconnect,
+		address:           fmt.Sprintf("%s:%d", tconf.Hostname, tconf.Port),
+		devName:           tconf.Hostname,
+		paths:             tconf.Paths,
+		limits:            tconf.Limits,
+		leafFilter:        newLeafFilter(tconf.Include, tconf.Exclude),
+		logLimiter:        newLogLimiter(logInterval),
+		schema:            s,
+		stringValueMapper: newStringValueMapper(stringValueMapping, fallbackMapper),
+		reconnect:         reconnect,
+		counterStates:     newCounterStates(),
+	}
+
+	if tconf.Record != nil {
+		t.recorder = recorder.New(tconf.Hostname, tconf.Record)
 	}
 
+	t.metrics = t.newTree()
 	return t
 }
 
+func (t *Target) newTree() *tree {
+	tr := newTree(t.devName)
+	tr.schema = t.schema
+	tr.limits = newTreeLimits(t.limits)
+	tr.relabeler = t.relabeler
+	tr.aggregations = t.aggregations
+	tr.counters = t.counters
+	tr.discontinuity = t.counterDiscontinuity
+
+	// Counters continue in the new tree
+	if t.counterStates != nil {
+		tr.counterStates = t.counterStates
+		t.counterStates.rotate()
+	}
+
+	return tr
+}
+
+func (t *Target) currentTree() *tree {
+	t.metricsMu.RLock()
+	defer t.metricsMu.RUnlock()
+
+	return t.metrics
+}
+
+func (t *Target) setTree(tr *tree) {
+	t.metricsMu.Lock()
+	defer t.metricsMu.Unlock()
+
+	t.metrics = tr
+}
+
 func (t *Target) stop() {
 	t.stopCh <- struct{}{}
 }
 
 func (t *Target) dump() []string {
-	return t.metrics.dump()
+	return t.currentTree().dump()
 }
 
 func (t *Target) subscriptionRequest() *pb.SubscriptionRequest {
+	return SubscriptionRequest(t.paths)
+}
+
+// SubscriptionRequest creates the request subscribing paths
+func SubscriptionRequest(paths []*config.Path) *pb.SubscriptionRequest {
 	subReq := &pb.SubscriptionRequest{
 		AdditionalConfig: &pb.SubscriptionAdditionalConfig{
 			LimitRecords:     -1,
@@ -64,9 +128,10 @@ func (t *Target) subscriptionRequest() *pb.SubscriptionRequest {
 		PathList: make([]*pb.Path, 0),
 	}
 
-	for _, p := range t.paths {
+	for _, p := range paths {
 		subReq.PathList = append(subReq.PathList, &pb.Path{
 			Path:              p.Path,
+			Filter:            p.Filter,
 			SuppressUnchanged: *p.SuppressUnchanged,
 			MaxSilentInterval: uint32(p.MaxSilentIntervalMS),
 			SampleFrequency:   uint32(p.SampleFrequencyMS),
@@ -122,10 +187,11 @@ func (t *Target) process(stream pb.OpenConfigTelemetry_TelemetrySubscribeClient)
 		data, err := stream.Recv()
 		if err != nil {
 			log.Errorf("Failed to receive stream from [%v]: %v", t.devName, err)
-			t.metrics = newTree(t.devName)
+			t.setTree(t.newTree())
 			break
 		}
 
+		t.record(data)
 		t.processOpenConfigData(data)
 
 		i++
@@ -138,6 +204,7 @@ func (t *Target) process(stream pb.OpenConfigTelemetry_TelemetrySubscribeClient)
 // Serve is the main handling routine for a target
 func (t *Target) Serve(con *grpc.ClientConn) {
 	defer con.Close()
+	defer t.closeRecorder()
 
 	for {
 		stream := t.subscribe(con)
@@ -153,7 +220,39 @@ func (t *Target) Serve(con *grpc.ClientConn) {
 	}
 }
 
+// Process processes a message received outside of Serve
+func (t *Target) Process(data *pb.OpenConfigData) {
+	t.processOpenConfigData(data)
+}
+
+// record writes data to the recording of the target if enabled
+func (t *Target) record(data *pb.OpenConfigData) {
+	if t.recorder == nil {
+		return
+	}
+
+	err := t.recorder.Record(time.Now(), data)
+	if err != nil && t.logLimiter != nil {
+		t.logLimiter.warnf("record", "Target %s: unable to record message: %v", t.devName, err)
+	}
+}
+
+func (t *Target) closeRecorder() {
+	if t.recorder == nil {
+		return
+	}
+
+	err := t.recorder.Close()
+	if err != nil {
+		log.Errorf("Target %s: unable to close recording: %v", t.devName, err)
+	}
+}
+
 func (t *Target) processOpenConfigData(data *pb.OpenConfigData) {
+	defer t.observeLag(data.Timestamp)
+
+	tr := t.currentTree()
+	var updates []*output.Update
 	prefix := ""
 	for _, kv := range data.Kv {
 		if kv.Key == "__prefix__" {
@@ -177,6 +276,13 @@ func (t *Target) processOpenConfigData(data *pb.OpenConfigData) {
 			continue
 		}
 
+		path := prefix + kv.Key
+		ids, err := tr.pathToIdentifiers(path)
+		if err != nil {
+			t.invalidPath(err)
+			continue
+		}
+
 		if strings.HasSuffix(kv.Key, "state/description") {
 			if kv.Value == nil {
 				continue
@@ -184,56 +290,195 @@ func (t *Target) processOpenConfigData(data *pb.OpenConfigData) {
 
 			switch value := kv.Value.(type) {
 			case *pb.KeyValue_StrValue:
-				t.metrics.setDescription(strings.Replace(prefix+kv.Key, "state/description", "", -1), value.StrValue)
+				labels := t.descParsers.parse(identifiersToSchemaPath(ids), value.StrValue)
+				err := tr.setDescription(strings.Replace(path, "state/description", "", -1), value.StrValue, labels)
+				if err != nil {
+					t.rejected(err)
+				}
 			}
 		}
 
-		t.metrics.insert(prefix+kv.Key, kv.Value)
+		if t.leafFilter != nil && !t.leafFilter.admit(identifiersToSchemaPath(ids)) {
+			continue
+		}
+
+		v, ok := newValue(kv.Value)
+		if !ok {
+			t.collectFailed(collectErrorUnknownType, fmt.Errorf("unknown data type %T for %s", kv.Value, path))
+			continue
+		}
+
+		sr, err := tr.insert(path, v)
+		if err != nil {
+			t.rejected(err)
+			continue
+		}
+
+		if len(t.sinks) > 0 && v.kind != valueNone {
+			updates = append(updates, t.newUpdate(sr, path, kv.Value, timestamp(data.Timestamp), data.SequenceNumber))
+		}
+	}
+
+	if len(updates) == 0 {
+		return
+	}
+
+	for _, s := range t.sinks {
+		s.Write(updates)
+	}
+}
+
+// observeLag records the time between the device timestamp (ms since epoch) of an update and the end of its processing
+func (t *Target) observeLag(timestampMS uint64) {
+	if t.stats == nil || timestampMS == 0 {
+		return
+	}
+
+	lag := time.Since(timestamp(timestampMS))
+	t.stats.ingestionLag.WithLabelValues(t.devName).Set(lag.Seconds())
+}
+
+func (t *Target) rejected(err error) {
+	limit := "unknown"
+	if le, ok := err.(*limitError); ok {
+		limit = le.limit
+	}
+
+	if t.stats != nil {
+		t.stats.rejectedInserts.WithLabelValues(t.devName, limit).Inc()
+	}
+
+	if t.logLimiter != nil {
+		t.logLimiter.warnf(limit, "Target %s: %v", t.devName, err)
+	}
+}
+
+func (t *Target) counterDiscontinuity(kind string) {
+	if t.stats != nil {
+		t.stats.counterDiscontinuities.WithLabelValues(t.devName, kind).Inc()
 	}
 }
 
-func (t *Target) collect(ch chan<- prometheus.Metric, wg *sync.WaitGroup) {
+func (t *Target) invalidPath(err error) {
+	if t.stats != nil {
+		t.stats.invalidPaths.WithLabelValues(t.devName).Inc()
+	}
+
+	if t.logLimiter != nil {
+		t.logLimiter.warnf("invalid_path", "Target %s: %v", t.devName, err)
+	}
+}
+
+// collect sends the metrics of the target. Aggregations without window are reset unless peek is set.
+func (t *Target) collect(ch chan<- prometheus.Metric, wg *sync.WaitGroup, peek bool) {
 	defer wg.Done()
 
-	res := t.metrics.getMetrics()
-	for _, m := range res {
-		if m.value == nil {
+	in := t.derived.newInput()
+	now := time.Now()
+	for _, sr := range t.currentTree().snapshot() {
+		meta, val := sr.get()
+		if val.kind == valueNone || meta.dropped && in == nil {
 			continue
 		}
 
-		valueType := prometheus.GaugeValue
-		if strings.Contains(m.name, "counters") {
-			valueType = prometheus.CounterValue
-		}
-
-		v := float64(0)
-		switch value := m.value.(type) {
-		case *pb.KeyValue_DoubleValue:
-			v = float64(value.DoubleValue)
-		case *pb.KeyValue_IntValue:
-			v = float64(value.IntValue)
-		case *pb.KeyValue_UintValue:
-			v = float64(value.UintValue)
-		case *pb.KeyValue_SintValue:
-			v = float64(value.SintValue)
-		case *pb.KeyValue_BoolValue:
-			if value.BoolValue {
-				v = 1
-			}
-		case *pb.KeyValue_StrValue:
-			if _, ok := t.stringValueMapping["/"+m.name]; !ok {
-				continue
-			}
+		v, ok := t.seriesValue(meta, val)
+		if !ok {
+			continue
+		}
 
-			if _, ok := t.stringValueMapping["/"+m.name][value.StrValue]; !ok {
-				continue
-			}
+		// Derived metrics are computed from dropped series as well
+		if in != nil {
+			in.add(meta, v)
+		}
 
-			v = float64(t.stringValueMapping["/"+m.name][value.StrValue])
-		default:
-			log.Fatalf("Unknown data type for %v", value)
+		if meta.dropped {
+			continue
+		}
+
+		cm, err := prometheus.NewConstMetric(meta.metric.desc, meta.valueType, v, meta.labelValues...)
+		if err != nil {
+			t.collectFailed(collectErrorInvalidMetric, fmt.Errorf("invalid metric /%s: %v", meta.metric.name, err))
+			continue
+		}
+
+		ch <- cm
+
+		if meta.aggregateDescs != nil {
+			t.collectAggregate(ch, sr, meta, now, peek)
+		}
+
+		if meta.createdDesc != nil {
+			t.collectCreated(ch, sr, meta)
+		}
+	}
+
+	if in != nil {
+		t.collectDerived(ch, in)
+	}
+}
+
+// collectAggregate sends the min, max and avg series of the aggregated series sr
+func (t *Target) collectAggregate(ch chan<- prometheus.Metric, sr *series, meta *seriesMeta, now time.Time, peek bool) {
+	min, max, avg, ok := sr.aggregate(now, peek)
+	if !ok {
+		return
+	}
+
+	for i, v := range []float64{min, max, avg} {
+		cm, err := prometheus.NewConstMetric(meta.aggregateDescs[i], prometheus.GaugeValue, v*meta.scale, meta.labelValues...)
+		if err != nil {
+			t.collectFailed(collectErrorInvalidMetric, fmt.Errorf("invalid metric /%s%s: %v", meta.metric.name, aggregateSuffixes[i], err))
+			continue
 		}
 
-		ch <- prometheus.MustNewConstMetric(m.desc, valueType, v, m.promLabelValues()...)
+		ch <- cm
+	}
+}
+
+// collectCreated sends the _created series of the counter sr
+func (t *Target) collectCreated(ch chan<- prometheus.Metric, sr *series, meta *seriesMeta) {
+	created, ok := sr.created()
+	if !ok {
+		return
+	}
+
+	cm, err := prometheus.NewConstMetric(meta.createdDesc, prometheus.GaugeValue, float64(created.UnixNano())/1e9, meta.labelValues...)
+	if err != nil {
+		t.collectFailed(collectErrorInvalidMetric, fmt.Errorf("invalid metric /%s_created: %v", meta.metric.name, err))
+		return
+	}
+
+	ch <- cm
+}
+
+// seriesValue converts the value of a series to a sample value. It returns false if the value has to be skipped.
+func (t *Target) seriesValue(meta *seriesMeta, val value) (float64, bool) {
+	switch val.kind {
+	case valueString:
+		return t.stringValueMapper.lookup("/"+meta.metric.name, val.str)
+	case valueBytes:
+		decoded, ok, err := t.bytesDecoders.decode("/"+meta.metric.name, []byte(val.str))
+		if err != nil {
+			t.collectFailed(collectErrorBytesDecode, fmt.Errorf("unable to decode /%s: %v", meta.metric.name, err))
+			return 0, false
+		}
+
+		if !ok {
+			return 0, false
+		}
+
+		return decoded * meta.scale, true
+	}
+
+	return val.num * meta.scale, true
+}
+
+func (t *Target) collectFailed(reason string, err error) {
+	if t.stats != nil {
+		t.stats.collectErrors.WithLabelValues(t.devName, reason).Inc()
+	}
+
+	if t.logLimiter != nil {
+		t.logLimiter.warnf(reason, "Target %s: %v", t.devName, err)
 	}
 }
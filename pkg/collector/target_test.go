@@ -1,9 +1,17 @@
 package collector
 
 import (
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/output"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/simulator"
 	pb "github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/telemetry"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -12,7 +20,7 @@ func TestProcessOpenConfigData(t *testing.T) {
 		name     string
 		target   *Target
 		input    *pb.OpenConfigData
-		expected *tree
+		expected map[string]value
 	}{
 		{
 			name: "All ok",
@@ -35,31 +43,8 @@ func TestProcessOpenConfigData(t *testing.T) {
 					},
 				},
 			},
-			expected: &tree{
-				root: &node{
-					id: identifier{
-						labels: "device=test",
-					},
-					children: []node{
-						{
-							id: identifier{
-								name: "foobar",
-							},
-							children: []node{
-								{
-									id: identifier{
-										name: "baz",
-									},
-									real: true,
-									value: &pb.KeyValue_StrValue{
-										StrValue: "hello world",
-									},
-									children: []node{},
-								},
-							},
-						},
-					},
-				},
+			expected: map[string]value{
+				"/foobar/baz": stringValue("hello world"),
 			},
 		},
 		{
@@ -89,31 +74,8 @@ func TestProcessOpenConfigData(t *testing.T) {
 					},
 				},
 			},
-			expected: &tree{
-				root: &node{
-					id: identifier{
-						labels: "device=test",
-					},
-					children: []node{
-						{
-							id: identifier{
-								name: "foobar",
-							},
-							children: []node{
-								{
-									id: identifier{
-										name: "baz",
-									},
-									real: true,
-									value: &pb.KeyValue_StrValue{
-										StrValue: "hello world",
-									},
-									children: []node{},
-								},
-							},
-						},
-					},
-				},
+			expected: map[string]value{
+				"/foobar/baz": stringValue("hello world"),
 			},
 		},
 		{
@@ -135,24 +97,8 @@ func TestProcessOpenConfigData(t *testing.T) {
 					},
 				},
 			},
-			expected: &tree{
-				root: &node{
-					id: identifier{
-						labels: "device=test",
-					},
-					children: []node{
-						{
-							id: identifier{
-								name: "baz",
-							},
-							real: true,
-							value: &pb.KeyValue_StrValue{
-								StrValue: "hello world",
-							},
-							children: []node{},
-						},
-					},
-				},
+			expected: map[string]value{
+				"/baz": stringValue("hello world"),
 			},
 		},
 		{
@@ -176,30 +122,331 @@ func TestProcessOpenConfigData(t *testing.T) {
 					},
 				},
 			},
-			expected: &tree{
-				root: &node{
-					id: identifier{
-						labels: "device=test",
+			expected: map[string]value{
+				"/baz": stringValue("hello world"),
+			},
+		},
+		{
+			name: "Excluded leaf",
+			target: &Target{
+				metrics:    newTree("test"),
+				leafFilter: newLeafFilter(nil, []string{"/interfaces/interface/state/admin-status"}),
+			},
+			input: &pb.OpenConfigData{
+				Kv: []*pb.KeyValue{
+					{
+						Key: "__prefix__",
+						Value: &pb.KeyValue_StrValue{
+							StrValue: "/interfaces/interface[name='xe-0/0/0']/",
+						},
 					},
-					children: []node{
-						{
-							id: identifier{
-								name: "baz",
-							},
-							real: true,
-							value: &pb.KeyValue_StrValue{
-								StrValue: "hello world",
-							},
-							children: []node{},
+					{
+						Key: "state/admin-status",
+						Value: &pb.KeyValue_StrValue{
+							StrValue: "UP",
+						},
+					},
+					{
+						Key: "state/mtu",
+						Value: &pb.KeyValue_UintValue{
+							UintValue: 9000,
 						},
 					},
 				},
 			},
+			expected: map[string]value{
+				"/interfaces/interface[name='xe-0/0/0']/state/mtu": uintValue(9000),
+			},
 		},
 	}
 
 	for _, test := range tests {
 		test.target.processOpenConfigData(test.input)
-		assert.Equal(t, test.expected.root, test.target.metrics.root, test.name)
+		assert.Equal(t, test.expected, leaves(test.target.metrics), test.name)
+		assert.Equal(t, "device=test", test.target.metrics.root.id.labels, test.name)
+	}
+}
+
+func TestProcessOpenConfigDataIngestionLag(t *testing.T) {
+	ta := &Target{
+		devName: "test",
+		metrics: newTree("test"),
+		stats:   newStats(),
+	}
+
+	ta.processOpenConfigData(&pb.OpenConfigData{
+		Timestamp: uint64(time.Now().Add(-5*time.Second).UnixNano() / int64(time.Millisecond)),
+		Kv: []*pb.KeyValue{
+			{
+				Key: "/interfaces/interface[name='xe-0/0/0']/state/mtu",
+				Value: &pb.KeyValue_UintValue{
+					UintValue: 9000,
+				},
+			},
+		},
+	})
+
+	m := &dto.Metric{}
+	err := ta.stats.ingestionLag.WithLabelValues("test").Write(m)
+	if err != nil {
+		t.Fatalf("Unexpected failure: %v", err)
+	}
+
+	lag := m.GetGauge().GetValue()
+	assert.True(t, lag >= 5 && lag < 60, "unexpected lag %v", lag)
+}
+
+type testSink struct {
+	updates []*output.Update
+}
+
+func (s *testSink) Write(updates []*output.Update) {
+	s.updates = append(s.updates, updates...)
+}
+
+func TestProcessOpenConfigDataSinks(t *testing.T) {
+	sink := &testSink{}
+	ta := &Target{
+		devName: "test",
+		metrics: newTree("test"),
+		sinks:   []output.Sink{sink},
+	}
+
+	ta.processOpenConfigData(&pb.OpenConfigData{
+		Timestamp:      1600000000123,
+		SequenceNumber: 42,
+		Kv: []*pb.KeyValue{
+			{
+				Key: "__prefix__",
+				Value: &pb.KeyValue_StrValue{
+					StrValue: "/interfaces/interface[name='xe-0/0/0']/",
+				},
+			},
+			{
+				Key: "state/description",
+				Value: &pb.KeyValue_StrValue{
+					StrValue: "customer=foo",
+				},
+			},
+			{
+				Key: "state/counters/in-octets",
+				Value: &pb.KeyValue_UintValue{
+					UintValue: 1000,
+				},
+			},
+			{
+				Key: "state/admin-status",
+				Value: &pb.KeyValue_StrValue{
+					StrValue: "UP",
+				},
+			},
+		},
+	})
+
+	ts := time.Unix(1600000000, 123000000)
+	expected := []*output.Update{
+		{
+			Target:    "test",
+			Path:      "/interfaces/interface/state/description",
+			FullPath:  "/interfaces/interface[name='xe-0/0/0']/state/description",
+			Labels:    map[string]string{"customer": "foo", "device": "test", "interface_name": "xe-0/0/0"},
+			Value:     "customer=foo",
+			Timestamp: ts,
+			Sequence:  42,
+		},
+		{
+			Target:    "test",
+			Path:      "/interfaces/interface/state/counters/in-octets",
+			FullPath:  "/interfaces/interface[name='xe-0/0/0']/state/counters/in-octets",
+			Labels:    map[string]string{"customer": "foo", "device": "test", "interface_name": "xe-0/0/0"},
+			Value:     uint64(1000),
+			Timestamp: ts,
+			Sequence:  42,
+		},
+		{
+			Target:    "test",
+			Path:      "/interfaces/interface/state/admin-status",
+			FullPath:  "/interfaces/interface[name='xe-0/0/0']/state/admin-status",
+			Labels:    map[string]string{"customer": "foo", "device": "test", "interface_name": "xe-0/0/0"},
+			Value:     "UP",
+			Timestamp: ts,
+			Sequence:  42,
+		},
+	}
+	assert.Equal(t, expected, sink.updates)
+}
+
+func TestTargetSnapshot(t *testing.T) {
+	ta := &Target{
+		devName:           "test",
+		metrics:           newTree("test"),
+		stringValueMapper: newStringValueMapper(nil, nil),
+	}
+
+	ta.processOpenConfigData(&pb.OpenConfigData{
+		Timestamp: 1600000000123,
+		Kv: []*pb.KeyValue{
+			{
+				Key: "__prefix__",
+				Value: &pb.KeyValue_StrValue{
+					StrValue: "/interfaces/interface[name='xe-0/0/0']/",
+				},
+			},
+			{
+				Key: "state/counters/in-octets",
+				Value: &pb.KeyValue_UintValue{
+					UintValue: 1000,
+				},
+			},
+			{
+				Key: "state/mtu",
+				Value: &pb.KeyValue_UintValue{
+					UintValue: 9000,
+				},
+			},
+			{
+				Key: "state/description",
+				Value: &pb.KeyValue_StrValue{
+					StrValue: "uplink",
+				},
+			},
+		},
+	})
+
+	expected := &TargetSnapshot{
+		Target: "test",
+		Series: []*Series{
+			{
+				Name:    "interfaces_interface_state_counters_in_octets",
+				Help:    "interfaces/interface/state/counters/in-octets",
+				Counter: true,
+				Labels:  map[string]string{"interface_name": "xe-0/0/0"},
+				Value:   1000,
+				Start:   ta.metrics.created,
+			},
+			{
+				Name:   "interfaces_interface_state_mtu",
+				Help:   "interfaces/interface/state/mtu",
+				Labels: map[string]string{"interface_name": "xe-0/0/0"},
+				Value:  9000,
+				Start:  ta.metrics.created,
+			},
+		},
+	}
+	assert.Equal(t, expected, ta.snapshot())
+}
+
+func TestConcurrentProcessAndCollect(t *testing.T) {
+	ta := &Target{
+		devName: "test",
+		metrics: newTree("test"),
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for i := 0; i < 1000; i++ {
+			ta.processOpenConfigData(&pb.OpenConfigData{
+				Kv: []*pb.KeyValue{
+					{
+						Key: fmt.Sprintf("/interfaces/interface[name='xe-0/0/%d']/state/mtu", i%100),
+						Value: &pb.KeyValue_UintValue{
+							UintValue: uint64(i),
+						},
+					},
+				},
+			})
+		}
+	}()
+
+	ch := make(chan prometheus.Metric, 100)
+	go func() {
+		for range ch {
+		}
+	}()
+
+	for {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		ta.collect(ch, &wg, false)
+
+		select {
+		case <-done:
+			close(ch)
+			assert.Equal(t, 100, len(ta.currentTree().snapshot()))
+			return
+		default:
+		}
+	}
+}
+
+func TestSubscriptionRequest(t *testing.T) {
+	suppress := true
+	target := &Target{
+		paths: []*config.Path{
+			{
+				Path:                "/interfaces/",
+				Filter:              "^xe-",
+				SuppressUnchanged:   &suppress,
+				MaxSilentIntervalMS: 15000,
+				SampleFrequencyMS:   5000,
+			},
+		},
+	}
+
+	expected := []*pb.Path{
+		{
+			Path:              "/interfaces/",
+			Filter:            "^xe-",
+			SuppressUnchanged: true,
+			MaxSilentInterval: 15000,
+			SampleFrequency:   5000,
+		},
 	}
+
+	assert.Equal(t, expected, target.subscriptionRequest().PathList)
+}
+
+func BenchmarkProcessSimulatedDevice(b *testing.B) {
+	d := simulator.NewDevice("sim", simulator.Options{Interfaces: 48, Neighbors: 16})
+	start := time.Now()
+	msgs := make([]*pb.OpenConfigData, 0)
+	for i := 0; i < 10; i++ {
+		msgs = append(msgs, d.Messages([]string{"/interfaces/", "/network-instances/"}, start.Add(time.Duration(i)*time.Second))...)
+	}
+
+	ta := newTarget(&config.Target{Hostname: "sim"}, nil, nil, nil, false)
+	for _, m := range msgs {
+		ta.processOpenConfigData(m)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ta.processOpenConfigData(msgs[i%len(msgs)])
+	}
+}
+
+// leaves returns the values of all leaves of tr by path
+func leaves(tr *tree) map[string]value {
+	res := make(map[string]value)
+	if tr.root == nil {
+		return res
+	}
+
+	var walk func(n *node, path []*identifier)
+	walk = func(n *node, path []*identifier) {
+		if n.real {
+			_, v := n.series.get()
+			res[identifiersToPath(path)] = v
+		}
+
+		for _, c := range n.children {
+			walk(c, append(path[:len(path):len(path)], c.id))
+		}
+	}
+
+	walk(tr.root, nil)
+	return res
 }
@@ -5,8 +5,11 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/prometheus/client_golang/prometheus"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/ocpath"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/schema"
 )
 
 var (
@@ -26,77 +29,121 @@ var (
 )
 
 type tree struct {
-	lock    sync.RWMutex
-	root    *node
-	idCache *idCache
-	devName string
+	lock         sync.RWMutex
+	root         *node
+	idCache      *idCache
+	devName      string
+	schema       *schema.Schema
+	limits       *treeLimits
+	relabeler    *relabeler
+	aggregations aggregationRules
+	counters     counterRules
+	// counterStates keeps counter states across trees, nil if they are not kept
+	counterStates *counterStates
+	// discontinuity is called with the kind of counter discontinuities detected on insert
+	discontinuity func(kind string)
+	// created is the time the tree has been created, i.e. the subscription started
+	created time.Time
+	series  []*series
+	// published is a copy-on-write view of series ([]*series) for lock free reads
+	published atomic.Value
 }
 
+// childIndexThreshold is the number of children above which a node indexes its children by identifier
+const childIndexThreshold = 8
+
 type node struct {
-	id          identifier
-	real        bool
-	value       interface{}
-	description string
-	desc        *prometheus.Desc
-	children    []node
+	id                *identifier
+	parent            *node
+	real              bool
+	series            *series
+	description       string
+	descriptionLabels []label
+	children          []*node
+	index             map[*identifier]*node
 }
 
+// identifier identifies a node within its parent. Identifiers are interned by the idCache and compared by pointer.
 type identifier struct {
 	name   string
 	labels string
+	keys   []label
+}
+
+func newIdentifier(name string, labels string) *identifier {
+	return &identifier{
+		name:   name,
+		labels: labels,
+		keys:   keyLabels(name, labels),
+	}
 }
 
 func newTree(devName string) *tree {
 	return &tree{
 		idCache: newIDCache(),
 		devName: devName,
+		created: time.Now(),
 	}
 }
 
-func newNode(id identifier) *node {
+func newNode(id *identifier) *node {
 	return &node{
-		id:       id,
-		children: make([]node, 0, 10),
+		id: id,
 	}
 }
 
-func (t *tree) dump() []string {
-	t.lock.RLock()
-	defer t.lock.RUnlock()
+// child returns the child identified by id or nil
+func (n *node) child(id *identifier) *node {
+	if n.index != nil {
+		return n.index[id]
+	}
 
-	if t.root == nil {
-		return nil
+	for _, c := range n.children {
+		if c.id == id {
+			return c
+		}
 	}
 
-	return t.root.dump(0)
+	return nil
 }
 
-func (t *tree) setDescription(path string, v string) {
-	t.lock.Lock()
-	defer t.lock.Unlock()
-
-	ids := t.pathToIdentifiers(path)
-	if t.root == nil {
-		t.root = newNode(identifier{})
+// getOrCreateChild returns the child identified by id. The child is created if it does not exist.
+func (n *node) getOrCreateChild(id *identifier) *node {
+	c := n.child(id)
+	if c != nil {
+		return c
 	}
 
-	t.root.setDescription(ids, v)
-}
+	c = newNode(id)
+	c.parent = n
+	n.children = append(n.children, c)
 
-func (t *tree) insert(path string, v interface{}) {
-	t.lock.Lock()
-	defer t.lock.Unlock()
+	if n.index != nil {
+		n.index[id] = c
+		return c
+	}
 
-	ids := t.pathToIdentifiers(path)
+	if len(n.children) > childIndexThreshold {
+		n.index = make(map[*identifier]*node, len(n.children)*2)
+		for _, x := range n.children {
+			n.index[x.id] = x
+		}
+	}
 
-	if t.root == nil {
-		t.root = newNode(identifier{labels: fmt.Sprintf("device=%s", t.devName)})
+	return c
+}
+
+// walk returns the node at path, creating missing nodes
+func (n *node) walk(path []*identifier) *node {
+	cur := n
+	for _, id := range path {
+		cur = cur.getOrCreateChild(id)
 	}
 
-	t.root.insert(ids, v)
+	return cur
 }
 
-func (t *tree) getMetrics() []metric {
+func (t *tree) dump() []string {
 	t.lock.RLock()
 	defer t.lock.RUnlock()
 
@@ -104,150 +151,187 @@ func (t *tree) getMetrics() []metric {
 		return nil
 	}
 
-	res := newMetricSet()
-	t.root.getMetrics("", res, []label{}, []label{})
-
-	return res.get()
+	return t.root.dump(0)
 }
 
-func (n *node) dump(level int) []string {
-	ret := make([]string, 0)
+func (t *tree) setDescription(path string, v string, labels []label) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
 
-	ret = append(ret, "|\n")
-	ret = append(ret, fmt.Sprintf("%s[%s](%v) = %v\n", n.id.name, n.id.labels, n.description, n.value))
+	ids, cached, err := t.resolvePath(path)
+	if err != nil {
+		return err
+	}
 
-	for _, c := range n.children {
-		ret = append(ret, c.dump(level+1)...)
+	if t.root == nil {
+		t.root = newNode(t.idCache.intern("", ""))
 	}
 
-	for i := range ret {
-		for j := 0; j < level; j++ {
-			ret[i] = " " + ret[i]
+	if t.limits != nil {
+		err := t.limits.admit(t.root, ids, false)
+		if err != nil {
+			return err
 		}
-		ret[i] = "|" + ret[i]
 	}
 
-	return ret
-}
+	if !cached {
+		ids = t.cacheIdentifiers(path, ids)
+	}
 
-func (n *node) setDescription(path []identifier, v string) {
-	if len(path) > 0 {
-		for i := range n.children {
-			if n.children[i].id != path[0] {
-				continue
-			}
+	n := t.root.walk(ids)
+	if n.description != v {
+		n.description = v
+		n.descriptionLabels = labels
+		t.updateSeries(n)
+	}
 
-			// Found
-			n.children[i].setDescription(path[1:], v)
+	return nil
+}
 
-			return
-		}
+// insert stores v at path and returns the series of the leaf
+func (t *tree) insert(path string, v value) (*series, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
 
-		// Not Found
-		newChild := newNode(path[0])
-		newChild.setDescription(path[1:], v)
-		n.children = append(n.children, *newChild)
-		return
+	ids, cached, err := t.resolvePath(path)
+	if err != nil {
+		return nil, err
 	}
 
-	if n.description != v {
-		n.description = v
-		n.clearDesc()
+	if t.root == nil {
+		t.root = newNode(t.idCache.intern("", fmt.Sprintf("device=%s", t.devName)))
 	}
 
-}
+	if t.limits != nil {
+		err := t.limits.admit(t.root, ids, true)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-func (n *node) clearDesc() {
-	n.desc = nil
-	for i := range n.children {
-		n.children[i].clearDesc()
+	if !cached {
+		ids = t.cacheIdentifiers(path, ids)
 	}
-}
 
-func (n *node) descLabels() []string {
-	parts := strings.Split(n.description, ",")
-	for _, p := range parts {
-		keyValue := strings.Split(p, "=")
-		if len(keyValue) != 2 {
-			return []string{}
+	leaf := t.root.walk(ids)
+	counterPath := ""
+	if leaf.series == nil {
+		leaf.real = true
+		leaf.series = &series{
+			meta: t.seriesMeta(leaf),
 		}
 
-		if !descLabelRegexp.Match([]byte(keyValue[0])) {
-			return []string{}
+		if leaf.series.meta.counter != nil && t.counterStates != nil {
+			counterPath = identifiersToPath(ids)
+			leaf.series.ctr = t.counterStates.adopt(counterPath)
 		}
+
+		// Elements are never modified once appended, so readers of a published slice are not affected by appends
+		t.series = append(t.series, leaf.series)
+		t.published.Store(t.series)
+	}
+
+	discontinuity := leaf.series.set(v)
+	if counterPath != "" && leaf.series.ctr != nil {
+		t.counterStates.set(counterPath, leaf.series.ctr)
+	}
+	if discontinuity != "" && t.discontinuity != nil {
+		t.discontinuity(discontinuity)
 	}
 
-	return parts
+	return leaf.series, nil
 }
 
-func (n *node) getMetrics(path string, res *metricSet, labels []label, descriptionLabels []label) {
-	if path == "" {
-		path = n.id.name
-	} else {
-		path = path + "/" + n.id.name
+// snapshot returns the series of the tree without taking the tree lock. The returned slice must not be modified.
+func (t *tree) snapshot() []*series {
+	s, _ := t.published.Load().([]*series)
+	return s
+}
+
+// seriesMeta computes name, labels and desc of the series of leaf n
+func (t *tree) seriesMeta(n *node) *seriesMeta {
+	depth := 0
+	for cur := n; cur != nil; cur = cur.parent {
+		depth++
 	}
 
-	if n.id.labels != "" {
-		newLabels := labelIdentifierToLabels(n.id)
-		mergedLabels := make([]label, len(labels)+len(newLabels))
-		for i, label := range labels {
-			mergedLabels[i] = label
+	ids := make([]*identifier, depth)
+	var descriptionLabels []label
+	descriptionFound := false
+	for cur := n; cur != nil; cur = cur.parent {
+		depth--
+		ids[depth] = cur.id
+
+		if !descriptionFound && cur.description != "" {
+			descriptionLabels = cur.descriptionLabels
+			descriptionFound = true
 		}
+	}
 
-		for i, label := range newLabels {
-			mergedLabels[len(labels)+i] = label
+	names := make([]string, 0, len(ids))
+	labels := make([]label, 0, len(ids)+len(descriptionLabels))
+	for i, id := range ids {
+		if i > 0 {
+			names = append(names, id.name)
 		}
 
-		labels = mergedLabels
+		labels = append(labels, id.keys...)
 	}
 
-	if n.description != "" {
-		descriptionLabels = labelStringToLabels(n.description)
-	}
+	labels = append(labels, descriptionLabels...)
+	name := strings.Join(names, "/")
 
-	if n.real {
-		m := metric{
-			name:   path,
-			value:  n.value,
-			labels: append(labels, descriptionLabels...),
-		}
+	meta := newSeriesMeta(metric{
+		name:   name,
+		labels: labels,
+		meta:   t.schema.Leaf("/" + name),
+	}, t.relabeler)
 
-		if n.desc == nil {
-			n.desc = m.describe()
-		}
+	if window, ok := t.aggregations.lookup("/" + name); ok {
+		meta.enableAggregation(window)
+	}
 
-		m.desc = n.desc
+	if rule := t.counters.lookup("/" + name); rule != nil {
+		meta.enableCounter(rule)
+	}
 
-		res.append(m)
+	return meta
+}
+
+// updateSeries recomputes the series of all leafs below n
+func (t *tree) updateSeries(n *node) {
+	if n.series != nil {
+		n.series.setMeta(t.seriesMeta(n))
 	}
 
-	for i := range n.children {
-		n.children[i].getMetrics(path, res, labels, descriptionLabels)
+	for _, c := range n.children {
+		t.updateSeries(c)
 	}
 }
 
-func (n *node) insert(path []identifier, v interface{}) {
-	if len(path) > 0 {
-		for i := range n.children {
-			if n.children[i].id != path[0] {
-				continue
-			}
+func (n *node) dump(level int) []string {
+	ret := make([]string, 0)
 
-			// Found
-			n.children[i].insert(path[1:], v)
+	ret = append(ret, "|\n")
+	v := value{}
+	if n.series != nil {
+		_, v = n.series.get()
+	}
 
-			return
-		}
+	ret = append(ret, fmt.Sprintf("%s[%s](%v) = %v\n", n.id.name, n.id.labels, n.description, v))
 
-		// Not Found
-		newChild := newNode(path[0])
-		newChild.insert(path[1:], v)
-		n.children = append(n.children, *newChild)
-		return
+	for _, c := range n.children {
+		ret = append(ret, c.dump(level+1)...)
 	}
 
-	n.real = true
-	n.value = v
+	for i := range ret {
+		for j := 0; j < level; j++ {
+			ret[i] = " " + ret[i]
+		}
+		ret[i] = "|" + ret[i]
+	}
+
+	return ret
 }
 
 func labelStringToLabels(input string) []label {
@@ -271,21 +355,28 @@ func labelStringToLabels(input string) []label {
 	return res
 }
 
-func labelIdentifierToLabels(id identifier) []label {
-	res := make([]label, 0, 10)
-	for _, labelStr := range strings.Split(id.labels, ",") {
-		kv := strings.Split(labelStr, "=")
-		if len(kv) != 2 {
-			continue
-		}
+// keyLabels returns the list keys labels (as formatted by ocpath.FormatKeys) of element name as labels.
+// Label names are prefixed with the element name.
+func keyLabels(name string, labels string) []label {
+	if labels == "" {
+		return nil
+	}
 
-		if !descLabelRegexp.Match([]byte(kv[0])) {
+	keys, err := ocpath.ParseKeys(labels)
+	if err != nil {
+		return nil
+	}
+
+	res := make([]label, 0, len(keys))
+	for _, k := range keys {
+		key := getKeyName(name, k.Name)
+		if key == "" {
 			continue
 		}
 
 		res = append(res, label{
-			key:   getKeyName(id.name, kv[0]),
-			value: labelValueReplacer.Replace(kv[1]),
+			key:   key,
+			value: k.Value,
 		})
 	}
 
@@ -294,108 +385,50 @@ func labelIdentifierToLabels(id identifier) []label {
 
 func getKeyName(idName string, key string) string {
 	if idName == "" {
-		return labelKeyReplacer.Replace(key)
+		return sanitizeLabelName(key)
 	}
 
-	return idName + "_" + labelKeyReplacer.Replace(key)
+	return sanitizeLabelName(idName + "_" + key)
 }
 
-func dropSlashPrefixSuffix(p string) []rune {
-	start := 0
-	end := len(p)
-
-	if strings.HasPrefix(p, "/") {
-		start = 1
-	}
-
-	if strings.HasSuffix(p, "/") {
-		end = len(p) - 1
-	}
-
-	return []rune(p)[start:end]
+// pathToIdentifiers returns the identifiers of path p. Unlike the identifiers of inserted paths they are not cached.
+func (t *tree) pathToIdentifiers(p string) ([]*identifier, error) {
+	ids, _, err := t.resolvePath(p)
+	return ids, err
 }
 
-func slashCount(runes []rune) int {
-	count := 0
-	for _, r := range runes {
-		if r == '/' {
-			count++
-		}
-	}
-
-	return count
-}
-
-func (t *tree) pathToIdentifiers(p string) []identifier {
+// resolvePath returns the identifiers of path p and whether they have been cached. Identifiers of uncached paths
+// are only interned if they already exist, so paths refused by the limits do not grow the cache. See cacheIdentifiers.
+func (t *tree) resolvePath(p string) ([]*identifier, bool, error) {
 	ids := t.idCache.lookup(p)
 	if ids != nil {
-		return ids
+		return ids, true, nil
 	}
 
-	runes := dropSlashPrefixSuffix(p)
-	res := make([]identifier, 0, slashCount(runes))
-
-	bracesLevel := 0
-	tmp := make([]rune, 0, 256)
-	for i := 0; i < len(runes); i++ {
-		if runes[i] == '[' {
-			bracesLevel++
-		}
-
-		if bracesLevel > 0 {
-			if runes[i] == ']' {
-				bracesLevel--
-			}
-		}
-
-		if runes[i] == '/' {
-			if bracesLevel == 0 {
-				res = append(res, pathElementToIdentifier(tmp))
-				tmp = tmp[:0]
-				continue
-			}
-		}
-
-		tmp = append(tmp, runes[i])
+	elements, err := ocpath.Parse(p)
+	if err != nil {
+		return nil, false, err
 	}
 
-	if len(tmp) > 0 {
-		res = append(res, pathElementToIdentifier(tmp))
+	res := make([]*identifier, len(elements))
+	for i, e := range elements {
+		labels := ocpath.FormatKeys(e.Keys)
+		res[i] = t.idCache.get(e.Name, labels)
+		if res[i] == nil {
+			res[i] = newIdentifier(e.Name, labels)
+		}
 	}
 
-	t.idCache.set(p, res)
-	return res
+	return res, false, nil
 }
 
-func pathElementToIdentifier(data []rune) identifier {
-	key := make([]rune, 0, 25)
-	labelsString := ""
-	withinAngledBraces := false
-	tmp := make([]rune, 0, 25)
-
-	for i := 0; i < len(data); i++ {
-		if !withinAngledBraces {
-			if data[i] == '[' {
-				withinAngledBraces = true
-				continue
-			}
-
-			key = append(key, data[i])
-			continue
-		}
-
-		if data[i] == ']' {
-			labelsString = string(tmp)
-			withinAngledBraces = false
-			tmp = make([]rune, 0)
-			continue
-		}
-
-		tmp = append(tmp, data[i])
+// cacheIdentifiers interns the identifiers of path p and caches them
+func (t *tree) cacheIdentifiers(p string, ids []*identifier) []*identifier {
+	res := make([]*identifier, len(ids))
+	for i, id := range ids {
+		res[i] = t.idCache.intern(id.name, id.labels)
 	}
 
-	return identifier{
-		name:   string(key),
-		labels: labelsString,
-	}
+	t.idCache.set(p, res)
+	return res
 }
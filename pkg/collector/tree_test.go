@@ -1,30 +1,14 @@
 package collector
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestSlashCount(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected int
-	}{
-		{
-			name:     "Test #1",
-			input:    "/interfaces/foo/bar/",
-			expected: 4,
-		},
-	}
-
-	for _, test := range tests {
-		x := slashCount([]rune(test.input))
-		assert.Equal(t, test.expected, x, test.name)
-	}
-}
-
 func TestGetMetrics(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -48,7 +32,7 @@ func TestGetMetrics(t *testing.T) {
 			expected: []metric{
 				{
 					name:  "interfaces",
-					value: float64(100),
+					value: doubleValue(100),
 					labels: []label{
 						{
 							key:   "device",
@@ -72,7 +56,7 @@ func TestGetMetrics(t *testing.T) {
 			expected: []metric{
 				{
 					name:  "interfaces/bgp/something",
-					value: float64(200),
+					value: doubleValue(200),
 					labels: []label{
 						{
 							key:   "device",
@@ -108,7 +92,7 @@ func TestGetMetrics(t *testing.T) {
 			expected: []metric{
 				{
 					name:  "interfaces/bgp/something",
-					value: float64(200),
+					value: doubleValue(200),
 					labels: []label{
 						{
 							key:   "device",
@@ -126,7 +110,7 @@ func TestGetMetrics(t *testing.T) {
 				},
 				{
 					name:  "interfaces/bgp/something",
-					value: float64(300),
+					value: doubleValue(300),
 					labels: []label{
 						{
 							key:   "device",
@@ -149,14 +133,21 @@ func TestGetMetrics(t *testing.T) {
 	for _, test := range tests {
 		tr := newTree("test")
 		for _, input := range test.input {
-			tr.insert(input.path, input.value)
+			tr.insert(input.path, doubleValue(input.value))
 		}
 
 		for i := range test.expected {
 			test.expected[i].desc = test.expected[i].describe()
 		}
 
-		m := tr.getMetrics()
+		m := make([]metric, 0)
+		for _, sr := range tr.snapshot() {
+			meta, v := sr.get()
+			x := meta.metric
+			x.value = v
+			m = append(m, x)
+		}
+
 		assert.Equal(t, test.expected, m, test.name)
 	}
 }
@@ -165,83 +156,73 @@ func TestPathToIdentifiers(t *testing.T) {
 	tests := []struct {
 		name     string
 		input    string
-		expected []identifier
+		expected []*identifier
 	}{
 		{
 			name:  "Test #1",
 			input: "/interfaces/interface[name='xe-0/0/0']/pkts/",
-			expected: []identifier{
-				{
-					name: "interfaces",
-				},
-				{
-					name:   "interface",
-					labels: "name='xe-0/0/0'",
-				},
-				{
-					name: "pkts",
-				},
+			expected: []*identifier{
+				newIdentifier("interfaces", ""),
+				newIdentifier("interface", "name='xe-0/0/0'"),
+				newIdentifier("pkts", ""),
 			},
 		},
 		{
 			name:  "Test #2",
 			input: "/interfaces/interface[name='xe-0/0/0']/pkts/state[with='label']",
-			expected: []identifier{
-				{
-					name: "interfaces",
-				},
-				{
-					name:   "interface",
-					labels: "name='xe-0/0/0'",
-				},
-				{
-					name: "pkts",
-				},
-				{
-					name:   "state",
-					labels: "with='label'",
-				},
+			expected: []*identifier{
+				newIdentifier("interfaces", ""),
+				newIdentifier("interface", "name='xe-0/0/0'"),
+				newIdentifier("pkts", ""),
+				newIdentifier("state", "with='label'"),
+			},
+		},
+		{
+			name:  "Multiple keys",
+			input: "/interfaces/interface[name=xe-0/0/0][unit=0]/state",
+			expected: []*identifier{
+				newIdentifier("interfaces", ""),
+				newIdentifier("interface", "name='xe-0/0/0' and unit='0'"),
+				newIdentifier("state", ""),
 			},
 		},
 	}
 
 	for _, test := range tests {
 		tr := newTree("test")
-		ids := tr.pathToIdentifiers(test.input)
+		ids, err := tr.pathToIdentifiers(test.input)
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
 		assert.Equal(t, test.expected, ids, test.name)
 	}
 }
 
-func TestPathElementToIdentifier(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected identifier
-	}{
-		{
-			name:  "Test #1",
-			input: "interfaces",
-			expected: identifier{
-				name: "interfaces",
-			},
-		},
-		{
-			name:  "Test #1",
-			input: "interfaces[name='xe-0/0/0']",
-			expected: identifier{
-				name:   "interfaces",
-				labels: "name='xe-0/0/0'",
-			},
-		},
+func TestPathToIdentifiersInterned(t *testing.T) {
+	tr := newTree("test")
+	_, err := tr.insert("/interfaces/interface[name='xe-0/0/0']/state/in-pkts", uintValue(1))
+	if err != nil {
+		t.Fatalf("Unexpected failure: %v", err)
 	}
 
-	for _, test := range tests {
-		id := pathElementToIdentifier([]rune(test.input))
-		assert.Equal(t, test.expected, id, test.name)
+	a, err := tr.pathToIdentifiers("/interfaces/interface[name='xe-0/0/0']/state/in-pkts")
+	if err != nil {
+		t.Fatalf("Unexpected failure: %v", err)
+	}
+
+	b, err := tr.pathToIdentifiers("/interfaces/interface[name=xe-0/0/0]/state/out-pkts")
+	if err != nil {
+		t.Fatalf("Unexpected failure: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, a[i] == b[i], "identifier %d not interned", i)
 	}
 }
 
-func TestLabelIdentifierToLabels(t *testing.T) {
+func TestIdentifierKeyLabels(t *testing.T) {
 	tests := []struct {
 		name     string
 		id       identifier
@@ -251,7 +232,7 @@ func TestLabelIdentifierToLabels(t *testing.T) {
 			name: "With Name",
 			id: identifier{
 				name:   "foo",
-				labels: "a=123,b=456,c=1312",
+				labels: "a='123' and b='456' and c='1312'",
 			},
 			expected: []label{
 				{
@@ -289,18 +270,109 @@ func TestLabelIdentifierToLabels(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Invalid label name characters",
+			id: identifier{
+				name:   "network-instance",
+				labels: "oc-ni:name='default'",
+			},
+			expected: []label{
+				{
+					key:   "network_instance_oc_ni_name",
+					value: "default",
+				},
+			},
+		},
 		{
 			name: "Empty input",
 			id: identifier{
 				name:   "",
 				labels: "",
 			},
-			expected: []label{},
+			expected: nil,
 		},
 	}
 
 	for _, test := range tests {
-		res := labelIdentifierToLabels(test.id)
+		res := keyLabels(test.id.name, test.id.labels)
 		assert.Equal(t, test.expected, res, test.name)
 	}
 }
+
+func TestTreeSeriesUpdate(t *testing.T) {
+	tr := newTree("test")
+	tr.insert("/interfaces/interface[name='xe-0/0/0']/state/pkts", doubleValue(1))
+	tr.setDescription("/interfaces/interface[name='xe-0/0/0']/", "customer=foo", []label{{key: "customer", value: "foo"}})
+	tr.insert("/interfaces/interface[name='xe-0/0/0']/state/pkts", doubleValue(2))
+
+	s := tr.snapshot()
+	assert.Equal(t, 1, len(s))
+	meta, v := s[0].get()
+	assert.Equal(t, doubleValue(2), v)
+	assert.Equal(t, []string{"test", "xe-0/0/0", "foo"}, meta.metric.labelValues())
+
+	tr.setDescription("/interfaces/interface[name='xe-0/0/0']/", "customer=bar", []label{{key: "customer", value: "bar"}})
+
+	meta, _ = tr.snapshot()[0].get()
+	assert.Equal(t, []string{"test", "xe-0/0/0", "bar"}, meta.metric.labelValues())
+	assert.Equal(t, []string{"device", "interface_name", "customer"}, meta.metric.labelKeys())
+}
+
+const (
+	benchmarkInterfaces = 1000
+	benchmarkCounters   = 100
+)
+
+// benchmarkTree returns a tree of 100k leafs and their paths
+func benchmarkTree(b *testing.B) (*tree, []string) {
+	tr := newTree("test")
+	paths := make([]string, 0, benchmarkInterfaces*benchmarkCounters)
+	for i := 0; i < benchmarkInterfaces; i++ {
+		for j := 0; j < benchmarkCounters; j++ {
+			paths = append(paths, fmt.Sprintf("/interfaces/interface[name='xe-0/0/%d']/subinterfaces/subinterface[index='0']/state/counters/counter-%d", i, j))
+		}
+	}
+
+	for i, p := range paths {
+		_, err := tr.insert(p, doubleValue(float64(i)))
+		if err != nil {
+			b.Fatalf("Unexpected failure: %v", err)
+		}
+	}
+
+	return tr, paths
+}
+
+func BenchmarkTreeInsert(b *testing.B) {
+	tr, paths := benchmarkTree(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tr.insert(paths[i%len(paths)], doubleValue(float64(i)))
+	}
+}
+
+func BenchmarkTargetCollect(b *testing.B) {
+	tr, _ := benchmarkTree(b)
+	ta := &Target{
+		devName: "test",
+		metrics: tr,
+	}
+
+	ch := make(chan prometheus.Metric, 1024)
+	go func() {
+		for range ch {
+		}
+	}()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		ta.collect(ch, &wg, false)
+	}
+
+	close(ch)
+}
@@ -0,0 +1,110 @@
+package collector
+
+import (
+	"fmt"
+	"strconv"
+
+	pb "github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/telemetry"
+)
+
+// valueKind is the type of a value as received from the device
+type valueKind uint8
+
+const (
+	valueNone valueKind = iota
+	valueDouble
+	valueInt
+	valueUint
+	valueSint
+	valueBool
+	valueString
+	valueBytes
+)
+
+// value is a compact representation of a received value. Numeric values are stored as float64,
+// string and bytes values as string. Unsigned integers are kept exactly in exact as well.
+type value struct {
+	kind  valueKind
+	num   float64
+	str   string
+	exact uint64
+}
+
+// newValue converts a value received from a device. It returns false for unknown value types.
+func newValue(v interface{}) (value, bool) {
+	switch x := v.(type) {
+	case nil:
+		return value{}, true
+	case *pb.KeyValue_DoubleValue:
+		return value{kind: valueDouble, num: x.DoubleValue}, true
+	case *pb.KeyValue_IntValue:
+		return value{kind: valueInt, num: float64(x.IntValue)}, true
+	case *pb.KeyValue_UintValue:
+		return uintValue(x.UintValue), true
+	case *pb.KeyValue_SintValue:
+		return value{kind: valueSint, num: float64(x.SintValue)}, true
+	case *pb.KeyValue_BoolValue:
+		if x.BoolValue {
+			return value{kind: valueBool, num: 1}, true
+		}
+
+		return value{kind: valueBool}, true
+	case *pb.KeyValue_StrValue:
+		return stringValue(x.StrValue), true
+	case *pb.KeyValue_BytesValue:
+		return value{kind: valueBytes, str: string(x.BytesValue)}, true
+	}
+
+	return value{}, false
+}
+
+// uintValue creates an unsigned integer value
+func uintValue(u uint64) value {
+	return value{kind: valueUint, num: float64(u), exact: u}
+}
+
+// doubleValue creates a numeric value
+func doubleValue(f float64) value {
+	return value{kind: valueDouble, num: f}
+}
+
+// stringValue creates a string value
+func stringValue(s string) value {
+	return value{kind: valueString, str: s}
+}
+
+// numeric reports whether v holds a number
+func (v value) numeric() bool {
+	switch v.kind {
+	case valueNone, valueString, valueBytes:
+		return false
+	}
+
+	return true
+}
+
+// counter returns v as unsigned integer. It returns false if v is not a non-negative number.
+func (v value) counter() (uint64, bool) {
+	if v.kind == valueUint {
+		return v.exact, true
+	}
+
+	if !v.numeric() || v.num < 0 {
+		return 0, false
+	}
+
+	return uint64(v.num), true
+}
+
+func (v value) String() string {
+	switch v.kind {
+	case valueNone:
+		return "<nil>"
+	case valueString:
+		return strconv.Quote(v.str)
+	case valueBytes:
+		return fmt.Sprintf("%x", v.str)
+	}
+
+	return strconv.FormatFloat(v.num, 'g', -1, 64)
+}
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/pathmatch"
+)
+
+// Aggregation enables the aggregation of the values received between scrapes for matching paths.
+// Aggregated series are exposed with the suffixes _min, _max and _avg.
+type Aggregation struct {
+	// Path is the path (or pattern) of the leaf, e.g. /junos/system/linecard/cpu/**
+	Path string `yaml:"path"`
+	// WindowS is the length of the sliding window in seconds. If 0 all values received since the last scrape are aggregated.
+	WindowS uint64 `yaml:"window_s"`
+}
+
+func (a *Aggregation) validate() error {
+	_, err := pathmatch.Compile(a.Path)
+	if err != nil {
+		return fmt.Errorf("invalid aggregation path: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/pathmatch"
+)
+
+// Bytes value decoder types
+const (
+	BytesValueUint  = "uint"
+	BytesValueInt   = "int"
+	BytesValueFloat = "float"
+	BytesValueSkip  = "skip"
+)
+
+// BytesValueDecoder describes how bytes values of matching paths are converted to numbers.
+// Bytes values of paths without matching decoder are skipped.
+type BytesValueDecoder struct {
+	// Path is the path (or pattern) of the leaf, e.g. /junos/system/linecard/packet/usage/*
+	Path string `yaml:"path"`
+	// Type is one of uint (big-endian unsigned integer), int (big-endian two's complement integer),
+	// float (IEEE 754, 4 or 8 bytes) or skip
+	Type string `yaml:"type"`
+}
+
+func (d *BytesValueDecoder) validate() error {
+	_, err := pathmatch.Compile(d.Path)
+	if err != nil {
+		return fmt.Errorf("invalid bytes value decoder path: %v", err)
+	}
+
+	switch d.Type {
+	case BytesValueUint, BytesValueInt, BytesValueFloat, BytesValueSkip:
+	default:
+		return fmt.Errorf("unknown bytes value decoder type %q", d.Type)
+	}
+
+	return nil
+}
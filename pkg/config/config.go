@@ -1,10 +1,13 @@
 package config
 
 import (
+	"fmt"
 	"io"
 	"io/ioutil"
+	"strings"
 
-	yaml "gopkg.in/yaml.v2"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/ocpath"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/pathmatch"
 )
 
 const (
@@ -19,20 +22,39 @@ const (
 
 // Config is the configuration of the prom-telemetry-gw
 type Config struct {
-	ListenAddress      string                    `yaml:"listen_address"`
-	MetricsPath        string                    `yaml:"metrics_path"`
-	Targets            []*Target                 `yaml:"targets"`
-	StringValueMapping map[string]map[string]int `yaml:"string_value_mapping"`
-	Version            string
+	ListenAddress                    string               `yaml:"listen_address"`
+	MetricsPath                      string               `yaml:"metrics_path"`
+	Targets                          []*Target            `yaml:"targets"`
+	StringValueMapping               StringValueMapping   `yaml:"string_value_mapping"`
+	DisableBuiltinStringValueMapping bool                 `yaml:"disable_builtin_string_value_mapping"`
+	YANGDir                          string               `yaml:"yang_dir"`
+	MetricRelabelConfigs             []*RelabelConfig     `yaml:"metric_relabel_configs"`
+	DescriptionParsers               []*DescriptionParser `yaml:"description_parsers"`
+	BytesValueDecoders               []*BytesValueDecoder `yaml:"bytes_value_decoders"`
+	DerivedMetrics                   []*DerivedMetric     `yaml:"derived_metrics"`
+	Aggregations                     []*Aggregation       `yaml:"aggregations"`
+	Counters                         []*Counter           `yaml:"counters"`
+	RemoteWrite                      *RemoteWrite         `yaml:"remote_write"`
+	InfluxDB                         *InfluxDB            `yaml:"influxdb"`
+	OTLP                             *OTLP                `yaml:"otlp"`
+	Kafka                            *Kafka               `yaml:"kafka"`
+	Limits                           *Limits              `yaml:"limits"`
+	IncludeFiles                     []string             `yaml:"include_files"`
+	Version                          string
 }
 
 // Target represents a monitored system
 type Target struct {
-	Hostname   string  `yaml:"hostname"`
-	Port       uint16  `yaml:"port"`
-	KeepaliveS uint16  `yaml:"keepalive_s"`
-	TimeoutS   uint16  `yaml:"timeout_s"`
-	Paths      []*Path `yaml:"paths"`
+	Hostname             string           `yaml:"hostname"`
+	Port                 uint16           `yaml:"port"`
+	KeepaliveS           uint16           `yaml:"keepalive_s"`
+	TimeoutS             uint16           `yaml:"timeout_s"`
+	Paths                []*Path          `yaml:"paths"`
+	MetricRelabelConfigs []*RelabelConfig `yaml:"metric_relabel_configs"`
+	Limits               *Limits          `yaml:"limits"`
+	Include              []string         `yaml:"include"`
+	Exclude              []string         `yaml:"exclude"`
+	Record               *Record          `yaml:"record"`
 }
 
 // Path represents a resource identifier, e.g. /junos/system/linecard/cpu/memory/
@@ -40,31 +62,229 @@ type Target struct {
 // for more examples.
 type Path struct {
 	Path                string `yaml:"path"`
+	Filter              string `yaml:"filter"`
 	SuppressUnchanged   *bool  `yaml:"suppress_unchanged"`
 	MaxSilentIntervalMS uint64 `yaml:"max_silent_interval_ms"`
 	SampleFrequencyMS   uint64 `yaml:"sample_frequency_ms"`
 }
 
+// NewPath creates a subscription of path with default settings
+func NewPath(path string) *Path {
+	p := &Path{
+		Path: path,
+	}
+
+	p.loadDefaults()
+	return p
+}
+
+func (p *Path) loadDefaults() {
+	if p.SampleFrequencyMS == 0 {
+		p.SampleFrequencyMS = defaultSampleFrequencyMS
+	}
+
+	if p.MaxSilentIntervalMS == 0 {
+		p.MaxSilentIntervalMS = defaultMaxSilentIntervalMS
+	}
+
+	if p.SuppressUnchanged == nil {
+		x := defaultSuppressUnchanged
+		p.SuppressUnchanged = &x
+	}
+}
+
 // New creates a new empty config object
 func New() *Config {
 	return &Config{}
 }
 
-// Load loads a config from reader
+// Load loads a config from reader. Unknown fields are rejected.
+// Included files are relative to the working directory.
 func Load(reader io.Reader) (*Config, error) {
 	b, err := ioutil.ReadAll(reader)
 	if err != nil {
 		return nil, err
 	}
 
-	c := New()
-	err = yaml.Unmarshal(b, c)
+	ld := newLoader()
+	err = ld.load("", ".", b)
 	if err != nil {
 		return nil, err
 	}
 
-	c.LoadDefaults()
-	return c, nil
+	return ld.finish()
+}
+
+func (c *Config) validate(l *lines) error {
+	err := c.StringValueMapping.validate()
+	if err != nil {
+		return l.wrap(err, "string_value_mapping")
+	}
+
+	err = validateRelabelConfigs(c.MetricRelabelConfigs)
+	if err != nil {
+		return l.wrap(err, "metric_relabel_configs")
+	}
+
+	for i, dp := range c.DescriptionParsers {
+		err = dp.validate()
+		if err != nil {
+			return l.wrap(err, "description_parsers", i)
+		}
+	}
+
+	for i, d := range c.BytesValueDecoders {
+		err = d.validate()
+		if err != nil {
+			return l.wrap(err, "bytes_value_decoders", i)
+		}
+	}
+
+	for i, d := range c.DerivedMetrics {
+		err = d.validate()
+		if err != nil {
+			return l.wrap(err, "derived_metrics", i)
+		}
+	}
+
+	for i, a := range c.Aggregations {
+		err = a.validate()
+		if err != nil {
+			return l.wrap(err, "aggregations", i)
+		}
+	}
+
+	for i, ctr := range c.Counters {
+		err = ctr.validate()
+		if err != nil {
+			return l.wrap(err, "counters", i)
+		}
+	}
+
+	if c.RemoteWrite != nil {
+		err = c.RemoteWrite.validate()
+		if err != nil {
+			return l.wrap(err, "remote_write")
+		}
+	}
+
+	if c.InfluxDB != nil {
+		err = c.InfluxDB.validate()
+		if err != nil {
+			return l.wrap(err, "influxdb")
+		}
+	}
+
+	if c.OTLP != nil {
+		err = c.OTLP.validate()
+		if err != nil {
+			return l.wrap(err, "otlp")
+		}
+	}
+
+	if c.Kafka != nil {
+		err = c.Kafka.validate()
+		if err != nil {
+			return l.wrap(err, "kafka")
+		}
+	}
+
+	hostnames := make(map[string]struct{}, len(c.Targets))
+	for i, t := range c.Targets {
+		if _, ok := hostnames[t.Hostname]; ok {
+			return l.wrap(fmt.Errorf("duplicate target %s", t.Hostname), "targets", i, "hostname")
+		}
+
+		hostnames[t.Hostname] = struct{}{}
+
+		err = t.validate(l, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validate validates target i of the config
+func (t *Target) validate(l *lines, i int) error {
+	if t.Hostname == "" {
+		return l.wrap(fmt.Errorf("target without hostname"), "targets", i)
+	}
+
+	if t.Port == 0 {
+		return l.wrap(fmt.Errorf("target %s: port must not be 0", t.Hostname), "targets", i, "port")
+	}
+
+	if len(t.Paths) == 0 {
+		return l.wrap(fmt.Errorf("target %s: no paths", t.Hostname), "targets", i, "paths")
+	}
+
+	for j, p := range t.Paths {
+		err := p.validate()
+		if err != nil {
+			return l.wrap(fmt.Errorf("target %s: %v", t.Hostname, err), "targets", i, "paths", j)
+		}
+	}
+
+	err := validateRelabelConfigs(t.MetricRelabelConfigs)
+	if err != nil {
+		return l.wrap(fmt.Errorf("target %s: %v", t.Hostname, err), "targets", i, "metric_relabel_configs")
+	}
+
+	err = validateLeafPatterns(t.Include, t.Exclude)
+	if err != nil {
+		return l.wrap(fmt.Errorf("target %s: %v", t.Hostname, err), "targets", i)
+	}
+
+	if t.Record != nil {
+		err = t.Record.validate()
+		if err != nil {
+			return l.wrap(fmt.Errorf("target %s: %v", t.Hostname, err), "targets", i, "record")
+		}
+	}
+
+	return nil
+}
+
+func (p *Path) validate() error {
+	if p.Path == "" {
+		return fmt.Errorf("empty path")
+	}
+
+	if !strings.HasPrefix(p.Path, "/") {
+		return fmt.Errorf("path %q does not start with /", p.Path)
+	}
+
+	_, err := ocpath.Parse(p.Path)
+	if err != nil {
+		return err
+	}
+
+	// The device sends unchanged values at least every max silent interval
+	if *p.SuppressUnchanged && p.MaxSilentIntervalMS < p.SampleFrequencyMS {
+		return fmt.Errorf("path %s: max_silent_interval_ms %d is less than sample_frequency_ms %d", p.Path, p.MaxSilentIntervalMS, p.SampleFrequencyMS)
+	}
+
+	return nil
+}
+
+func validateLeafPatterns(include []string, exclude []string) error {
+	for _, p := range include {
+		_, err := pathmatch.Compile(p)
+		if err != nil {
+			return fmt.Errorf("invalid include: %v", err)
+		}
+	}
+
+	for _, p := range exclude {
+		_, err := pathmatch.Compile(p)
+		if err != nil {
+			return fmt.Errorf("invalid exclude: %v", err)
+		}
+	}
+
+	return nil
 }
 
 // LoadDefaults loads default settings for config c
@@ -77,6 +297,34 @@ func (c *Config) LoadDefaults() {
 		c.MetricsPath = defaultMetricsPath
 	}
 
+	for _, dp := range c.DescriptionParsers {
+		dp.loadDefaults()
+	}
+
+	for _, d := range c.DerivedMetrics {
+		d.loadDefaults()
+	}
+
+	for _, ctr := range c.Counters {
+		ctr.loadDefaults()
+	}
+
+	if c.RemoteWrite != nil {
+		c.RemoteWrite.loadDefaults()
+	}
+
+	if c.InfluxDB != nil {
+		c.InfluxDB.loadDefaults()
+	}
+
+	if c.OTLP != nil {
+		c.OTLP.loadDefaults()
+	}
+
+	if c.Kafka != nil {
+		c.Kafka.loadDefaults()
+	}
+
 	for i := range c.Targets {
 		if c.Targets[i].KeepaliveS == 0 {
 			c.Targets[i].KeepaliveS = defaultKeepaliveSeconds
@@ -86,19 +334,20 @@ func (c *Config) LoadDefaults() {
 			c.Targets[i].TimeoutS = defaultTimeoutFactor * c.Targets[i].KeepaliveS
 		}
 
-		for j := range c.Targets[i].Paths {
-			if c.Targets[i].Paths[j].SampleFrequencyMS == 0 {
-				c.Targets[i].Paths[j].SampleFrequencyMS = defaultSampleFrequencyMS
-			}
+		if c.Targets[i].Record != nil {
+			c.Targets[i].Record.loadDefaults()
+		}
 
-			if c.Targets[i].Paths[j].MaxSilentIntervalMS == 0 {
-				c.Targets[i].Paths[j].MaxSilentIntervalMS = defaultMaxSilentIntervalMS
+		if c.Limits != nil {
+			if c.Targets[i].Limits == nil {
+				c.Targets[i].Limits = &Limits{}
 			}
 
-			if c.Targets[i].Paths[j].SuppressUnchanged == nil {
-				x := defaultSuppressUnchanged
-				c.Targets[i].Paths[j].SuppressUnchanged = &x
-			}
+			c.Targets[i].Limits.inherit(c.Limits)
+		}
+
+		for _, p := range c.Targets[i].Paths {
+			p.loadDefaults()
 		}
 	}
 }
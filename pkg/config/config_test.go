@@ -2,6 +2,9 @@ package config
 
 import (
 	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -11,6 +14,14 @@ func boolAddr(v bool) *bool {
 	return &v
 }
 
+func floatAddr(v float64) *float64 {
+	return &v
+}
+
+func intAddr(v int) *int {
+	return &v
+}
+
 func TestLoad(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -54,6 +65,54 @@ targets:
 				},
 			},
 		},
+		{
+			name: "Filter, include and exclude",
+			input: `
+targets:
+  - hostname: 203.0.113.1
+    port: 50051
+    include:
+    - /interfaces/**
+    exclude:
+    - /interfaces/interface/state/counters/*-pkts
+    paths:
+    - path: /interfaces/
+      filter: "^xe-"
+`,
+			expected: &Config{
+				ListenAddress: defaultListenAddress,
+				MetricsPath:   defaultMetricsPath,
+				Targets: []*Target{
+					{
+						Hostname:   "203.0.113.1",
+						Port:       50051,
+						KeepaliveS: defaultKeepaliveSeconds,
+						TimeoutS:   defaultTimeoutFactor * defaultKeepaliveSeconds,
+						Include:    []string{"/interfaces/**"},
+						Exclude:    []string{"/interfaces/interface/state/counters/*-pkts"},
+						Paths: []*Path{
+							{
+								Path:                "/interfaces/",
+								Filter:              "^xe-",
+								SuppressUnchanged:   boolAddr(defaultSuppressUnchanged),
+								SampleFrequencyMS:   defaultSampleFrequencyMS,
+								MaxSilentIntervalMS: defaultMaxSilentIntervalMS,
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "Invalid exclude",
+			input: `
+targets:
+  - hostname: 203.0.113.1
+    exclude:
+    - "~(foo"
+`,
+			expected: nil,
+		},
 	}
 	for _, test := range tests {
 		cfg, _ := Load(bytes.NewReader([]byte(test.input)))
@@ -61,6 +120,108 @@ targets:
 	}
 }
 
+func TestLoadStringValueMapping(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected StringValueMapping
+		wantFail bool
+	}{
+		{
+			name: "Short form",
+			input: `
+string_value_mapping:
+  /interfaces/interface/state/oper-status:
+    DOWN: 0
+    UP: 1
+`,
+			expected: StringValueMapping{
+				"/interfaces/interface/state/oper-status": {
+					Values: map[string]float64{
+						"DOWN": 0,
+						"UP":   1,
+					},
+				},
+			},
+		},
+		{
+			name: "Long form",
+			input: `
+string_value_mapping:
+  /interfaces/**/oper-status:
+    values:
+      DOWN: 0
+      UP: 1.5
+    default: -1
+    case_insensitive: true
+`,
+			expected: StringValueMapping{
+				"/interfaces/**/oper-status": {
+					Values: map[string]float64{
+						"DOWN": 0,
+						"UP":   1.5,
+					},
+					Default:         floatAddr(-1),
+					CaseInsensitive: true,
+				},
+			},
+		},
+		{
+			name: "Long form without values",
+			input: `
+string_value_mapping:
+  /interfaces/**/oper-status:
+    default: 0
+    case_insensitive: true
+`,
+			expected: StringValueMapping{
+				"/interfaces/**/oper-status": {
+					Default:         floatAddr(0),
+					CaseInsensitive: true,
+				},
+			},
+		},
+		{
+			name: "Long form with unknown key",
+			input: `
+string_value_mapping:
+  /interfaces/**/oper-status:
+    default: 0
+    UP: 1
+`,
+			wantFail: true,
+		},
+		{
+			name: "Invalid regular expression",
+			input: `
+string_value_mapping:
+  ~/interfaces/(:
+    UP: 1
+`,
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		cfg, err := Load(bytes.NewReader([]byte(test.input)))
+		if test.wantFail {
+			if err != nil {
+				continue
+			}
+
+			t.Errorf("Unexpected success for test %q", test.name)
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		assert.Equal(t, test.expected, cfg.StringValueMapping, test.name)
+	}
+}
+
 func TestLoadDefaults(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -111,3 +272,1361 @@ func TestLoadDefaults(t *testing.T) {
 		assert.Equal(t, test.expected, test.cfg, test.name)
 	}
 }
+
+func TestBuiltinStringValueMapping(t *testing.T) {
+	svm := BuiltinStringValueMapping()
+	assert.NoError(t, svm.validate())
+	assert.Equal(t, float64(7), svm["/interfaces/**/state/oper-status"].Values["LOWER_LAYER_DOWN"])
+}
+
+func TestLoadRelabelConfigs(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []*RelabelConfig
+		wantFail bool
+	}{
+		{
+			name: "Defaults",
+			input: `
+metric_relabel_configs:
+- source_labels: [__name__]
+  regex: interfaces_interface_(.*)
+  target_label: __name__
+- action: labeldrop
+  regex: some_label
+`,
+			expected: []*RelabelConfig{
+				{
+					SourceLabels: []string{"__name__"},
+					Separator:    ";",
+					Regex:        "interfaces_interface_(.*)",
+					TargetLabel:  "__name__",
+					Replacement:  "$1",
+					Action:       RelabelReplace,
+				},
+				{
+					Separator:   ";",
+					Regex:       "some_label",
+					Replacement: "$1",
+					Action:      RelabelLabelDrop,
+				},
+			},
+		},
+		{
+			name: "Unknown action",
+			input: `
+metric_relabel_configs:
+- action: foo
+`,
+			wantFail: true,
+		},
+		{
+			name: "Replace without target label",
+			input: `
+metric_relabel_configs:
+- source_labels: [__name__]
+`,
+			wantFail: true,
+		},
+		{
+			name: "Hashmod without modulus",
+			input: `
+targets:
+- hostname: 203.0.113.1
+  metric_relabel_configs:
+  - action: hashmod
+    target_label: foo
+`,
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		cfg, err := Load(bytes.NewReader([]byte(test.input)))
+		if test.wantFail {
+			if err != nil {
+				continue
+			}
+
+			t.Errorf("Unexpected success for test %q", test.name)
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		assert.Equal(t, test.expected, cfg.MetricRelabelConfigs, test.name)
+	}
+}
+
+func TestLoadDescriptionParsers(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []*DescriptionParser
+		wantFail bool
+	}{
+		{
+			name: "Defaults",
+			input: `
+description_parsers:
+- path: /interfaces/interface/state/description
+  labels: [customer]
+- path: /interfaces/**/description
+  type: regex
+  regex: CUST:(?P<customer>[^;]+)
+  fallback_label: description
+`,
+			expected: []*DescriptionParser{
+				{
+					Path:              "/interfaces/interface/state/description",
+					Type:              DescriptionParserKV,
+					PairSeparator:     ",",
+					KeyValueSeparator: "=",
+					Labels:            []string{"customer"},
+				},
+				{
+					Path:          "/interfaces/**/description",
+					Type:          DescriptionParserRegex,
+					Regex:         "CUST:(?P<customer>[^;]+)",
+					FallbackLabel: "description",
+				},
+			},
+		},
+		{
+			name: "Regex without named groups",
+			input: `
+description_parsers:
+- path: /interfaces/interface/state/description
+  type: regex
+  regex: CUST:([^;]+)
+`,
+			wantFail: true,
+		},
+		{
+			name: "Unknown type",
+			input: `
+description_parsers:
+- path: /interfaces/interface/state/description
+  type: xml
+`,
+			wantFail: true,
+		},
+		{
+			name: "Invalid fallback label",
+			input: `
+description_parsers:
+- path: /interfaces/interface/state/description
+  fallback_label: raw-description
+`,
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		cfg, err := Load(bytes.NewReader([]byte(test.input)))
+		if test.wantFail {
+			if err != nil {
+				continue
+			}
+
+			t.Errorf("Unexpected success for test %q", test.name)
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		assert.Equal(t, test.expected, cfg.DescriptionParsers, test.name)
+	}
+}
+
+func TestLoadLimits(t *testing.T) {
+	input := `
+limits:
+  max_nodes: 1000
+  max_series_per_metric: 100
+targets:
+- hostname: 203.0.113.1
+  port: 50051
+  paths:
+  - path: /interfaces/
+- hostname: 203.0.113.2
+  port: 50051
+  paths:
+  - path: /interfaces/
+  limits:
+    max_nodes: 10
+    max_label_values: 5
+- hostname: 203.0.113.3
+  port: 50051
+  paths:
+  - path: /interfaces/
+  limits:
+    max_nodes: 0
+`
+
+	cfg, err := Load(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("Unexpected failure: %v", err)
+	}
+
+	assert.Equal(t, &Limits{MaxNodes: intAddr(1000), MaxSeriesPerMetric: intAddr(100)}, cfg.Targets[0].Limits)
+	assert.Equal(t, &Limits{MaxNodes: intAddr(10), MaxSeriesPerMetric: intAddr(100), MaxLabelValues: intAddr(5)}, cfg.Targets[1].Limits)
+	// 0 overrides the global limit
+	assert.Equal(t, &Limits{MaxNodes: intAddr(0), MaxSeriesPerMetric: intAddr(100)}, cfg.Targets[2].Limits)
+}
+
+func TestLoadBytesValueDecoders(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []*BytesValueDecoder
+		wantFail bool
+	}{
+		{
+			name: "Valid",
+			input: `
+bytes_value_decoders:
+- path: /junos/system/linecard/packet/usage/**
+  type: uint
+- path: /components/component/state/temperature/instant
+  type: float
+`,
+			expected: []*BytesValueDecoder{
+				{
+					Path: "/junos/system/linecard/packet/usage/**",
+					Type: BytesValueUint,
+				},
+				{
+					Path: "/components/component/state/temperature/instant",
+					Type: BytesValueFloat,
+				},
+			},
+		},
+		{
+			name: "Unknown type",
+			input: `
+bytes_value_decoders:
+- path: /foo
+  type: string
+`,
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		cfg, err := Load(bytes.NewReader([]byte(test.input)))
+		if test.wantFail {
+			if err != nil {
+				continue
+			}
+
+			t.Errorf("Unexpected success for test %q", test.name)
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		assert.Equal(t, test.expected, cfg.BytesValueDecoders, test.name)
+	}
+}
+
+func TestLoadDerivedMetrics(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []*DerivedMetric
+		wantFail bool
+	}{
+		{
+			name: "Valid",
+			input: `
+derived_metrics:
+- name: interface_in_bits_total
+  type: counter
+  expr: /interfaces/interface/state/counters/in-octets * 8
+  labels:
+    source: derived
+- name: interface_in_errors_total
+  help: Input errors of all subinterfaces
+  type: counter
+  expr: sum by (device, interface_name) (/interfaces/interface/subinterfaces/subinterface/state/counters/in-errors)
+`,
+			expected: []*DerivedMetric{
+				{
+					Name:   "interface_in_bits_total",
+					Help:   "/interfaces/interface/state/counters/in-octets * 8",
+					Type:   DerivedMetricCounter,
+					Expr:   "/interfaces/interface/state/counters/in-octets * 8",
+					Labels: map[string]string{"source": "derived"},
+				},
+				{
+					Name: "interface_in_errors_total",
+					Help: "Input errors of all subinterfaces",
+					Type: DerivedMetricCounter,
+					Expr: "sum by (device, interface_name) (/interfaces/interface/subinterfaces/subinterface/state/counters/in-errors)",
+				},
+			},
+		},
+		{
+			name: "Invalid name",
+			input: `
+derived_metrics:
+- name: interface-utilization
+  expr: "1"
+`,
+			wantFail: true,
+		},
+		{
+			name: "Unknown type",
+			input: `
+derived_metrics:
+- name: foo
+  type: histogram
+  expr: "1"
+`,
+			wantFail: true,
+		},
+		{
+			name: "Invalid expression",
+			input: `
+derived_metrics:
+- name: foo
+  expr: /a/b *
+`,
+			wantFail: true,
+		},
+		{
+			name: "Invalid label name",
+			input: `
+derived_metrics:
+- name: foo
+  expr: "1"
+  labels:
+    foo-bar: x
+`,
+			wantFail: true,
+		},
+		{
+			name: "Device label",
+			input: `
+derived_metrics:
+- name: foo
+  expr: "1"
+  labels:
+    device: x
+`,
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		cfg, err := Load(bytes.NewReader([]byte(test.input)))
+		if test.wantFail {
+			if err != nil {
+				continue
+			}
+
+			t.Errorf("Unexpected success for test %q", test.name)
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		assert.Equal(t, test.expected, cfg.DerivedMetrics, test.name)
+	}
+}
+
+func TestLoadAggregations(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []*Aggregation
+		wantFail bool
+	}{
+		{
+			name: "Valid",
+			input: `
+aggregations:
+- path: /junos/system/linecard/cpu/**
+- path: /components/component/transceiver/**/input-power/instant
+  window_s: 30
+`,
+			expected: []*Aggregation{
+				{
+					Path: "/junos/system/linecard/cpu/**",
+				},
+				{
+					Path:    "/components/component/transceiver/**/input-power/instant",
+					WindowS: 30,
+				},
+			},
+		},
+		{
+			name: "Invalid path",
+			input: `
+aggregations:
+- path: ~/foo/(
+`,
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		cfg, err := Load(bytes.NewReader([]byte(test.input)))
+		if test.wantFail {
+			if err != nil {
+				continue
+			}
+
+			t.Errorf("Unexpected success for test %q", test.name)
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		assert.Equal(t, test.expected, cfg.Aggregations, test.name)
+	}
+}
+
+func TestLoadCounters(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []*Counter
+		wantFail bool
+	}{
+		{
+			name: "Valid",
+			input: `
+counters:
+- path: /junos/system/linecard/interface/**
+  width: 32
+  accumulate: true
+- path: /interfaces/interface/state/counters/*
+`,
+			expected: []*Counter{
+				{
+					Path:       "/junos/system/linecard/interface/**",
+					Width:      32,
+					Accumulate: true,
+				},
+				{
+					Path:  "/interfaces/interface/state/counters/*",
+					Width: 64,
+				},
+			},
+		},
+		{
+			name: "Invalid width",
+			input: `
+counters:
+- path: /foo
+  width: 16
+`,
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		cfg, err := Load(bytes.NewReader([]byte(test.input)))
+		if test.wantFail {
+			if err != nil {
+				continue
+			}
+
+			t.Errorf("Unexpected success for test %q", test.name)
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		assert.Equal(t, test.expected, cfg.Counters, test.name)
+	}
+}
+
+func TestLoadRemoteWrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *RemoteWrite
+		wantFail bool
+	}{
+		{
+			name: "Defaults",
+			input: `
+remote_write:
+  url: https://prometheus.example.com/api/v1/write
+  bearer_token_file: /etc/exporter/token
+`,
+			expected: &RemoteWrite{
+				URL:             "https://prometheus.example.com/api/v1/write",
+				IntervalS:       15,
+				TimeoutS:        30,
+				BatchSize:       500,
+				QueueCapacity:   10000,
+				MaxRetries:      intAddr(3),
+				MinBackoffMS:    30,
+				MaxBackoffMS:    5000,
+				BearerTokenFile: "/etc/exporter/token",
+			},
+		},
+		{
+			name: "Retries disabled",
+			input: `
+remote_write:
+  url: https://prometheus.example.com/api/v1/write
+  max_retries: 0
+`,
+			expected: &RemoteWrite{
+				URL:           "https://prometheus.example.com/api/v1/write",
+				IntervalS:     15,
+				TimeoutS:      30,
+				BatchSize:     500,
+				QueueCapacity: 10000,
+				MaxRetries:    intAddr(0),
+				MinBackoffMS:  30,
+				MaxBackoffMS:  5000,
+			},
+		},
+		{
+			name: "Invalid URL",
+			input: `
+remote_write:
+  url: prometheus.example.com
+`,
+			wantFail: true,
+		},
+		{
+			name: "Multiple auth methods",
+			input: `
+remote_write:
+  url: https://prometheus.example.com/api/v1/write
+  bearer_token: foo
+  basic_auth:
+    username: foo
+    password: bar
+`,
+			wantFail: true,
+		},
+		{
+			name: "Queue smaller than batch",
+			input: `
+remote_write:
+  url: https://prometheus.example.com/api/v1/write
+  batch_size: 1000
+  queue_capacity: 100
+`,
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		cfg, err := Load(bytes.NewReader([]byte(test.input)))
+		if test.wantFail {
+			if err != nil {
+				continue
+			}
+
+			t.Errorf("Unexpected success for test %q", test.name)
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		assert.Equal(t, test.expected, cfg.RemoteWrite, test.name)
+	}
+}
+
+func TestLoadInfluxDB(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *InfluxDB
+		wantFail bool
+	}{
+		{
+			name: "HTTP",
+			input: `
+influxdb:
+  url: http://influxdb:8086
+  database: telemetry
+  precision: s
+`,
+			expected: &InfluxDB{
+				URL:             "http://influxdb:8086",
+				Database:        "telemetry",
+				Precision:       "s",
+				BatchSize:       1000,
+				FlushIntervalMS: 1000,
+				QueueCapacity:   100000,
+				TimeoutS:        10,
+				MaxPayload:      1400,
+			},
+		},
+		{
+			name: "UDP without database",
+			input: `
+influxdb:
+  url: udp://influxdb:8089
+`,
+			expected: &InfluxDB{
+				URL:             "udp://influxdb:8089",
+				Precision:       "ms",
+				BatchSize:       1000,
+				FlushIntervalMS: 1000,
+				QueueCapacity:   100000,
+				TimeoutS:        10,
+				MaxPayload:      1400,
+			},
+		},
+		{
+			name: "HTTP without database",
+			input: `
+influxdb:
+  url: http://influxdb:8086
+`,
+			wantFail: true,
+		},
+		{
+			name: "Invalid precision",
+			input: `
+influxdb:
+  url: udp://influxdb:8089
+  precision: m
+`,
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		cfg, err := Load(bytes.NewReader([]byte(test.input)))
+		if test.wantFail {
+			if err != nil {
+				continue
+			}
+
+			t.Errorf("Unexpected success for test %q", test.name)
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		assert.Equal(t, test.expected, cfg.InfluxDB, test.name)
+	}
+}
+
+func TestLoadOTLP(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *OTLP
+		wantFail bool
+	}{
+		{
+			name: "gRPC with TLS",
+			input: `
+otlp:
+  endpoint: collector:4317
+  resource_attributes:
+    site: fra1
+  tls:
+    ca_file: /etc/ssl/ca.pem
+`,
+			expected: &OTLP{
+				Endpoint:           "collector:4317",
+				Protocol:           "grpc",
+				IntervalS:          15,
+				TimeoutS:           10,
+				ResourceAttributes: map[string]string{"site": "fra1"},
+				TLS: &TLS{
+					CAFile: "/etc/ssl/ca.pem",
+				},
+			},
+		},
+		{
+			name: "HTTP",
+			input: `
+otlp:
+  endpoint: http://collector:4318/v1/metrics
+  protocol: http/protobuf
+  interval_s: 60
+`,
+			expected: &OTLP{
+				Endpoint:  "http://collector:4318/v1/metrics",
+				Protocol:  "http/protobuf",
+				IntervalS: 60,
+				TimeoutS:  10,
+			},
+		},
+		{
+			name: "HTTP without URL",
+			input: `
+otlp:
+  endpoint: collector:4318
+  protocol: http/protobuf
+`,
+			wantFail: true,
+		},
+		{
+			name: "Unknown protocol",
+			input: `
+otlp:
+  endpoint: collector:4317
+  protocol: http/json
+`,
+			wantFail: true,
+		},
+		{
+			name: "Certificate without key",
+			input: `
+otlp:
+  endpoint: collector:4317
+  tls:
+    cert_file: /etc/ssl/client.pem
+`,
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		cfg, err := Load(bytes.NewReader([]byte(test.input)))
+		if test.wantFail {
+			if err != nil {
+				continue
+			}
+
+			t.Errorf("Unexpected success for test %q", test.name)
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		assert.Equal(t, test.expected, cfg.OTLP, test.name)
+	}
+}
+
+func TestLoadKafka(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *Kafka
+		wantFail bool
+	}{
+		{
+			name: "Defaults",
+			input: `
+kafka:
+  brokers:
+    - kafka1:9092
+    - kafka2:9092
+  topic: telemetry
+`,
+			expected: &Kafka{
+				Brokers:         []string{"kafka1:9092", "kafka2:9092"},
+				Topic:           "telemetry",
+				Encoding:        "json",
+				ClientID:        "openconfig-streaming-telemetry-exporter",
+				Version:         "1.0.0",
+				Compression:     "none",
+				RequiredAcks:    "leader",
+				FlushIntervalMS: 500,
+				QueueCapacity:   10000,
+				TimeoutS:        10,
+				MaxRetries:      3,
+			},
+		},
+		{
+			name: "Protobuf",
+			input: `
+kafka:
+  brokers:
+    - kafka1:9092
+  topic: telemetry
+  encoding: protobuf
+  version: 2.1.0
+  compression: zstd
+  required_acks: all
+`,
+			expected: &Kafka{
+				Brokers:         []string{"kafka1:9092"},
+				Topic:           "telemetry",
+				Encoding:        "protobuf",
+				ClientID:        "openconfig-streaming-telemetry-exporter",
+				Version:         "2.1.0",
+				Compression:     "zstd",
+				RequiredAcks:    "all",
+				FlushIntervalMS: 500,
+				QueueCapacity:   10000,
+				TimeoutS:        10,
+				MaxRetries:      3,
+			},
+		},
+		{
+			name: "Missing topic",
+			input: `
+kafka:
+  brokers:
+    - kafka1:9092
+`,
+			wantFail: true,
+		},
+		{
+			name: "Unknown encoding",
+			input: `
+kafka:
+  brokers:
+    - kafka1:9092
+  topic: telemetry
+  encoding: avro
+`,
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		cfg, err := Load(bytes.NewReader([]byte(test.input)))
+		if test.wantFail {
+			if err != nil {
+				continue
+			}
+
+			t.Errorf("Unexpected success for test %q", test.name)
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		assert.Equal(t, test.expected, cfg.Kafka, test.name)
+	}
+}
+
+func TestLoadRecord(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *Record
+		wantFail bool
+	}{
+		{
+			name: "Defaults",
+			input: `
+targets:
+  - hostname: r1
+    port: 50051
+    paths:
+    - path: /interfaces/
+    record:
+      dir: /var/lib/exporter/recordings
+`,
+			expected: &Record{
+				Dir:           "/var/lib/exporter/recordings",
+				Enabled:       boolAddr(true),
+				MaxFileSizeMB: 100,
+				MaxFileAgeS:   3600,
+			},
+		},
+		{
+			name: "Disabled with gzip",
+			input: `
+targets:
+  - hostname: r1
+    port: 50051
+    paths:
+    - path: /interfaces/
+    record:
+      dir: /var/lib/exporter/recordings
+      enabled: false
+      max_files: 24
+      gzip: true
+`,
+			expected: &Record{
+				Dir:           "/var/lib/exporter/recordings",
+				Enabled:       boolAddr(false),
+				MaxFileSizeMB: 100,
+				MaxFileAgeS:   3600,
+				MaxFiles:      24,
+				Gzip:          true,
+			},
+		},
+		{
+			name: "Missing dir",
+			input: `
+targets:
+  - hostname: r1
+    port: 50051
+    paths:
+    - path: /interfaces/
+    record:
+      gzip: true
+`,
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		cfg, err := Load(bytes.NewReader([]byte(test.input)))
+		if test.wantFail {
+			if err != nil {
+				continue
+			}
+
+			t.Errorf("Unexpected success for test %q", test.name)
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		assert.Equal(t, test.expected, cfg.Targets[0].Record, test.name)
+	}
+}
+
+func TestLoadValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name: "Unknown field",
+			input: `
+targets:
+  - hostname: r1
+    port: 50051
+    paths:
+    - path: /interfaces/
+      sample_frequncy_ms: 1000
+`,
+			expected: "yaml: unmarshal errors:\n  line 7: field sample_frequncy_ms not found in type config.Path",
+		},
+		{
+			name: "Duplicate target",
+			input: `
+targets:
+  - hostname: r1
+    port: 50051
+    paths:
+    - path: /interfaces/
+  - hostname: r1
+    port: 50052
+    paths:
+    - path: /interfaces/
+`,
+			expected: "line 7: duplicate target r1",
+		},
+		{
+			name: "Port 0",
+			input: `
+targets:
+  - hostname: r1
+    paths:
+    - path: /interfaces/
+`,
+			expected: "line 3: target r1: port must not be 0",
+		},
+		{
+			name: "No paths",
+			input: `
+targets:
+  - hostname: r1
+    port: 50051
+    paths: []
+`,
+			expected: "line 5: target r1: no paths",
+		},
+		{
+			name: "Empty path",
+			input: `
+targets:
+  - hostname: r1
+    port: 50051
+    paths:
+    - path: /interfaces/
+    - path: ""
+`,
+			expected: "line 7: target r1: empty path",
+		},
+		{
+			name: "Relative path",
+			input: `
+targets:
+  - hostname: r1
+    port: 50051
+    paths:
+    - path: interfaces/
+`,
+			expected: "line 6: target r1: path \"interfaces/\" does not start with /",
+		},
+		{
+			name: "Invalid path syntax",
+			input: `
+targets:
+  - hostname: r1
+    port: 50051
+    paths:
+    - path: /interfaces/interface[name='xe-0/0/0/
+`,
+			expected: "line 6: target r1: invalid path \"/interfaces/interface[name='xe-0/0/0/\": unterminated quoted value at offset 27",
+		},
+		{
+			name: "Max silent interval less than sample frequency",
+			input: `
+targets:
+  - hostname: r1
+    port: 50051
+    paths:
+    - path: /interfaces/
+      sample_frequency_ms: 30000
+`,
+			expected: "line 6: target r1: path /interfaces/: max_silent_interval_ms 15000 is less than sample_frequency_ms 30000",
+		},
+		{
+			name: "Sink",
+			input: `
+kafka:
+  topic: telemetry
+`,
+			expected: "line 2: kafka brokers are required",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := Load(bytes.NewReader([]byte(test.input)))
+		if err == nil {
+			t.Errorf("Unexpected success for test %q", test.name)
+			continue
+		}
+
+		assert.Equal(t, test.expected, err.Error(), test.name)
+	}
+}
+
+// writeFiles writes files by path relative to a new temporary directory and returns the directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = ioutil.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestLoadFileInclude(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     map[string]string
+		hostnames []string
+		expected  string
+	}{
+		{
+			name: "Globs in lexical order",
+			files: map[string]string{
+				"config.yml": `
+listen_address: :9000
+include_files:
+- sites/*.yml
+- global.yml
+targets:
+  - hostname: r1
+    port: 50051
+    paths:
+    - path: /interfaces/
+`,
+				"sites/b.yml": `
+targets:
+  - hostname: r3
+    port: 50051
+    paths:
+    - path: /interfaces/
+`,
+				"sites/a.yml": `
+include_files:
+- ../nested/*.yml
+targets:
+  - hostname: r2
+    port: 50051
+    paths:
+    - path: /interfaces/
+`,
+				"nested/c.yml": `
+targets:
+  - hostname: r4
+    port: 50051
+    paths:
+    - path: /interfaces/
+`,
+				"global.yml": `
+listen_address: :9513
+`,
+			},
+			hostnames: []string{"r1", "r2", "r4", "r3"},
+		},
+		{
+			name: "Error in included file",
+			files: map[string]string{
+				"config.yml": `
+include_files:
+- sites/*.yml
+targets:
+  - hostname: r1
+    port: 50051
+    paths:
+    - path: /interfaces/
+`,
+				"sites/a.yml": `
+targets:
+  - hostname: r2
+    port: 50051
+    paths:
+    - path: /interfaces/
+  - hostname: r1
+    port: 50051
+    paths:
+    - path: /interfaces/
+`,
+			},
+			expected: "sites/a.yml:7: duplicate target r1",
+		},
+		{
+			name: "Unknown field in included file",
+			files: map[string]string{
+				"config.yml": `
+include_files:
+- sites/*.yml
+`,
+				"sites/a.yml": `
+target:
+  - hostname: r1
+`,
+			},
+			expected: "sites/a.yml: yaml: unmarshal errors:\n  line 2: field target not found in type config.Config",
+		},
+		{
+			name: "Include cycle",
+			files: map[string]string{
+				"config.yml": `
+include_files:
+- config.yml
+`,
+			},
+			expected: "config.yml: loaded more than once",
+		},
+	}
+
+	for _, test := range tests {
+		dir := writeFiles(t, test.files)
+		defer os.RemoveAll(dir)
+
+		cfg, err := LoadFile(filepath.Join(dir, "config.yml"))
+		if test.expected != "" {
+			if err == nil {
+				t.Errorf("Unexpected success for test %q", test.name)
+				continue
+			}
+
+			assert.Equal(t, filepath.Join(dir, test.expected), err.Error(), test.name)
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		hostnames := make([]string, 0, len(cfg.Targets))
+		for _, target := range cfg.Targets {
+			hostnames = append(hostnames, target.Hostname)
+		}
+
+		assert.Equal(t, test.hostnames, hostnames, test.name)
+		assert.Equal(t, ":9513", cfg.ListenAddress, test.name)
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"10-global.yml": `
+string_value_mapping:
+  /interfaces/interface/state/oper-status:
+    DOWN: 0
+    UP: 1
+`,
+		"20-site.yaml": `
+string_value_mapping:
+  /interfaces/interface/state/oper-status:
+    DOWN: 0
+    UP: 2
+targets:
+  - hostname: r1
+    port: 50051
+    paths:
+    - path: /interfaces/
+`,
+		"README.md": "not a config file",
+	})
+	defer os.RemoveAll(dir)
+
+	cfg, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("Unexpected failure: %v", err)
+	}
+
+	assert.Equal(t, 1, len(cfg.Targets))
+	assert.Equal(t, map[string]float64{"DOWN": 0, "UP": 2}, cfg.StringValueMapping["/interfaces/interface/state/oper-status"].Values)
+}
+
+func TestLoadExpansion(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"password": "s3cret\n",
+	})
+	defer os.RemoveAll(dir)
+
+	os.Setenv("CONFIG_TEST_USER", "exporter")
+	defer os.Unsetenv("CONFIG_TEST_USER")
+
+	tests := []struct {
+		name     string
+		input    string
+		expected *BasicAuth
+		wantErr  string
+	}{
+		{
+			name: "Environment and file",
+			input: `
+remote_write:
+  url: http://localhost:9090/api/v1/write
+  basic_auth:
+    username: ${CONFIG_TEST_USER}
+    password: "${file:` + filepath.Join(dir, "password") + `}$${x}"
+`,
+			expected: &BasicAuth{
+				Username: "exporter",
+				Password: "s3cret${x}",
+			},
+		},
+		{
+			name: "Unset variable",
+			input: `
+remote_write:
+  url: http://localhost:9090/api/v1/write
+  basic_auth:
+    username: ${CONFIG_TEST_UNSET}
+`,
+			wantErr: "line 5: ${CONFIG_TEST_UNSET}: environment variable CONFIG_TEST_UNSET is not set",
+		},
+		{
+			name: "Invalid reference",
+			input: `
+remote_write:
+  url: http://localhost:9090/api/v1/write
+  bearer_token: ${}
+`,
+			wantErr: "line 4: ${}: invalid reference",
+		},
+	}
+
+	for _, test := range tests {
+		cfg, err := Load(bytes.NewReader([]byte(test.input)))
+		if test.wantErr != "" {
+			if err == nil {
+				t.Errorf("Unexpected success for test %q", test.name)
+				continue
+			}
+
+			assert.Equal(t, test.wantErr, err.Error(), test.name)
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		assert.Equal(t, test.expected, cfg.RemoteWrite.BasicAuth, test.name)
+	}
+}
+
+func TestLoadExpansionSkipsPatterns(t *testing.T) {
+	os.Setenv("CONFIG_TEST_TOKEN", "secret")
+	defer os.Unsetenv("CONFIG_TEST_TOKEN")
+
+	cfg, err := Load(bytes.NewReader([]byte(`
+targets:
+- hostname: 203.0.113.1
+  port: 50051
+  paths:
+  - path: /interfaces/
+  include:
+  - "~^/interfaces/.*$$"
+metric_relabel_configs:
+- source_labels: [interface_name]
+  regex: "([a-z]+)-.*"
+  target_label: interface_type
+  replacement: ${1}
+description_parsers:
+- path: /interfaces/interface/state/description
+  type: regex
+  regex: "^CUST:(?P<customer>[^;]+)$$"
+otlp:
+  endpoint: collector:4317
+  headers:
+    authorization: Bearer ${CONFIG_TEST_TOKEN}
+  resource_attributes:
+    service.name: ${CONFIG_TEST_TOKEN}
+`)))
+	if err != nil {
+		t.Fatalf("Unexpected failure: %v", err)
+	}
+
+	assert.Equal(t, []string{"~^/interfaces/.*$$"}, cfg.Targets[0].Include)
+	assert.Equal(t, "${1}", cfg.MetricRelabelConfigs[0].Replacement)
+	assert.Equal(t, "^CUST:(?P<customer>[^;]+)$$", cfg.DescriptionParsers[0].Regex)
+	assert.Equal(t, map[string]string{"authorization": "Bearer secret"}, cfg.OTLP.Headers)
+	assert.Equal(t, map[string]string{"service.name": "${CONFIG_TEST_TOKEN}"}, cfg.OTLP.ResourceAttributes)
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/pathmatch"
+)
+
+const defaultCounterWidth = 64
+
+// Counter configures the handling of discontinuities of matching counters
+type Counter struct {
+	// Path is the path (or pattern) of the leaf, e.g. /junos/system/linecard/interface/**
+	Path string `yaml:"path"`
+	// Width is the width of the counter in bits (32 or 64). Decreasing 32 bit counters above 2^31 are treated as wraps.
+	Width uint `yaml:"width"`
+	// Accumulate exposes a monotonic counter accumulating all increases across resets and wraps
+	Accumulate bool `yaml:"accumulate"`
+}
+
+func (c *Counter) loadDefaults() {
+	if c.Width == 0 {
+		c.Width = defaultCounterWidth
+	}
+}
+
+func (c *Counter) validate() error {
+	_, err := pathmatch.Compile(c.Path)
+	if err != nil {
+		return fmt.Errorf("invalid counter path: %v", err)
+	}
+
+	if c.Width != 32 && c.Width != 64 {
+		return fmt.Errorf("invalid counter width %d", c.Width)
+	}
+
+	return nil
+}
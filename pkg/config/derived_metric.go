@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/derived"
+	"github.com/prometheus/common/model"
+)
+
+// Derived metric types
+const (
+	DerivedMetricGauge   = "gauge"
+	DerivedMetricCounter = "counter"
+)
+
+// DerivedMetric is a metric computed from other series at collect time
+type DerivedMetric struct {
+	// Name is the name of the metric, e.g. interface_in_bits_total
+	Name string `yaml:"name"`
+	Help string `yaml:"help"`
+	// Type is either gauge (default) or counter
+	Type string `yaml:"type"`
+	// Expr is the expression computing the metric, see package derived
+	Expr string `yaml:"expr"`
+	// Labels are added to all series of the metric
+	Labels map[string]string `yaml:"labels"`
+}
+
+func (d *DerivedMetric) loadDefaults() {
+	if d.Type == "" {
+		d.Type = DerivedMetricGauge
+	}
+
+	if d.Help == "" {
+		d.Help = d.Expr
+	}
+}
+
+func (d *DerivedMetric) validate() error {
+	if !model.IsValidMetricName(model.LabelValue(d.Name)) {
+		return fmt.Errorf("invalid derived metric name %q", d.Name)
+	}
+
+	switch d.Type {
+	case DerivedMetricGauge, DerivedMetricCounter:
+	default:
+		return fmt.Errorf("derived metric %s: unknown type %q", d.Name, d.Type)
+	}
+
+	_, err := derived.Parse(d.Expr)
+	if err != nil {
+		return fmt.Errorf("derived metric %s: invalid expression: %v", d.Name, err)
+	}
+
+	for name := range d.Labels {
+		if !model.LabelName(name).IsValid() {
+			return fmt.Errorf("derived metric %s: invalid label name %q", d.Name, name)
+		}
+
+		if name == "device" {
+			return fmt.Errorf("derived metric %s: label device is set to the target", d.Name)
+		}
+	}
+
+	return nil
+}
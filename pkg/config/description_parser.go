@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/pathmatch"
+	"github.com/prometheus/common/model"
+)
+
+// Description parser types
+const (
+	DescriptionParserRegex = "regex"
+	DescriptionParserJSON  = "json"
+	DescriptionParserKV    = "kv"
+)
+
+const (
+	defaultDescriptionPairSeparator     = ","
+	defaultDescriptionKeyValueSeparator = "="
+)
+
+// DescriptionParser describes how labels are extracted from descriptions
+type DescriptionParser struct {
+	// Path is the path (or pattern) of the description leaf, e.g. /interfaces/interface/state/description
+	Path string `yaml:"path"`
+	Type string `yaml:"type"`
+	// Regex is a regular expression with named groups (type regex)
+	Regex string `yaml:"regex"`
+	// PairSeparator separates key value pairs (type kv)
+	PairSeparator string `yaml:"pair_separator"`
+	// KeyValueSeparator separates keys from values (type kv)
+	KeyValueSeparator string `yaml:"key_value_separator"`
+	// Labels is the list of keys that become labels. All keys become labels if empty.
+	Labels []string `yaml:"labels"`
+	// FallbackLabel is the label the raw description is put into if no labels could be extracted
+	FallbackLabel string `yaml:"fallback_label"`
+}
+
+func (dp *DescriptionParser) loadDefaults() {
+	if dp.Type == "" {
+		dp.Type = DescriptionParserKV
+	}
+
+	if dp.Type != DescriptionParserKV {
+		return
+	}
+
+	if dp.PairSeparator == "" {
+		dp.PairSeparator = defaultDescriptionPairSeparator
+	}
+
+	if dp.KeyValueSeparator == "" {
+		dp.KeyValueSeparator = defaultDescriptionKeyValueSeparator
+	}
+}
+
+func (dp *DescriptionParser) validate() error {
+	_, err := pathmatch.Compile(dp.Path)
+	if err != nil {
+		return fmt.Errorf("invalid description parser path: %v", err)
+	}
+
+	if dp.FallbackLabel != "" && !model.LabelName(dp.FallbackLabel).IsValid() {
+		return fmt.Errorf("invalid description parser fallback_label %q", dp.FallbackLabel)
+	}
+
+	switch dp.Type {
+	case DescriptionParserRegex:
+		re, err := regexp.Compile(dp.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid description parser regex %q: %v", dp.Regex, err)
+		}
+
+		for _, name := range re.SubexpNames() {
+			if name != "" {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("description parser regex %q has no named groups", dp.Regex)
+	case DescriptionParserJSON, DescriptionParserKV:
+	default:
+		return fmt.Errorf("unknown description parser type %q", dp.Type)
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+const fileReferencePrefix = "file:"
+
+var (
+	referenceRegexp = regexp.MustCompile(`\$\$|\$\{([^}]*)\}`)
+	envNameRegexp   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// referenceError is the error resolving a reference
+type referenceError struct {
+	ref string
+	err error
+}
+
+func (e *referenceError) Error() string {
+	return fmt.Sprintf("%s: %v", e.ref, e.err)
+}
+
+// expander expands references in config strings. Relative file references are relative to dir.
+type expander struct {
+	dir string
+}
+
+// expand replaces ${NAME} by the value of the environment variable NAME and ${file:PATH} by the content of the file
+// at PATH without trailing newlines. $$ is replaced by $.
+func (e *expander) expand(s string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var err error
+	res := referenceRegexp.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref == "$$" {
+			return "$"
+		}
+
+		v, refErr := e.resolve(ref[2 : len(ref)-1])
+		if refErr != nil && err == nil {
+			err = &referenceError{ref: ref, err: refErr}
+		}
+
+		return v
+	})
+
+	return res, err
+}
+
+func (e *expander) resolve(ref string) (string, error) {
+	if strings.HasPrefix(ref, fileReferencePrefix) {
+		path := strings.TrimPrefix(ref, fileReferencePrefix)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(e.dir, path)
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+
+	if !envNameRegexp.MatchString(ref) {
+		return "", fmt.Errorf("invalid reference")
+	}
+
+	v, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", ref)
+	}
+
+	return v, nil
+}
+
+// expandStrings expands the strings of all fields tagged with `expand:"true"` reachable from v.
+// Other strings are left alone as e.g. regular expressions and relabel replacements use $ themselves.
+func (e *expander) expandStrings(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+
+		return e.expandStrings(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Field(i).CanSet() {
+				continue
+			}
+
+			var err error
+			if v.Type().Field(i).Tag.Get("expand") == "true" {
+				err = e.expandValue(v.Field(i))
+			} else {
+				err = e.expandStrings(v.Field(i))
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			err := e.expandStrings(v.Index(i))
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			err := e.expandStrings(v.MapIndex(k))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// expandValue expands a string or the string values of a map
+func (e *expander) expandValue(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			x := v.MapIndex(k)
+			if x.Kind() != reflect.String {
+				continue
+			}
+
+			s, err := e.expand(x.String())
+			if err != nil {
+				return err
+			}
+
+			v.SetMapIndex(k, reflect.ValueOf(s).Convert(x.Type()))
+		}
+	case reflect.String:
+		s, err := e.expand(v.String())
+		if err != nil {
+			return err
+		}
+
+		v.SetString(s)
+	}
+
+	return nil
+}
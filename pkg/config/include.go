@@ -0,0 +1,203 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// loader merges config files and the files they include
+type loader struct {
+	cfg    *Config
+	lines  *lines
+	loaded map[string]struct{}
+}
+
+func newLoader() *loader {
+	return &loader{
+		cfg:    New(),
+		lines:  newLines(),
+		loaded: make(map[string]struct{}),
+	}
+}
+
+// LoadFile loads the config file at path and the files it includes
+func LoadFile(path string) (*Config, error) {
+	ld := newLoader()
+	err := ld.loadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ld.finish()
+}
+
+// LoadDir loads the config files (*.yml and *.yaml) in dir in lexical order and the files they include
+func LoadDir(dir string) (*Config, error) {
+	var files []string
+	for _, pattern := range []string{"*.yml", "*.yaml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, matches...)
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no config files in %s", dir)
+	}
+
+	sort.Strings(files)
+
+	ld := newLoader()
+	for _, f := range files {
+		err := ld.loadFile(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ld.finish()
+}
+
+func (ld *loader) loadFile(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := ld.loaded[abs]; ok {
+		return fmt.Errorf("%s: loaded more than once", path)
+	}
+
+	ld.loaded[abs] = struct{}{}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return ld.load(path, filepath.Dir(path), b)
+}
+
+// load merges the config b of file and then the files it includes. Relative paths are relative to dir.
+func (ld *loader) load(file string, dir string, b []byte) error {
+	c := New()
+	err := yaml.UnmarshalStrict(b, c)
+	if err != nil {
+		return located(file, 0, err)
+	}
+
+	e := &expander{dir: dir}
+	err = e.expandStrings(reflect.ValueOf(c))
+	if err != nil {
+		line := 0
+		if refErr, ok := err.(*referenceError); ok {
+			line = lineOf(b, refErr.ref)
+		}
+
+		return located(file, line, err)
+	}
+
+	ld.lines.add(file, b)
+	ld.cfg.merge(c)
+
+	for _, include := range c.IncludeFiles {
+		pattern := include
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return located(file, lineOf(b, include), fmt.Errorf("invalid include_files %q: %v", include, err))
+		}
+
+		sort.Strings(matches)
+		for _, m := range matches {
+			err = ld.loadFile(m)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (ld *loader) finish() (*Config, error) {
+	ld.cfg.LoadDefaults()
+
+	err := ld.cfg.validate(ld.lines)
+	if err != nil {
+		return nil, err
+	}
+
+	return ld.cfg, nil
+}
+
+// merge merges o into c. Lists are appended, string value mappings are merged by path.
+// Other settings of o replace the ones of c.
+func (c *Config) merge(o *Config) {
+	if o.ListenAddress != "" {
+		c.ListenAddress = o.ListenAddress
+	}
+
+	if o.MetricsPath != "" {
+		c.MetricsPath = o.MetricsPath
+	}
+
+	c.Targets = append(c.Targets, o.Targets...)
+
+	if o.StringValueMapping != nil && c.StringValueMapping == nil {
+		c.StringValueMapping = make(StringValueMapping, len(o.StringValueMapping))
+	}
+
+	for path, m := range o.StringValueMapping {
+		c.StringValueMapping[path] = m
+	}
+
+	if o.DisableBuiltinStringValueMapping {
+		c.DisableBuiltinStringValueMapping = true
+	}
+
+	if o.YANGDir != "" {
+		c.YANGDir = o.YANGDir
+	}
+
+	c.MetricRelabelConfigs = append(c.MetricRelabelConfigs, o.MetricRelabelConfigs...)
+	c.DescriptionParsers = append(c.DescriptionParsers, o.DescriptionParsers...)
+	c.BytesValueDecoders = append(c.BytesValueDecoders, o.BytesValueDecoders...)
+	c.DerivedMetrics = append(c.DerivedMetrics, o.DerivedMetrics...)
+	c.Aggregations = append(c.Aggregations, o.Aggregations...)
+	c.Counters = append(c.Counters, o.Counters...)
+
+	if o.RemoteWrite != nil {
+		c.RemoteWrite = o.RemoteWrite
+	}
+
+	if o.InfluxDB != nil {
+		c.InfluxDB = o.InfluxDB
+	}
+
+	if o.OTLP != nil {
+		c.OTLP = o.OTLP
+	}
+
+	if o.Kafka != nil {
+		c.Kafka = o.Kafka
+	}
+
+	if o.Limits != nil {
+		c.Limits = o.Limits
+	}
+
+	if o.Version != "" {
+		c.Version = o.Version
+	}
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+const (
+	defaultInfluxDBPrecision       = "ms"
+	defaultInfluxDBBatchSize       = 1000
+	defaultInfluxDBFlushIntervalMS = 1000
+	defaultInfluxDBQueueCapacity   = 100000
+	defaultInfluxDBTimeoutS        = 10
+	defaultInfluxDBMaxPayload      = 1400
+)
+
+// InfluxDB configures writing every update as InfluxDB line protocol
+type InfluxDB struct {
+	// URL is either the HTTP(S) base URL of the server (e.g. http://influxdb:8086) or udp://host:port
+	URL             string `yaml:"url"`
+	Database        string `yaml:"database"`
+	RetentionPolicy string `yaml:"retention_policy"`
+	Username        string `yaml:"username" expand:"true"`
+	Password        string `yaml:"password" expand:"true"`
+	// Precision of the timestamps (ns, us, ms or s)
+	Precision string `yaml:"precision"`
+	// BatchSize is the maximum number of points per request
+	BatchSize int `yaml:"batch_size"`
+	// FlushIntervalMS is the maximum time points are buffered
+	FlushIntervalMS uint64 `yaml:"flush_interval_ms"`
+	// QueueCapacity is the maximum number of points waiting to be written. Points exceeding it are dropped.
+	QueueCapacity int    `yaml:"queue_capacity"`
+	TimeoutS      uint64 `yaml:"timeout_s"`
+	// MaxPayload is the maximum size of a UDP datagram in bytes
+	MaxPayload int `yaml:"max_payload"`
+}
+
+func (i *InfluxDB) loadDefaults() {
+	if i.Precision == "" {
+		i.Precision = defaultInfluxDBPrecision
+	}
+
+	if i.BatchSize == 0 {
+		i.BatchSize = defaultInfluxDBBatchSize
+	}
+
+	if i.FlushIntervalMS == 0 {
+		i.FlushIntervalMS = defaultInfluxDBFlushIntervalMS
+	}
+
+	if i.QueueCapacity == 0 {
+		i.QueueCapacity = defaultInfluxDBQueueCapacity
+	}
+
+	if i.TimeoutS == 0 {
+		i.TimeoutS = defaultInfluxDBTimeoutS
+	}
+
+	if i.MaxPayload == 0 {
+		i.MaxPayload = defaultInfluxDBMaxPayload
+	}
+}
+
+func (i *InfluxDB) validate() error {
+	u, err := url.Parse(i.URL)
+	if err != nil {
+		return fmt.Errorf("invalid influxdb url: %v", err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		if i.Database == "" {
+			return fmt.Errorf("influxdb database is required for %s", u.Scheme)
+		}
+	case "udp":
+	default:
+		return fmt.Errorf("invalid influxdb url %q: scheme must be http, https or udp", i.URL)
+	}
+
+	switch i.Precision {
+	case "ns", "us", "ms", "s":
+	default:
+		return fmt.Errorf("invalid influxdb precision %q", i.Precision)
+	}
+
+	if i.BatchSize < 0 || i.QueueCapacity < i.BatchSize {
+		return fmt.Errorf("influxdb queue_capacity (%d) must not be smaller than batch_size (%d)", i.QueueCapacity, i.BatchSize)
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package config
+
+import "fmt"
+
+// Kafka record encodings
+const (
+	KafkaEncodingJSON     = "json"
+	KafkaEncodingProtobuf = "protobuf"
+)
+
+const (
+	defaultKafkaEncoding        = KafkaEncodingJSON
+	defaultKafkaClientID        = "openconfig-streaming-telemetry-exporter"
+	defaultKafkaVersion         = "1.0.0"
+	defaultKafkaCompression     = "none"
+	defaultKafkaRequiredAcks    = "leader"
+	defaultKafkaFlushIntervalMS = 500
+	defaultKafkaQueueCapacity   = 10000
+	defaultKafkaTimeoutS        = 10
+	defaultKafkaMaxRetries      = 3
+)
+
+// Kafka configures publishing every update as a record to a Kafka topic
+type Kafka struct {
+	Brokers []string `yaml:"brokers"`
+	Topic   string   `yaml:"topic"`
+	// Encoding of the records: json (default) or protobuf
+	Encoding string `yaml:"encoding"`
+	ClientID string `yaml:"client_id"`
+	// Version is the Kafka protocol version to use, e.g. 2.1.0
+	Version string `yaml:"version"`
+	// Compression is one of none, gzip, snappy, lz4 or zstd
+	Compression string `yaml:"compression"`
+	// RequiredAcks is one of none, leader or all
+	RequiredAcks string `yaml:"required_acks"`
+	// FlushIntervalMS is the maximum time records are buffered before being sent
+	FlushIntervalMS uint64 `yaml:"flush_interval_ms"`
+	// QueueCapacity is the maximum number of records waiting to be sent. Records exceeding it are dropped.
+	QueueCapacity int    `yaml:"queue_capacity"`
+	TimeoutS      uint64 `yaml:"timeout_s"`
+	MaxRetries    int    `yaml:"max_retries"`
+	TLS           *TLS   `yaml:"tls"`
+}
+
+func (k *Kafka) loadDefaults() {
+	if k.Encoding == "" {
+		k.Encoding = defaultKafkaEncoding
+	}
+
+	if k.ClientID == "" {
+		k.ClientID = defaultKafkaClientID
+	}
+
+	if k.Version == "" {
+		k.Version = defaultKafkaVersion
+	}
+
+	if k.Compression == "" {
+		k.Compression = defaultKafkaCompression
+	}
+
+	if k.RequiredAcks == "" {
+		k.RequiredAcks = defaultKafkaRequiredAcks
+	}
+
+	if k.FlushIntervalMS == 0 {
+		k.FlushIntervalMS = defaultKafkaFlushIntervalMS
+	}
+
+	if k.QueueCapacity == 0 {
+		k.QueueCapacity = defaultKafkaQueueCapacity
+	}
+
+	if k.TimeoutS == 0 {
+		k.TimeoutS = defaultKafkaTimeoutS
+	}
+
+	if k.MaxRetries == 0 {
+		k.MaxRetries = defaultKafkaMaxRetries
+	}
+}
+
+func (k *Kafka) validate() error {
+	if len(k.Brokers) == 0 {
+		return fmt.Errorf("kafka brokers are required")
+	}
+
+	if k.Topic == "" {
+		return fmt.Errorf("kafka topic is required")
+	}
+
+	switch k.Encoding {
+	case KafkaEncodingJSON, KafkaEncodingProtobuf:
+	default:
+		return fmt.Errorf("unknown kafka encoding %q", k.Encoding)
+	}
+
+	switch k.Compression {
+	case "none", "gzip", "snappy", "lz4", "zstd":
+	default:
+		return fmt.Errorf("unknown kafka compression %q", k.Compression)
+	}
+
+	switch k.RequiredAcks {
+	case "none", "leader", "all":
+	default:
+		return fmt.Errorf("invalid kafka required_acks %q", k.RequiredAcks)
+	}
+
+	if k.TLS != nil {
+		err := k.TLS.validate()
+		if err != nil {
+			return fmt.Errorf("kafka: %v", err)
+		}
+	}
+
+	return nil
+}
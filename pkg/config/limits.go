@@ -0,0 +1,27 @@
+package config
+
+// Limits limits the cardinality of the data stored per target. Unset or zero means unlimited.
+// Limits are pointers so a target can set 0 to override a global limit.
+type Limits struct {
+	// MaxNodes is the maximum number of nodes in the tree of a target
+	MaxNodes *int `yaml:"max_nodes"`
+	// MaxSeriesPerMetric is the maximum number of series per metric name
+	MaxSeriesPerMetric *int `yaml:"max_series_per_metric"`
+	// MaxLabelValues is the maximum number of distinct values per label
+	MaxLabelValues *int `yaml:"max_label_values"`
+}
+
+// inherit sets all unset limits of l to the value of parent
+func (l *Limits) inherit(parent *Limits) {
+	if l.MaxNodes == nil {
+		l.MaxNodes = parent.MaxNodes
+	}
+
+	if l.MaxSeriesPerMetric == nil {
+		l.MaxSeriesPerMetric = parent.MaxSeriesPerMetric
+	}
+
+	if l.MaxLabelValues == nil {
+		l.MaxLabelValues = parent.MaxLabelValues
+	}
+}
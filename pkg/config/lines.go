@@ -0,0 +1,171 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// lines locates elements of the YAML documents a config has been merged from to report errors with line numbers
+type lines struct {
+	docs []*document
+}
+
+type document struct {
+	file string
+	root *yaml3.Node
+}
+
+func newLines() *lines {
+	return &lines{}
+}
+
+// add adds the document of file. Documents have to be added in the order they are merged.
+func (l *lines) add(file string, b []byte) {
+	doc := &yaml3.Node{}
+	err := yaml3.Unmarshal(b, doc)
+	if err != nil || len(doc.Content) == 0 {
+		doc = nil
+	} else {
+		doc = doc.Content[0]
+	}
+
+	l.docs = append(l.docs, &document{
+		file: file,
+		root: doc,
+	})
+}
+
+// locate returns file and line of the element at path, e.g. "targets", 0, "port". Elements are mapping keys (string) or
+// sequence indices (int). Indices of top level sequences refer to the merged sequence of all documents.
+// The line of the closest parent is returned if the element does not exist, 0 if none does.
+func (l *lines) locate(path ...interface{}) (string, int) {
+	if l == nil || len(l.docs) == 0 {
+		return "", 0
+	}
+
+	doc := l.docs[0]
+	if len(path) > 0 {
+		doc, path = l.document(path)
+	}
+
+	if doc.root == nil {
+		return doc.file, 0
+	}
+
+	return doc.file, doc.line(path)
+}
+
+// document returns the document containing the top level element of path and path relative to it
+func (l *lines) document(path []interface{}) (*document, []interface{}) {
+	key, _ := path[0].(string)
+	if len(path) > 1 {
+		if idx, ok := path[1].(int); ok {
+			for _, d := range l.docs {
+				n := d.child(key)
+				if n == nil || n.Kind != yaml3.SequenceNode {
+					continue
+				}
+
+				if idx < len(n.Content) {
+					res := append([]interface{}{key, idx}, path[2:]...)
+					return d, res
+				}
+
+				idx -= len(n.Content)
+			}
+		}
+	}
+
+	for i := len(l.docs) - 1; i >= 0; i-- {
+		if l.docs[i].child(key) != nil {
+			return l.docs[i], path
+		}
+	}
+
+	return l.docs[0], path
+}
+
+// child returns the value of the top level key of d
+func (d *document) child(key string) *yaml3.Node {
+	if d.root == nil || d.root.Kind != yaml3.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(d.root.Content); i += 2 {
+		if d.root.Content[i].Value == key {
+			return d.root.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+func (d *document) line(path []interface{}) int {
+	n := d.root
+	line := n.Line
+	for _, p := range path {
+		if n.Kind == yaml3.AliasNode {
+			n = n.Alias
+		}
+
+		var next *yaml3.Node
+		switch x := p.(type) {
+		case string:
+			if n.Kind != yaml3.MappingNode {
+				break
+			}
+
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if n.Content[i].Value == x {
+					line = n.Content[i].Line
+					next = n.Content[i+1]
+				}
+			}
+		case int:
+			if n.Kind == yaml3.SequenceNode && x < len(n.Content) {
+				next = n.Content[x]
+				line = next.Line
+			}
+		}
+
+		if next == nil {
+			return line
+		}
+
+		n = next
+	}
+
+	return line
+}
+
+// wrap prefixes err with the location of the element at path
+func (l *lines) wrap(err error, path ...interface{}) error {
+	file, line := l.locate(path...)
+	return located(file, line, err)
+}
+
+// located prefixes err with file and line if known
+func located(file string, line int, err error) error {
+	switch {
+	case file != "" && line > 0:
+		return fmt.Errorf("%s:%d: %v", file, line, err)
+	case file != "":
+		return fmt.Errorf("%s: %v", file, err)
+	case line > 0:
+		return fmt.Errorf("line %d: %v", line, err)
+	}
+
+	return err
+}
+
+// lineOf returns the line of the first occurrence of s in b or 0
+func lineOf(b []byte, s string) int {
+	i := bytes.Index(b, []byte(s))
+	if i < 0 {
+		return 0
+	}
+
+	return bytes.Count(b[:i], []byte("\n")) + 1
+}
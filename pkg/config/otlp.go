@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// OTLP protocols
+const (
+	OTLPProtocolGRPC = "grpc"
+	OTLPProtocolHTTP = "http/protobuf"
+)
+
+const (
+	defaultOTLPIntervalS = 15
+	defaultOTLPTimeoutS  = 10
+)
+
+// OTLP configures the periodic export of all series to an OpenTelemetry collector
+type OTLP struct {
+	// Endpoint is host:port for grpc or the URL (e.g. https://collector:4318/v1/metrics) for http/protobuf
+	Endpoint string `yaml:"endpoint"`
+	// Protocol is either grpc (default) or http/protobuf
+	Protocol  string `yaml:"protocol"`
+	IntervalS uint64 `yaml:"interval_s"`
+	TimeoutS  uint64 `yaml:"timeout_s"`
+	// Headers are sent with every request (as metadata for grpc)
+	Headers map[string]string `yaml:"headers" expand:"true"`
+	// ResourceAttributes are added to the resource of every target
+	ResourceAttributes map[string]string `yaml:"resource_attributes"`
+	TLS                *TLS              `yaml:"tls"`
+}
+
+func (o *OTLP) loadDefaults() {
+	if o.Protocol == "" {
+		o.Protocol = OTLPProtocolGRPC
+	}
+
+	if o.IntervalS == 0 {
+		o.IntervalS = defaultOTLPIntervalS
+	}
+
+	if o.TimeoutS == 0 {
+		o.TimeoutS = defaultOTLPTimeoutS
+	}
+}
+
+func (o *OTLP) validate() error {
+	if o.Endpoint == "" {
+		return fmt.Errorf("otlp endpoint is required")
+	}
+
+	switch o.Protocol {
+	case OTLPProtocolGRPC:
+	case OTLPProtocolHTTP:
+		u, err := url.Parse(o.Endpoint)
+		if err != nil {
+			return fmt.Errorf("invalid otlp endpoint: %v", err)
+		}
+
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid otlp endpoint %q: scheme must be http or https", o.Endpoint)
+		}
+	default:
+		return fmt.Errorf("unknown otlp protocol %q", o.Protocol)
+	}
+
+	if o.TLS != nil {
+		err := o.TLS.validate()
+		if err != nil {
+			return fmt.Errorf("otlp: %v", err)
+		}
+	}
+
+	return nil
+}
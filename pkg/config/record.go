@@ -0,0 +1,51 @@
+package config
+
+import "fmt"
+
+const (
+	defaultRecordEnabled       = true
+	defaultRecordMaxFileSizeMB = 100
+	defaultRecordMaxFileAgeS   = 3600
+)
+
+// Record configures recording the raw stream of a target to disk
+type Record struct {
+	// Dir is the directory the recordings are written to
+	Dir string `yaml:"dir"`
+	// Enabled starts recording along with the target (default true). Recording can be toggled at runtime via the API.
+	Enabled *bool `yaml:"enabled"`
+	// MaxFileSizeMB is the size (before compression) after which a new file is started
+	MaxFileSizeMB uint64 `yaml:"max_file_size_mb"`
+	// MaxFileAgeS is the age after which a new file is started
+	MaxFileAgeS uint64 `yaml:"max_file_age_s"`
+	// MaxFiles is the number of files kept per target. Older files are removed, 0 keeps all files.
+	MaxFiles int  `yaml:"max_files"`
+	Gzip     bool `yaml:"gzip"`
+}
+
+func (r *Record) loadDefaults() {
+	if r.Enabled == nil {
+		x := defaultRecordEnabled
+		r.Enabled = &x
+	}
+
+	if r.MaxFileSizeMB == 0 {
+		r.MaxFileSizeMB = defaultRecordMaxFileSizeMB
+	}
+
+	if r.MaxFileAgeS == 0 {
+		r.MaxFileAgeS = defaultRecordMaxFileAgeS
+	}
+}
+
+func (r *Record) validate() error {
+	if r.Dir == "" {
+		return fmt.Errorf("record dir is required")
+	}
+
+	if r.MaxFiles < 0 {
+		return fmt.Errorf("record max_files must not be negative")
+	}
+
+	return nil
+}
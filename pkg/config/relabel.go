@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Relabel actions
+const (
+	RelabelReplace   = "replace"
+	RelabelKeep      = "keep"
+	RelabelDrop      = "drop"
+	RelabelHashMod   = "hashmod"
+	RelabelLabelMap  = "labelmap"
+	RelabelLabelDrop = "labeldrop"
+	RelabelLabelKeep = "labelkeep"
+)
+
+// DefaultRelabelConfig is the default relabel configuration as in Prometheus
+var DefaultRelabelConfig = RelabelConfig{
+	Action:      RelabelReplace,
+	Separator:   ";",
+	Regex:       "(.*)",
+	Replacement: "$1",
+}
+
+// RelabelConfig is a relabel rule with the same semantics as Prometheus metric_relabel_configs
+type RelabelConfig struct {
+	SourceLabels []string `yaml:"source_labels,flow"`
+	Separator    string   `yaml:"separator"`
+	Regex        string   `yaml:"regex"`
+	Modulus      uint64   `yaml:"modulus"`
+	TargetLabel  string   `yaml:"target_label"`
+	Replacement  string   `yaml:"replacement"`
+	Action       string   `yaml:"action"`
+}
+
+// UnmarshalYAML sets the defaults for all omitted fields
+func (c *RelabelConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultRelabelConfig
+
+	type plain RelabelConfig
+	return unmarshal((*plain)(c))
+}
+
+func (c *RelabelConfig) validate() error {
+	_, err := regexp.Compile("^(?:" + c.Regex + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid regex %q: %v", c.Regex, err)
+	}
+
+	switch c.Action {
+	case RelabelReplace:
+		if c.TargetLabel == "" {
+			return fmt.Errorf("relabel configuration for %s action requires 'target_label' value", c.Action)
+		}
+	case RelabelHashMod:
+		if c.TargetLabel == "" {
+			return fmt.Errorf("relabel configuration for %s action requires 'target_label' value", c.Action)
+		}
+
+		if c.Modulus == 0 {
+			return fmt.Errorf("relabel configuration for %s action requires non-zero 'modulus'", c.Action)
+		}
+	case RelabelKeep, RelabelDrop, RelabelLabelMap, RelabelLabelDrop, RelabelLabelKeep:
+	default:
+		return fmt.Errorf("unknown relabel action %q", c.Action)
+	}
+
+	return nil
+}
+
+func validateRelabelConfigs(cfgs []*RelabelConfig) error {
+	for _, c := range cfgs {
+		err := c.validate()
+		if err != nil {
+			return fmt.Errorf("invalid metric_relabel_configs: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+const (
+	defaultRemoteWriteIntervalS     = 15
+	defaultRemoteWriteTimeoutS      = 30
+	defaultRemoteWriteBatchSize     = 500
+	defaultRemoteWriteQueueCapacity = 10000
+	defaultRemoteWriteMaxRetries    = 3
+	defaultRemoteWriteMinBackoffMS  = 30
+	defaultRemoteWriteMaxBackoffMS  = 5000
+)
+
+// RemoteWrite configures pushing all series to a Prometheus remote write endpoint
+type RemoteWrite struct {
+	URL string `yaml:"url"`
+	// IntervalS is the interval in seconds in which all series are pushed
+	IntervalS uint64 `yaml:"interval_s"`
+	// TimeoutS is the timeout of a single request in seconds
+	TimeoutS uint64 `yaml:"timeout_s"`
+	// BatchSize is the maximum number of samples per request
+	BatchSize int `yaml:"batch_size"`
+	// QueueCapacity is the maximum number of samples waiting to be sent. Samples exceeding it are dropped.
+	QueueCapacity int `yaml:"queue_capacity"`
+	// MaxRetries is the number of retries of a request failing with a recoverable error, 0 disables retries
+	MaxRetries         *int       `yaml:"max_retries"`
+	MinBackoffMS       uint64     `yaml:"min_backoff_ms"`
+	MaxBackoffMS       uint64     `yaml:"max_backoff_ms"`
+	BasicAuth          *BasicAuth `yaml:"basic_auth"`
+	BearerToken        string     `yaml:"bearer_token" expand:"true"`
+	BearerTokenFile    string     `yaml:"bearer_token_file" expand:"true"`
+	InsecureSkipVerify bool       `yaml:"insecure_skip_verify"`
+}
+
+// BasicAuth are HTTP basic auth credentials
+type BasicAuth struct {
+	Username     string `yaml:"username" expand:"true"`
+	Password     string `yaml:"password" expand:"true"`
+	PasswordFile string `yaml:"password_file" expand:"true"`
+}
+
+func (rw *RemoteWrite) loadDefaults() {
+	if rw.IntervalS == 0 {
+		rw.IntervalS = defaultRemoteWriteIntervalS
+	}
+
+	if rw.TimeoutS == 0 {
+		rw.TimeoutS = defaultRemoteWriteTimeoutS
+	}
+
+	if rw.BatchSize == 0 {
+		rw.BatchSize = defaultRemoteWriteBatchSize
+	}
+
+	if rw.QueueCapacity == 0 {
+		rw.QueueCapacity = defaultRemoteWriteQueueCapacity
+	}
+
+	if rw.MaxRetries == nil {
+		x := defaultRemoteWriteMaxRetries
+		rw.MaxRetries = &x
+	}
+
+	if rw.MinBackoffMS == 0 {
+		rw.MinBackoffMS = defaultRemoteWriteMinBackoffMS
+	}
+
+	if rw.MaxBackoffMS == 0 {
+		rw.MaxBackoffMS = defaultRemoteWriteMaxBackoffMS
+	}
+}
+
+func (rw *RemoteWrite) validate() error {
+	u, err := url.Parse(rw.URL)
+	if err != nil {
+		return fmt.Errorf("invalid remote write url: %v", err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid remote write url %q: scheme must be http or https", rw.URL)
+	}
+
+	if rw.BatchSize < 0 || rw.QueueCapacity < rw.BatchSize {
+		return fmt.Errorf("remote write queue_capacity (%d) must not be smaller than batch_size (%d)", rw.QueueCapacity, rw.BatchSize)
+	}
+
+	if rw.MinBackoffMS > rw.MaxBackoffMS {
+		return fmt.Errorf("remote write min_backoff_ms must not be greater than max_backoff_ms")
+	}
+
+	authMethods := 0
+	if rw.BasicAuth != nil {
+		authMethods++
+	}
+
+	if rw.BearerToken != "" {
+		authMethods++
+	}
+
+	if rw.BearerTokenFile != "" {
+		authMethods++
+	}
+
+	if authMethods > 1 {
+		return fmt.Errorf("at most one of remote write basic_auth, bearer_token and bearer_token_file must be configured")
+	}
+
+	if rw.BasicAuth != nil && rw.BasicAuth.Password != "" && rw.BasicAuth.PasswordFile != "" {
+		return fmt.Errorf("at most one of remote write basic_auth password and password_file must be configured")
+	}
+
+	return nil
+}
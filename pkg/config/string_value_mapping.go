@@ -0,0 +1,69 @@
+package config
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/pathmatch"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// StringValueMapping maps string values to numeric values. Keys are exact paths,
+// glob patterns (e.g. /interfaces/**/oper-status) or regular expressions prefixed with `~`.
+type StringValueMapping map[string]*StringValueMap
+
+// StringValueMap maps the string values of all paths matching a pattern
+type StringValueMap struct {
+	Values          map[string]float64 `yaml:"values"`
+	Default         *float64           `yaml:"default"`
+	CaseInsensitive bool               `yaml:"case_insensitive"`
+}
+
+// UnmarshalYAML accepts both the short form (a plain map of values) and the long form
+// (values, default and case_insensitive). The long form is used if any of its keys is present.
+func (m *StringValueMap) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	raw := make(map[string]interface{})
+	err := unmarshal(&raw)
+	if err != nil {
+		return err
+	}
+
+	long := false
+	for _, key := range []string{"values", "default", "case_insensitive"} {
+		if _, ok := raw[key]; ok {
+			long = true
+		}
+	}
+
+	if !long {
+		return unmarshal(&m.Values)
+	}
+
+	type plain StringValueMap
+	return unmarshal((*plain)(m))
+}
+
+func (svm StringValueMapping) validate() error {
+	for pattern := range svm {
+		_, err := pathmatch.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid string_value_mapping key: %v", err)
+		}
+	}
+
+	return nil
+}
+
+//go:embed builtin_string_value_mapping.yml
+var builtinStringValueMapping []byte
+
+// BuiltinStringValueMapping returns the built-in mapping of common OpenConfig enumerations
+func BuiltinStringValueMapping() StringValueMapping {
+	svm := make(StringValueMapping)
+	err := yaml.Unmarshal(builtinStringValueMapping, &svm)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in string value mapping: %v", err))
+	}
+
+	return svm
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// TLS is a TLS client configuration. Connections are unencrypted if it is omitted.
+type TLS struct {
+	CAFile             string `yaml:"ca_file" expand:"true"`
+	CertFile           string `yaml:"cert_file" expand:"true"`
+	KeyFile            string `yaml:"key_file" expand:"true"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+func (t *TLS) validate() error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("tls cert_file and key_file must be configured together")
+	}
+
+	return nil
+}
+
+// ClientConfig loads the configured files and returns the resulting tls.Config
+func (t *TLS) ClientConfig() (*tls.Config, error) {
+	res := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		b, err := ioutil.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file: %v", err)
+		}
+
+		res.RootCAs = x509.NewCertPool()
+		if !res.RootCAs.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in CA file %q", t.CAFile)
+		}
+	}
+
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %v", err)
+		}
+
+		res.Certificates = []tls.Certificate{cert}
+	}
+
+	return res, nil
+}
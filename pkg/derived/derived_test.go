@@ -0,0 +1,233 @@
+package derived
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testInput = map[string][]Sample{
+	"/interfaces/interface/state/counters/in-octets": {
+		{Labels: map[string]string{"device": "r1", "interface_name": "xe-0/0/0"}, Value: 1000},
+		{Labels: map[string]string{"device": "r1", "interface_name": "xe-0/0/1"}, Value: 2000},
+		{Labels: map[string]string{"device": "r1", "interface_name": "ae0"}, Value: 3000},
+	},
+	"/interfaces/interface/ethernet/state/port-speed": {
+		{Labels: map[string]string{"device": "r1", "interface_name": "xe-0/0/0"}, Value: 10000},
+		{Labels: map[string]string{"device": "r1", "interface_name": "xe-0/0/1"}, Value: 16000},
+	},
+	"/interfaces/interface/subinterfaces/subinterface/state/counters/in-errors": {
+		{Labels: map[string]string{"device": "r1", "interface_name": "xe-0/0/0", "subinterface_index": "0"}, Value: 1},
+		{Labels: map[string]string{"device": "r1", "interface_name": "xe-0/0/0", "subinterface_index": "1"}, Value: 2},
+		{Labels: map[string]string{"device": "r1", "interface_name": "xe-0/0/1", "subinterface_index": "0"}, Value: 5},
+	},
+	"/system/state/speed": {
+		{Labels: map[string]string{"device": "r1"}, Value: 100},
+	},
+}
+
+func testSamples(sel *Selector) []Sample {
+	res := make([]Sample, 0)
+	for path, samples := range testInput {
+		if sel.MatchPath(path) {
+			res = append(res, samples...)
+		}
+	}
+
+	return res
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		expected []Sample
+		wantFail bool
+	}{
+		{
+			name: "Number",
+			expr: "1 + 2 * 3 - -1",
+			expected: []Sample{
+				{Labels: map[string]string{}, Value: 8},
+			},
+		},
+		{
+			name: "Parenthesis",
+			expr: "(1 + 2) * 3 / 2",
+			expected: []Sample{
+				{Labels: map[string]string{}, Value: 4.5},
+			},
+		},
+		{
+			name: "Utilization",
+			expr: "/interfaces/interface/state/counters/in-octets * 8 / /interfaces/interface/ethernet/state/port-speed",
+			expected: []Sample{
+				{Labels: map[string]string{"device": "r1", "interface_name": "xe-0/0/0"}, Value: 0.8},
+				{Labels: map[string]string{"device": "r1", "interface_name": "xe-0/0/1"}, Value: 1},
+			},
+		},
+		{
+			name: "Label matcher",
+			expr: `/interfaces/interface/state/counters/in-octets{interface_name=~"xe-.*", interface_name!="xe-0/0/1"}`,
+			expected: []Sample{
+				{Labels: map[string]string{"device": "r1", "interface_name": "xe-0/0/0"}, Value: 1000},
+			},
+		},
+		{
+			name: "Sum by",
+			expr: "sum by (interface_name) (/interfaces/**/in-errors)",
+			expected: []Sample{
+				{Labels: map[string]string{"interface_name": "xe-0/0/0"}, Value: 3},
+				{Labels: map[string]string{"interface_name": "xe-0/0/1"}, Value: 5},
+			},
+		},
+		{
+			name: "Max without",
+			expr: "max(/interfaces/**/in-errors) without (subinterface_index)",
+			expected: []Sample{
+				{Labels: map[string]string{"device": "r1", "interface_name": "xe-0/0/0"}, Value: 2},
+				{Labels: map[string]string{"device": "r1", "interface_name": "xe-0/0/1"}, Value: 5},
+			},
+		},
+		{
+			name: "Count",
+			expr: "count(/interfaces/**/in-errors)",
+			expected: []Sample{
+				{Labels: map[string]string{}, Value: 3},
+			},
+		},
+		{
+			name: "Avg and min",
+			expr: "avg(/interfaces/**/in-errors) * 3 - min(/interfaces/**/in-errors)",
+			expected: []Sample{
+				{Labels: map[string]string{}, Value: 7},
+			},
+		},
+		{
+			name: "On",
+			expr: "/interfaces/interface/state/counters/in-octets / on(device) /system/state/speed",
+			expected: []Sample{
+				{Labels: map[string]string{"device": "r1", "interface_name": "xe-0/0/0"}, Value: 10},
+				{Labels: map[string]string{"device": "r1", "interface_name": "xe-0/0/1"}, Value: 20},
+				{Labels: map[string]string{"device": "r1", "interface_name": "ae0"}, Value: 30},
+			},
+		},
+		{
+			name: "Ignoring",
+			expr: "/interfaces/interface/ethernet/state/port-speed - ignoring(subinterface_index) /interfaces/**/in-errors{subinterface_index='0'}",
+			expected: []Sample{
+				{Labels: map[string]string{"device": "r1", "interface_name": "xe-0/0/0"}, Value: 9999},
+				{Labels: map[string]string{"device": "r1", "interface_name": "xe-0/0/1"}, Value: 15995},
+			},
+		},
+		{
+			name:     "Multiple matches",
+			expr:     "/system/state/speed / ignoring(subinterface_index) /interfaces/**/in-errors",
+			wantFail: true,
+		},
+		{
+			name:     "Aggregate number",
+			expr:     "sum(1)",
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		e, err := Parse(test.expr)
+		if err != nil {
+			t.Errorf("Unexpected parse failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		res, err := e.Eval(testSamples)
+		if test.wantFail {
+			if err != nil {
+				continue
+			}
+
+			t.Errorf("Unexpected success for test %q", test.name)
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		assert.ElementsMatch(t, test.expected, res, test.name)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		expr      string
+		selectors []string
+		wantFail  bool
+	}{
+		{
+			name:      "Selectors",
+			expr:      "/a/b{x='1'} * 8 / ~/c/.*",
+			selectors: []string{"/a/b", "~/c/.*"},
+		},
+		{
+			name:     "Missing operand",
+			expr:     "/a/b *",
+			wantFail: true,
+		},
+		{
+			name:     "Path without whitespace",
+			expr:     "8/a/b",
+			wantFail: true,
+		},
+		{
+			name:     "Unbalanced parenthesis",
+			expr:     "(1 + 2",
+			wantFail: true,
+		},
+		{
+			name:     "Invalid label matcher",
+			expr:     "/a/b{x=1}",
+			wantFail: true,
+		},
+		{
+			name:     "Invalid regex",
+			expr:     "/a/b{x=~'('}",
+			wantFail: true,
+		},
+		{
+			name:     "Unknown function",
+			expr:     "rate(/a/b)",
+			wantFail: true,
+		},
+		{
+			name:     "Unterminated string",
+			expr:     "/a/b{x='1}",
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		e, err := Parse(test.expr)
+		if test.wantFail {
+			if err != nil {
+				continue
+			}
+
+			t.Errorf("Unexpected success for test %q", test.name)
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		selectors := make([]string, 0)
+		for _, s := range e.Selectors() {
+			selectors = append(selectors, s.String())
+		}
+
+		assert.Equal(t, test.selectors, selectors, test.name)
+	}
+}
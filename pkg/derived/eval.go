@@ -0,0 +1,273 @@
+package derived
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Sample is a labeled value
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// result is either a number or a vector
+type result struct {
+	scalar bool
+	value  float64
+	vector []Sample
+}
+
+// Eval evaluates e. input returns all samples with a path selected by the selector (label matchers are applied by Eval).
+// A number is returned as a single sample without labels.
+func (e *Expr) Eval(input func(sel *Selector) []Sample) ([]Sample, error) {
+	r, err := eval(e.root, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.scalar {
+		return []Sample{{Labels: map[string]string{}, Value: r.value}}, nil
+	}
+
+	return r.vector, nil
+}
+
+func eval(n node, input func(sel *Selector) []Sample) (result, error) {
+	switch n := n.(type) {
+	case *numberNode:
+		return result{scalar: true, value: n.value}, nil
+	case *selectorNode:
+		res := make([]Sample, 0)
+		for _, s := range input(n.sel) {
+			if n.sel.matchLabels(s.Labels) {
+				res = append(res, s)
+			}
+		}
+
+		return result{vector: res}, nil
+	case *negNode:
+		r, err := eval(n.arg, input)
+		if err != nil {
+			return r, err
+		}
+
+		return apply(r, func(v float64) float64 { return -v }), nil
+	case *binaryNode:
+		return evalBinary(n, input)
+	case *aggregateNode:
+		return evalAggregate(n, input)
+	}
+
+	return result{}, fmt.Errorf("unknown node %T", n)
+}
+
+func apply(r result, f func(float64) float64) result {
+	if r.scalar {
+		return result{scalar: true, value: f(r.value)}
+	}
+
+	res := make([]Sample, len(r.vector))
+	for i, s := range r.vector {
+		res[i] = Sample{Labels: s.Labels, Value: f(s.Value)}
+	}
+
+	return result{vector: res}
+}
+
+func arith(op byte, a float64, b float64) float64 {
+	switch op {
+	case '+':
+		return a + b
+	case '-':
+		return a - b
+	case '*':
+		return a * b
+	case '/':
+		return a / b
+	}
+
+	return math.NaN()
+}
+
+func evalBinary(n *binaryNode, input func(sel *Selector) []Sample) (result, error) {
+	lhs, err := eval(n.lhs, input)
+	if err != nil {
+		return lhs, err
+	}
+
+	rhs, err := eval(n.rhs, input)
+	if err != nil {
+		return rhs, err
+	}
+
+	switch {
+	case lhs.scalar && rhs.scalar:
+		return result{scalar: true, value: arith(n.op, lhs.value, rhs.value)}, nil
+	case rhs.scalar:
+		return apply(lhs, func(v float64) float64 { return arith(n.op, v, rhs.value) }), nil
+	case lhs.scalar:
+		return apply(rhs, func(v float64) float64 { return arith(n.op, lhs.value, v) }), nil
+	}
+
+	index := make(map[string]Sample, len(rhs.vector))
+	for _, s := range rhs.vector {
+		sig := signature(s.Labels, n.matching)
+		if _, ok := index[sig]; ok {
+			return result{}, fmt.Errorf("multiple matches for labels %s on the right hand side", sig)
+		}
+
+		index[sig] = s
+	}
+
+	res := make([]Sample, 0, len(lhs.vector))
+	for _, s := range lhs.vector {
+		other, ok := index[signature(s.Labels, n.matching)]
+		if !ok {
+			continue
+		}
+
+		res = append(res, Sample{
+			Labels: s.Labels,
+			Value:  arith(n.op, s.Value, other.Value),
+		})
+	}
+
+	return result{vector: res}, nil
+}
+
+// signature returns a string identifying the labels relevant for matching
+func signature(labels map[string]string, m *matching) string {
+	names := make([]string, 0, len(labels))
+	switch {
+	case m != nil && m.on:
+		names = append(names, m.labels...)
+	case m != nil:
+		for name := range labels {
+			if !contains(m.labels, name) {
+				names = append(names, name)
+			}
+		}
+	default:
+		for name := range labels {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("{")
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+
+		sb.WriteString(fmt.Sprintf("%s=%q", name, labels[name]))
+	}
+	sb.WriteString("}")
+
+	return sb.String()
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+type group struct {
+	labels map[string]string
+	values []float64
+}
+
+func evalAggregate(n *aggregateNode, input func(sel *Selector) []Sample) (result, error) {
+	r, err := eval(n.arg, input)
+	if err != nil {
+		return r, err
+	}
+
+	if r.scalar {
+		return result{}, fmt.Errorf("%s expects a vector", n.op)
+	}
+
+	m := &matching{on: !n.without, labels: n.labels}
+	groups := make(map[string]*group)
+	order := make([]string, 0)
+	for _, s := range r.vector {
+		sig := signature(s.Labels, m)
+		g, ok := groups[sig]
+		if !ok {
+			g = &group{
+				labels: groupLabels(s.Labels, m),
+			}
+
+			groups[sig] = g
+			order = append(order, sig)
+		}
+
+		g.values = append(g.values, s.Value)
+	}
+
+	res := make([]Sample, 0, len(groups))
+	for _, sig := range order {
+		g := groups[sig]
+		res = append(res, Sample{
+			Labels: g.labels,
+			Value:  aggregate(n.op, g.values),
+		})
+	}
+
+	return result{vector: res}, nil
+}
+
+func groupLabels(labels map[string]string, m *matching) map[string]string {
+	res := make(map[string]string)
+	for name, v := range labels {
+		if contains(m.labels, name) == m.on {
+			res[name] = v
+		}
+	}
+
+	return res
+}
+
+func aggregate(op string, values []float64) float64 {
+	switch op {
+	case "count":
+		return float64(len(values))
+	case "sum", "avg":
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+
+		if op == "avg" {
+			return sum / float64(len(values))
+		}
+
+		return sum
+	case "min":
+		res := values[0]
+		for _, v := range values[1:] {
+			res = math.Min(res, v)
+		}
+
+		return res
+	case "max":
+		res := values[0]
+		for _, v := range values[1:] {
+			res = math.Max(res, v)
+		}
+
+		return res
+	}
+
+	return math.NaN()
+}
@@ -0,0 +1,552 @@
+// Package derived implements a small expression language to derive metrics from telemetry series.
+//
+// Expressions operate on vectors of samples selected by path and on numbers:
+//
+//	/interfaces/interface/state/counters/in-octets * 8
+//	sum by (interface_name) (/interfaces/interface/subinterfaces/subinterface/state/counters/in-errors)
+//	/interfaces/interface/state/oper-status{interface_name=~"xe-.*"} - 1
+//
+// Paths are matched like string_value_mapping paths (exact, globs or regular expressions prefixed with `~`)
+// against the path of a series without list keys. A path may be followed by label matchers
+// (`=`, `!=`, `=~`, `!~`). As paths may contain `-`, `*` and `/` they have to be followed by whitespace
+// or label matchers. Supported operators are `+`, `-`, `*` and `/` (optionally followed by `on(labels)`
+// or `ignoring(labels)`) and the aggregations sum, min, max, avg and count (optionally with `by (labels)`
+// or `without (labels)`).
+//
+// Expressions are evaluated on the current values. There is no rate function, so e.g. the utilization of an
+// interface can not be expressed.
+package derived
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/pathmatch"
+)
+
+// Aggregations
+var aggregations = map[string]struct{}{
+	"sum":   {},
+	"min":   {},
+	"max":   {},
+	"avg":   {},
+	"count": {},
+}
+
+// Expr is a parsed expression
+type Expr struct {
+	root      node
+	selectors []*Selector
+}
+
+// Selector selects samples by path and labels
+type Selector struct {
+	path     *pathmatch.Matcher
+	matchers []*labelMatcher
+}
+
+type labelMatcher struct {
+	name  string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+type node interface{}
+
+type numberNode struct {
+	value float64
+}
+
+type selectorNode struct {
+	sel *Selector
+}
+
+type negNode struct {
+	arg node
+}
+
+type binaryNode struct {
+	op       byte
+	lhs      node
+	rhs      node
+	matching *matching
+}
+
+// matching describes how samples of two vectors are matched
+type matching struct {
+	on     bool
+	labels []string
+}
+
+type aggregateNode struct {
+	op      string
+	without bool
+	labels  []string
+	arg     node
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenPath
+	tokenIdent
+	tokenString
+	tokenPunct
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value string
+	pos   int
+}
+
+// Parse parses an expression
+func Parse(input string) (*Expr, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{
+		tokens: tokens,
+		expr:   &Expr{},
+	}
+
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+	}
+
+	p.expr.root = root
+	return p.expr, nil
+}
+
+// Selectors returns all selectors of the expression
+func (e *Expr) Selectors() []*Selector {
+	return e.selectors
+}
+
+// MatchPath reports whether path is selected by s
+func (s *Selector) MatchPath(path string) bool {
+	return s.path.Match(path)
+}
+
+// String returns the path pattern of s
+func (s *Selector) String() string {
+	return s.path.String()
+}
+
+func (s *Selector) matchLabels(labels map[string]string) bool {
+	for _, m := range s.matchers {
+		v := labels[m.name]
+		switch m.op {
+		case "=":
+			if v != m.value {
+				return false
+			}
+		case "!=":
+			if v == m.value {
+				return false
+			}
+		case "=~":
+			if !m.re.MatchString(v) {
+				return false
+			}
+		case "!~":
+			if m.re.MatchString(v) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func lex(input string) ([]token, error) {
+	res := make([]token, 0)
+	i := 0
+	for i < len(input) {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case (c == '/' || c == '~') && i+1 < len(input) && !isSpace(input[i+1]):
+			start := i
+			for i < len(input) && !isSpace(input[i]) && !strings.ContainsRune("{}(),", rune(input[i])) {
+				i++
+			}
+
+			res = append(res, token{kind: tokenPath, text: input[start:i], pos: start})
+		case c >= '0' && c <= '9' || c == '.':
+			start := i
+			for i < len(input) && (isIdentChar(input[i]) || input[i] == '.' || (input[i] == '+' || input[i] == '-') && (input[i-1] == 'e' || input[i-1] == 'E')) {
+				i++
+			}
+
+			res = append(res, token{kind: tokenNumber, text: input[start:i], pos: start})
+		case isIdentStart(c):
+			start := i
+			for i < len(input) && isIdentChar(input[i]) {
+				i++
+			}
+
+			res = append(res, token{kind: tokenIdent, text: input[start:i], pos: start})
+		case c == '"' || c == '\'':
+			start := i
+			i++
+			for i < len(input) && input[i] != c {
+				if input[i] == '\\' {
+					i++
+				}
+
+				i++
+			}
+
+			if i >= len(input) {
+				return nil, fmt.Errorf("unterminated string at offset %d", start)
+			}
+
+			i++
+			text := input[start:i]
+			value, err := unquote(text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at offset %d: %v", start, err)
+			}
+
+			res = append(res, token{kind: tokenString, text: text, value: value, pos: start})
+		case c == '!' || c == '=':
+			start := i
+			i++
+			if i < len(input) && (input[i] == '=' || input[i] == '~') {
+				i++
+			}
+
+			res = append(res, token{kind: tokenPunct, text: input[start:i], pos: start})
+		case strings.ContainsRune("(){},+-*/", rune(c)):
+			res = append(res, token{kind: tokenPunct, text: string(c), pos: i})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected %q at offset %d", c, i)
+		}
+	}
+
+	return append(res, token{kind: tokenEOF, text: "end of input", pos: len(input)}), nil
+}
+
+func unquote(s string) (string, error) {
+	if s[0] == '\'' {
+		s = `"` + strings.Replace(strings.Replace(s[1:len(s)-1], `\'`, `'`, -1), `"`, `\"`, -1) + `"`
+	}
+
+	return strconv.Unquote(s)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isIdentStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9'
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	expr   *Expr
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *parser) isPunct(text string) bool {
+	t := p.peek()
+	return t.kind == tokenPunct && t.text == text
+}
+
+func (p *parser) expect(text string) error {
+	t := p.next()
+	if t.kind != tokenPunct || t.text != text {
+		return fmt.Errorf("expected %q at offset %d, got %q", text, t.pos, t.text)
+	}
+
+	return nil
+}
+
+// parseExpr parses additions and subtractions
+func (p *parser) parseExpr() (node, error) {
+	lhs, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isPunct("+") || p.isPunct("-") {
+		op := p.next().text[0]
+		m, err := p.parseMatching()
+		if err != nil {
+			return nil, err
+		}
+
+		rhs, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+
+		lhs = &binaryNode{op: op, lhs: lhs, rhs: rhs, matching: m}
+	}
+
+	return lhs, nil
+}
+
+// parseTerm parses multiplications and divisions
+func (p *parser) parseTerm() (node, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isPunct("*") || p.isPunct("/") {
+		op := p.next().text[0]
+		m, err := p.parseMatching()
+		if err != nil {
+			return nil, err
+		}
+
+		rhs, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		lhs = &binaryNode{op: op, lhs: lhs, rhs: rhs, matching: m}
+	}
+
+	return lhs, nil
+}
+
+func (p *parser) parseMatching() (*matching, error) {
+	t := p.peek()
+	if t.kind != tokenIdent || (t.text != "on" && t.text != "ignoring") {
+		return nil, nil
+	}
+
+	p.next()
+	labels, err := p.parseLabelList()
+	if err != nil {
+		return nil, err
+	}
+
+	return &matching{on: t.text == "on", labels: labels}, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isPunct("-") {
+		p.next()
+		arg, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &negNode{arg: arg}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", t.text, t.pos)
+		}
+
+		return &numberNode{value: v}, nil
+	case tokenPath:
+		return p.parseSelector(t)
+	case tokenIdent:
+		if _, ok := aggregations[t.text]; ok {
+			return p.parseAggregation(t.text)
+		}
+	case tokenPunct:
+		if t.text == "(" {
+			n, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+
+			return n, p.expect(")")
+		}
+	}
+
+	return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+}
+
+func (p *parser) parseSelector(t token) (node, error) {
+	m, err := pathmatch.Compile(t.text)
+	if err != nil {
+		return nil, err
+	}
+
+	sel := &Selector{
+		path: m,
+	}
+
+	if p.isPunct("{") {
+		p.next()
+		for !p.isPunct("}") {
+			lm, err := p.parseLabelMatcher()
+			if err != nil {
+				return nil, err
+			}
+
+			sel.matchers = append(sel.matchers, lm)
+			if !p.isPunct(",") {
+				break
+			}
+
+			p.next()
+		}
+
+		err = p.expect("}")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	p.expr.selectors = append(p.expr.selectors, sel)
+	return &selectorNode{sel: sel}, nil
+}
+
+func (p *parser) parseLabelMatcher() (*labelMatcher, error) {
+	name := p.next()
+	if name.kind != tokenIdent {
+		return nil, fmt.Errorf("expected label name at offset %d, got %q", name.pos, name.text)
+	}
+
+	op := p.next()
+	if op.kind != tokenPunct || (op.text != "=" && op.text != "!=" && op.text != "=~" && op.text != "!~") {
+		return nil, fmt.Errorf("expected label matcher at offset %d, got %q", op.pos, op.text)
+	}
+
+	v := p.next()
+	if v.kind != tokenString {
+		return nil, fmt.Errorf("expected string at offset %d, got %q", v.pos, v.text)
+	}
+
+	lm := &labelMatcher{
+		name:  name.text,
+		op:    op.text,
+		value: v.value,
+	}
+
+	if op.text == "=~" || op.text == "!~" {
+		re, err := regexp.Compile("^(?:" + v.value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %v", v.value, err)
+		}
+
+		lm.re = re
+	}
+
+	return lm, nil
+}
+
+func (p *parser) parseAggregation(op string) (node, error) {
+	n := &aggregateNode{
+		op: op,
+	}
+
+	err := p.parseGrouping(n)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.expect("(")
+	if err != nil {
+		return nil, err
+	}
+
+	n.arg, err = p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.expect(")")
+	if err != nil {
+		return nil, err
+	}
+
+	if n.labels == nil {
+		err = p.parseGrouping(n)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return n, nil
+}
+
+func (p *parser) parseGrouping(n *aggregateNode) error {
+	t := p.peek()
+	if t.kind != tokenIdent || (t.text != "by" && t.text != "without") {
+		return nil
+	}
+
+	p.next()
+	labels, err := p.parseLabelList()
+	if err != nil {
+		return err
+	}
+
+	n.without = t.text == "without"
+	n.labels = labels
+	return nil
+}
+
+func (p *parser) parseLabelList() ([]string, error) {
+	err := p.expect("(")
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make([]string, 0)
+	for !p.isPunct(")") {
+		t := p.next()
+		if t.kind != tokenIdent {
+			return nil, fmt.Errorf("expected label name at offset %d, got %q", t.pos, t.text)
+		}
+
+		labels = append(labels, t.text)
+		if !p.isPunct(",") {
+			break
+		}
+
+		p.next()
+	}
+
+	return labels, p.expect(")")
+}
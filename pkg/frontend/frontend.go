@@ -1,8 +1,10 @@
 package frontend
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/collector"
 	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
@@ -16,6 +18,7 @@ import (
 type Frontend struct {
 	cfg       *config.Config
 	collector *collector.Collector
+	stats     []prometheus.Collector
 }
 
 // New creates a new HTTP frontend
@@ -26,6 +29,11 @@ func New(cfg *config.Config, collector *collector.Collector) *Frontend {
 	}
 }
 
+// AddStats adds a collector of internal metrics exposed along with the collector. It has to be called before Start.
+func (fe *Frontend) AddStats(c prometheus.Collector) {
+	fe.stats = append(fe.stats, c)
+}
+
 // Start starts the frontend
 func (fe *Frontend) Start() {
 	log.Infof("Starting OpenConfig Streaming Telemetry Exporter (Version: %s)\n", fe.cfg.Version)
@@ -42,6 +50,7 @@ func (fe *Frontend) Start() {
 	})
 	http.HandleFunc(fe.cfg.MetricsPath, fe.handleMetricsRequest)
 	http.HandleFunc("/debug/dump", fe.handleDumpRequest)
+	http.HandleFunc("/api/record", fe.handleRecordRequest)
 
 	log.Infof("Listening for %s on %s\n", fe.cfg.MetricsPath, fe.cfg.ListenAddress)
 	log.Fatal(http.ListenAndServe(fe.cfg.ListenAddress, nil))
@@ -53,9 +62,39 @@ func (fe *Frontend) handleDumpRequest(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleRecordRequest returns the recording state of all targets (GET) or starts and stops recording the
+// stream of a target (POST with parameters target and enabled)
+func (fe *Frontend) handleRecordRequest(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		enabled, err := strconv.ParseBool(r.FormValue("enabled"))
+		if err != nil {
+			http.Error(w, "invalid parameter enabled", http.StatusBadRequest)
+			return
+		}
+
+		err = fe.collector.SetRecording(r.FormValue("target"), enabled)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fe.collector.Recordings())
+}
+
 func (fe *Frontend) handleMetricsRequest(w http.ResponseWriter, r *http.Request) {
 	reg := prometheus.NewRegistry()
 	reg.MustRegister(fe.collector)
+	reg.MustRegister(fe.collector.Stats())
+	for _, c := range fe.stats {
+		reg.MustRegister(c)
+	}
 
 	promhttp.HandlerFor(reg, promhttp.HandlerOpts{
 		ErrorLog:      promlog.NewErrorLogger(),
@@ -0,0 +1,204 @@
+// Package influxdb writes updates as InfluxDB line protocol over HTTP or UDP
+package influxdb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/output"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Writer is a sink writing all updates to InfluxDB
+type Writer struct {
+	cfg      *config.InfluxDB
+	writeURL string
+	client   *http.Client
+	udpConn  net.Conn
+	queue    chan []byte
+	stats    *stats
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// New creates a new Writer
+func New(cfg *config.InfluxDB) (*Writer, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Writer{
+		cfg:    cfg,
+		queue:  make(chan []byte, cfg.QueueCapacity),
+		stopCh: make(chan struct{}),
+	}
+
+	w.stats = newStats(w.queue)
+
+	if u.Scheme == "udp" {
+		w.udpConn, err = net.Dial("udp", u.Host)
+		if err != nil {
+			return nil, fmt.Errorf("unable to dial %s: %v", u.Host, err)
+		}
+
+		return w, nil
+	}
+
+	q := url.Values{}
+	q.Set("db", cfg.Database)
+	q.Set("precision", cfg.Precision)
+	if cfg.RetentionPolicy != "" {
+		q.Set("rp", cfg.RetentionPolicy)
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/write"
+	u.RawQuery = q.Encode()
+	w.writeURL = u.String()
+	w.client = &http.Client{
+		Timeout: time.Duration(cfg.TimeoutS) * time.Second,
+	}
+
+	return w, nil
+}
+
+// Start starts writing
+func (w *Writer) Start() {
+	w.wg.Add(1)
+	go w.loop()
+}
+
+// Stop stops writing. Buffered points are flushed, queued points are discarded.
+func (w *Writer) Stop() {
+	close(w.stopCh)
+	w.wg.Wait()
+
+	if w.udpConn != nil {
+		w.udpConn.Close()
+	}
+}
+
+// Stats returns a prometheus collector exposing the internal metrics of the writer
+func (w *Writer) Stats() prometheus.Collector {
+	return w.stats
+}
+
+// Write queues updates. Updates exceeding the queue are dropped.
+func (w *Writer) Write(updates []*output.Update) {
+	for _, u := range updates {
+		line, ok := appendLine(nil, u, w.cfg.Precision)
+		if !ok {
+			w.stats.skippedPoints.Inc()
+			continue
+		}
+
+		select {
+		case w.queue <- line:
+		default:
+			w.stats.droppedPoints.Inc()
+		}
+	}
+}
+
+func (w *Writer) loop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(time.Duration(w.cfg.FlushIntervalMS) * time.Millisecond)
+	defer ticker.Stop()
+
+	batch := make([][]byte, 0, w.cfg.BatchSize)
+	for {
+		select {
+		case <-w.stopCh:
+			w.flush(batch)
+			return
+		case line := <-w.queue:
+			batch = append(batch, line)
+			if len(batch) < w.cfg.BatchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+
+		w.flush(batch)
+		batch = batch[:0]
+	}
+}
+
+func (w *Writer) flush(batch [][]byte) {
+	if len(batch) == 0 {
+		return
+	}
+
+	var err error
+	if w.udpConn != nil {
+		err = w.sendUDP(batch)
+	} else {
+		err = w.sendHTTP(bytes.Join(batch, nil))
+	}
+
+	if err != nil {
+		log.Errorf("InfluxDB: dropping %d points: %v", len(batch), err)
+		w.stats.failedPoints.Add(float64(len(batch)))
+		return
+	}
+
+	w.stats.writtenPoints.Add(float64(len(batch)))
+}
+
+func (w *Writer) sendHTTP(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.writeURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if w.cfg.Username != "" {
+		req.SetBasicAuth(w.cfg.Username, w.cfg.Password)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 256))
+	return fmt.Errorf("server returned HTTP status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// sendUDP sends the lines of batch in datagrams of at most MaxPayload bytes. Longer lines are sent on their own.
+func (w *Writer) sendUDP(batch [][]byte) error {
+	buf := make([]byte, 0, w.cfg.MaxPayload)
+	for _, line := range batch {
+		if len(buf) > 0 && len(buf)+len(line) > w.cfg.MaxPayload {
+			_, err := w.udpConn.Write(buf)
+			if err != nil {
+				return err
+			}
+
+			buf = buf[:0]
+		}
+
+		buf = append(buf, line...)
+	}
+
+	_, err := w.udpConn.Write(buf)
+	return err
+}
@@ -0,0 +1,123 @@
+package influxdb
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/output"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+var testUpdates = []*output.Update{
+	{
+		Path:      "/interfaces/interface/state/counters/in-octets",
+		Labels:    map[string]string{"device": "r1", "interface_name": "xe-0/0/0"},
+		Value:     uint64(1000),
+		Timestamp: time.Unix(1600000000, 0),
+	},
+	{
+		Path:      "/interfaces/interface/state/counters/in-octets",
+		Labels:    map[string]string{"device": "r1", "interface_name": "xe-0/0/1"},
+		Value:     uint64(2000),
+		Timestamp: time.Unix(1600000000, 0),
+	},
+}
+
+func TestWriterHTTP(t *testing.T) {
+	type request struct {
+		query string
+		user  string
+		body  string
+	}
+
+	requests := make(chan request, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		b, _ := ioutil.ReadAll(r.Body)
+		requests <- request{
+			query: r.URL.Path + "?" + r.URL.RawQuery,
+			user:  user,
+			body:  string(b),
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	w, err := New(&config.InfluxDB{
+		URL:             srv.URL,
+		Database:        "telemetry",
+		Username:        "exporter",
+		Password:        "secret",
+		Precision:       "s",
+		BatchSize:       2,
+		FlushIntervalMS: 60000,
+		QueueCapacity:   10,
+		TimeoutS:        5,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	w.Start()
+	w.Write(testUpdates)
+
+	select {
+	case r := <-requests:
+		assert.Equal(t, request{
+			query: "/write?db=telemetry&precision=s",
+			user:  "exporter",
+			body:  "/interfaces/interface/state/counters/in-octets,device=r1,interface_name=xe-0/0/0 value=1000i 1600000000\n/interfaces/interface/state/counters/in-octets,device=r1,interface_name=xe-0/0/1 value=2000i 1600000000\n",
+		}, r)
+	case <-time.After(5 * time.Second):
+		t.Errorf("Timeout")
+	}
+
+	w.Stop()
+	assert.Equal(t, float64(2), testutil.ToFloat64(w.stats.writtenPoints))
+}
+
+func TestWriterUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer conn.Close()
+
+	w, err := New(&config.InfluxDB{
+		URL:             "udp://" + conn.LocalAddr().String(),
+		Precision:       "s",
+		BatchSize:       10,
+		FlushIntervalMS: 10,
+		QueueCapacity:   10,
+		// Only one line fits into a datagram
+		MaxPayload: 100,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	w.Start()
+	defer w.Stop()
+	w.Write(testUpdates)
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1500)
+	for _, expected := range []string{
+		"/interfaces/interface/state/counters/in-octets,device=r1,interface_name=xe-0/0/0 value=1000i 1600000000\n",
+		"/interfaces/interface/state/counters/in-octets,device=r1,interface_name=xe-0/0/1 value=2000i 1600000000\n",
+	} {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+
+		assert.Equal(t, expected, string(buf[:n]))
+	}
+}
@@ -0,0 +1,89 @@
+package influxdb
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/output"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+	stringEscaper      = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// appendLine appends u as line protocol to b. The path becomes the measurement, labels become tags and the value
+// becomes the field "value". It returns false if the value can not be represented.
+func appendLine(b []byte, u *output.Update, precision string) ([]byte, bool) {
+	field, ok := fieldValue(u.Value)
+	if !ok {
+		return b, false
+	}
+
+	b = append(b, measurementEscaper.Replace(u.Path)...)
+
+	keys := make([]string, 0, len(u.Labels))
+	for k, v := range u.Labels {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		b = append(b, ',')
+		b = append(b, tagEscaper.Replace(k)...)
+		b = append(b, '=')
+		b = append(b, tagEscaper.Replace(u.Labels[k])...)
+	}
+
+	b = append(b, " value="...)
+	b = append(b, field...)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, timestamp(u.Timestamp, precision), 10)
+
+	return append(b, '\n'), true
+}
+
+func fieldValue(v interface{}) (string, bool) {
+	switch x := v.(type) {
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return "", false
+		}
+
+		return strconv.FormatFloat(x, 'g', -1, 64), true
+	case int64:
+		return strconv.FormatInt(x, 10) + "i", true
+	case uint64:
+		// Unsigned integers are not supported by InfluxDB 1.x
+		if x > math.MaxInt64 {
+			return strconv.FormatFloat(float64(x), 'g', -1, 64), true
+		}
+
+		return strconv.FormatUint(x, 10) + "i", true
+	case bool:
+		return strconv.FormatBool(x), true
+	case string:
+		return `"` + stringEscaper.Replace(x) + `"`, true
+	}
+
+	return "", false
+}
+
+func timestamp(t time.Time, precision string) int64 {
+	switch precision {
+	case "s":
+		return t.Unix()
+	case "ms":
+		return t.UnixNano() / int64(time.Millisecond)
+	case "us":
+		return t.UnixNano() / int64(time.Microsecond)
+	}
+
+	return t.UnixNano()
+}
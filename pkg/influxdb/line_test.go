@@ -0,0 +1,110 @@
+package influxdb
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/output"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendLine(t *testing.T) {
+	ts := time.Unix(1600000000, 123456789)
+
+	tests := []struct {
+		name      string
+		update    *output.Update
+		precision string
+		expected  string
+		wantFail  bool
+	}{
+		{
+			name: "Counter",
+			update: &output.Update{
+				Path:      "/interfaces/interface/state/counters/in-octets",
+				Labels:    map[string]string{"interface_name": "xe-0/0/0", "device": "r1"},
+				Value:     uint64(1000),
+				Timestamp: ts,
+			},
+			precision: "ms",
+			expected:  "/interfaces/interface/state/counters/in-octets,device=r1,interface_name=xe-0/0/0 value=1000i 1600000000123\n",
+		},
+		{
+			name: "Escaping",
+			update: &output.Update{
+				Path:      "/interfaces/interface/state/description",
+				Labels:    map[string]string{"customer": "a b,c=d", "empty": ""},
+				Value:     `say "hi" \o/`,
+				Timestamp: ts,
+			},
+			precision: "s",
+			expected:  `/interfaces/interface/state/description,customer=a\ b\,c\=d value="say \"hi\" \\o/" 1600000000` + "\n",
+		},
+		{
+			name: "Float",
+			update: &output.Update{
+				Path:      "/components/component/state/temperature/instant",
+				Value:     41.5,
+				Timestamp: ts,
+			},
+			precision: "ns",
+			expected:  "/components/component/state/temperature/instant value=41.5 1600000000123456789\n",
+		},
+		{
+			name: "Bool",
+			update: &output.Update{
+				Path:      "/system/state/up",
+				Value:     true,
+				Timestamp: ts,
+			},
+			precision: "us",
+			expected:  "/system/state/up value=true 1600000000123456\n",
+		},
+		{
+			name: "Large uint",
+			update: &output.Update{
+				Path:      "/a",
+				Value:     uint64(math.MaxUint64),
+				Timestamp: ts,
+			},
+			precision: "s",
+			expected:  "/a value=1.8446744073709552e+19 1600000000\n",
+		},
+		{
+			name: "NaN",
+			update: &output.Update{
+				Path:  "/a",
+				Value: math.NaN(),
+			},
+			wantFail: true,
+		},
+		{
+			name: "Bytes",
+			update: &output.Update{
+				Path:  "/a",
+				Value: []byte{1},
+			},
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		b, ok := appendLine(nil, test.update, test.precision)
+		if test.wantFail {
+			if !ok {
+				continue
+			}
+
+			t.Errorf("Unexpected success for test %q", test.name)
+			continue
+		}
+
+		if !ok {
+			t.Errorf("Unexpected failure for test %q", test.name)
+			continue
+		}
+
+		assert.Equal(t, test.expected, string(b), test.name)
+	}
+}
@@ -0,0 +1,31 @@
+package influxdb
+
+import (
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/output"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// stats are the internal metrics of the writer
+type stats struct {
+	*output.Stats
+	writtenPoints prometheus.Counter
+	failedPoints  prometheus.Counter
+	droppedPoints prometheus.Counter
+	skippedPoints prometheus.Counter
+}
+
+func newStats(queue chan []byte) *stats {
+	s := &stats{
+		Stats: output.NewStats("influxdb"),
+	}
+
+	s.writtenPoints = s.Counter("written_points_total", "Number of points written successfully")
+	s.failedPoints = s.Counter("failed_points_total", "Number of points that could not be written")
+	s.droppedPoints = s.Counter("dropped_points_total", "Number of points dropped because the queue was full")
+	s.skippedPoints = s.Counter("skipped_points_total", "Number of updates skipped because their value can not be represented as field")
+	s.QueueLength("Number of points waiting to be written", func() int {
+		return len(queue)
+	})
+
+	return s
+}
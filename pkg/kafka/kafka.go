@@ -0,0 +1,196 @@
+// Package kafka publishes updates as records to a Kafka topic
+package kafka
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/output"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var compressionCodecs = map[string]sarama.CompressionCodec{
+	"none":   sarama.CompressionNone,
+	"gzip":   sarama.CompressionGZIP,
+	"snappy": sarama.CompressionSnappy,
+	"lz4":    sarama.CompressionLZ4,
+	"zstd":   sarama.CompressionZSTD,
+}
+
+var requiredAcks = map[string]sarama.RequiredAcks{
+	"none":   sarama.NoResponse,
+	"leader": sarama.WaitForLocal,
+	"all":    sarama.WaitForAll,
+}
+
+// Producer is a sink publishing every update as a record keyed by the device
+type Producer struct {
+	cfg      *config.Kafka
+	producer sarama.AsyncProducer
+	marshal  func(u *output.Update) ([]byte, bool)
+	queue    chan *sarama.ProducerMessage
+	stats    *stats
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+	// forwardWg tracks the goroutine passing queued records to the producer
+	forwardWg sync.WaitGroup
+}
+
+// New creates a new Producer and connects to the brokers
+func New(cfg *config.Kafka) (*Producer, error) {
+	sc, err := saramaConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	producer, err := sarama.NewAsyncProducer(cfg.Brokers, sc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create producer: %v", err)
+	}
+
+	p := &Producer{
+		cfg:      cfg,
+		producer: producer,
+		marshal:  marshalJSON,
+		queue:    make(chan *sarama.ProducerMessage, cfg.QueueCapacity),
+		stopCh:   make(chan struct{}),
+	}
+
+	p.stats = newStats(p.queue)
+
+	if cfg.Encoding == config.KafkaEncodingProtobuf {
+		p.marshal = marshalProtobuf
+	}
+
+	return p, nil
+}
+
+func saramaConfig(cfg *config.Kafka) (*sarama.Config, error) {
+	version, err := sarama.ParseKafkaVersion(cfg.Version)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kafka version: %v", err)
+	}
+
+	timeout := time.Duration(cfg.TimeoutS) * time.Second
+
+	sc := sarama.NewConfig()
+	sc.ClientID = cfg.ClientID
+	sc.Version = version
+	sc.ChannelBufferSize = cfg.QueueCapacity
+	sc.Net.DialTimeout = timeout
+	sc.Net.ReadTimeout = timeout
+	sc.Net.WriteTimeout = timeout
+	sc.Producer.Timeout = timeout
+	sc.Producer.Partitioner = sarama.NewHashPartitioner
+	sc.Producer.Compression = compressionCodecs[cfg.Compression]
+	sc.Producer.RequiredAcks = requiredAcks[cfg.RequiredAcks]
+	sc.Producer.Flush.Frequency = time.Duration(cfg.FlushIntervalMS) * time.Millisecond
+	sc.Producer.Retry.Max = cfg.MaxRetries
+	sc.Producer.Return.Successes = true
+	sc.Producer.Return.Errors = true
+
+	if cfg.TLS != nil {
+		sc.Net.TLS.Enable = true
+		sc.Net.TLS.Config, err = cfg.TLS.ClientConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = sc.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid kafka config: %v", err)
+	}
+
+	return sc, nil
+}
+
+// Start starts producing
+func (p *Producer) Start() {
+	p.forwardWg.Add(1)
+	go p.forwardLoop()
+
+	p.wg.Add(2)
+	go p.successLoop()
+	go p.errorLoop()
+}
+
+// Stop sends all queued records and closes the producer
+func (p *Producer) Stop() {
+	close(p.stopCh)
+	p.forwardWg.Wait()
+
+	p.producer.AsyncClose()
+	p.wg.Wait()
+}
+
+// Stats returns a prometheus collector exposing the internal metrics of the producer
+func (p *Producer) Stats() prometheus.Collector {
+	return p.stats
+}
+
+// Write queues updates. Updates exceeding the queue are dropped.
+func (p *Producer) Write(updates []*output.Update) {
+	for _, u := range updates {
+		b, ok := p.marshal(u)
+		if !ok {
+			p.stats.skippedRecords.Inc()
+			continue
+		}
+
+		msg := &sarama.ProducerMessage{
+			Topic:     p.cfg.Topic,
+			Key:       sarama.StringEncoder(u.Target),
+			Value:     sarama.ByteEncoder(b),
+			Timestamp: u.Timestamp,
+		}
+
+		select {
+		case p.queue <- msg:
+		default:
+			p.stats.droppedRecords.Inc()
+		}
+	}
+}
+
+// forwardLoop passes queued records to the producer which blocks if its buffers are full
+func (p *Producer) forwardLoop() {
+	defer p.forwardWg.Done()
+
+	for {
+		select {
+		case <-p.stopCh:
+			for {
+				select {
+				case msg := <-p.queue:
+					p.producer.Input() <- msg
+				default:
+					return
+				}
+			}
+		case msg := <-p.queue:
+			p.producer.Input() <- msg
+		}
+	}
+}
+
+func (p *Producer) successLoop() {
+	defer p.wg.Done()
+
+	for range p.producer.Successes() {
+		p.stats.producedRecords.Inc()
+	}
+}
+
+func (p *Producer) errorLoop() {
+	defer p.wg.Done()
+
+	for err := range p.producer.Errors() {
+		log.Errorf("Kafka: dropping record: %v", err)
+		p.stats.failedRecords.Inc()
+	}
+}
@@ -0,0 +1,117 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/output"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTopic = "telemetry"
+
+// newTestBrokers starts two Kafka protocol stand-ins leading one partition of testTopic each
+func newTestBrokers(t *testing.T, produceErr sarama.KError) []*sarama.MockBroker {
+	brokers := []*sarama.MockBroker{sarama.NewMockBroker(t, 1), sarama.NewMockBroker(t, 2)}
+
+	metadata := sarama.NewMockMetadataResponse(t)
+	for i, b := range brokers {
+		metadata.SetBroker(b.Addr(), b.BrokerID())
+		metadata.SetLeader(testTopic, int32(i), b.BrokerID())
+	}
+
+	for i, b := range brokers {
+		b.SetHandlerByMap(map[string]sarama.MockResponse{
+			"MetadataRequest": metadata,
+			// Version 3 is the version of produce requests for Kafka 1.0.0
+			"ProduceRequest": sarama.NewMockProduceResponse(t).SetVersion(3).SetError(testTopic, int32(i), produceErr),
+		})
+	}
+
+	return brokers
+}
+
+func newTestProducer(t *testing.T, brokers []*sarama.MockBroker) *Producer {
+	cfg := &config.Kafka{
+		Brokers:         []string{brokers[0].Addr()},
+		Topic:           testTopic,
+		Encoding:        config.KafkaEncodingJSON,
+		ClientID:        "test",
+		Version:         "1.0.0",
+		Compression:     "none",
+		RequiredAcks:    "leader",
+		FlushIntervalMS: 10,
+		QueueCapacity:   100,
+		TimeoutS:        5,
+	}
+
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Unable to create producer: %v", err)
+	}
+
+	p.Start()
+	return p
+}
+
+func testUpdates(devices ...string) []*output.Update {
+	res := make([]*output.Update, 0, len(devices))
+	for i, d := range devices {
+		res = append(res, &output.Update{
+			Target:    d,
+			Path:      "/interfaces/interface/state/counters/in-octets",
+			FullPath:  "/interfaces/interface[name='xe-0/0/0']/state/counters/in-octets",
+			Labels:    map[string]string{"device": d, "interface_name": "xe-0/0/0"},
+			Value:     uint64(i),
+			Timestamp: time.Unix(1600000000, 0),
+			Sequence:  uint64(i),
+		})
+	}
+
+	return res
+}
+
+func produceRequests(b *sarama.MockBroker) int {
+	n := 0
+	for _, rr := range b.History() {
+		if _, ok := rr.Request.(*sarama.ProduceRequest); ok {
+			n++
+		}
+	}
+
+	return n
+}
+
+func TestProducer(t *testing.T) {
+	brokers := newTestBrokers(t, sarama.ErrNoError)
+	defer brokers[0].Close()
+	defer brokers[1].Close()
+
+	p := newTestProducer(t, brokers)
+	p.Write(testUpdates("r1", "r1", "r1"))
+	p.Stop()
+
+	assert.Equal(t, float64(3), testutil.ToFloat64(p.stats.producedRecords))
+	assert.Equal(t, float64(0), testutil.ToFloat64(p.stats.failedRecords))
+
+	// All records of a device are keyed by the device and thus sent to the leader of a single partition
+	requests := []int{produceRequests(brokers[0]), produceRequests(brokers[1])}
+	assert.Contains(t, requests, 0)
+	assert.NotEqual(t, []int{0, 0}, requests)
+}
+
+func TestProducerFailure(t *testing.T) {
+	brokers := newTestBrokers(t, sarama.ErrMessageSizeTooLarge)
+	defer brokers[0].Close()
+	defer brokers[1].Close()
+
+	p := newTestProducer(t, brokers)
+	p.Write(testUpdates("r1", "r2"))
+	p.Stop()
+
+	assert.Equal(t, float64(0), testutil.ToFloat64(p.stats.producedRecords))
+	assert.Equal(t, float64(2), testutil.ToFloat64(p.stats.failedRecords))
+}
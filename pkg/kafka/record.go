@@ -0,0 +1,154 @@
+package kafka
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/output"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of Record (see record.proto)
+const (
+	recordDevice      = 1
+	recordPath        = 2
+	recordLabels      = 3
+	recordDoubleValue = 4
+	recordIntValue    = 5
+	recordUintValue   = 6
+	recordBoolValue   = 7
+	recordStringValue = 8
+	recordBytesValue  = 9
+	recordTimestampMS = 10
+	recordSequence    = 11
+	mapEntryKey       = 1
+	mapEntryValue     = 2
+)
+
+// jsonRecord is the JSON representation of an update
+type jsonRecord struct {
+	Device      string            `json:"device"`
+	Path        string            `json:"path"`
+	Labels      map[string]string `json:"labels"`
+	Type        string            `json:"type"`
+	Value       interface{}       `json:"value"`
+	TimestampMS int64             `json:"timestamp_ms"`
+	Sequence    uint64            `json:"sequence"`
+}
+
+// recordLabelsOf returns the labels of u without the device label
+func recordLabelsOf(u *output.Update) map[string]string {
+	labels := make(map[string]string, len(u.Labels))
+	for k, v := range u.Labels {
+		if k == "device" {
+			continue
+		}
+
+		labels[k] = v
+	}
+
+	return labels
+}
+
+func timestampMS(ts time.Time) int64 {
+	return ts.UnixNano() / int64(time.Millisecond)
+}
+
+// marshalJSON encodes u as JSON. It returns false for values that can not be represented in JSON.
+func marshalJSON(u *output.Update) ([]byte, bool) {
+	r := jsonRecord{
+		Device:      u.Target,
+		Path:        u.FullPath,
+		Labels:      recordLabelsOf(u),
+		Value:       u.Value,
+		TimestampMS: timestampMS(u.Timestamp),
+		Sequence:    u.Sequence,
+	}
+
+	switch x := u.Value.(type) {
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return nil, false
+		}
+
+		r.Type = "double"
+	case int64:
+		r.Type = "int"
+	case uint64:
+		r.Type = "uint"
+	case bool:
+		r.Type = "bool"
+	case string:
+		r.Type = "string"
+	case []byte:
+		r.Type = "bytes"
+	default:
+		return nil, false
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, false
+	}
+
+	return b, true
+}
+
+// marshalProtobuf encodes u as Record. It returns false for unknown value types.
+func marshalProtobuf(u *output.Update) ([]byte, bool) {
+	var b []byte
+	b = protowire.AppendTag(b, recordDevice, protowire.BytesType)
+	b = protowire.AppendString(b, u.Target)
+	b = protowire.AppendTag(b, recordPath, protowire.BytesType)
+	b = protowire.AppendString(b, u.FullPath)
+
+	labels := recordLabelsOf(u)
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		var entry []byte
+		entry = protowire.AppendTag(entry, mapEntryKey, protowire.BytesType)
+		entry = protowire.AppendString(entry, k)
+		entry = protowire.AppendTag(entry, mapEntryValue, protowire.BytesType)
+		entry = protowire.AppendString(entry, labels[k])
+
+		b = protowire.AppendTag(b, recordLabels, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+
+	switch x := u.Value.(type) {
+	case float64:
+		b = protowire.AppendTag(b, recordDoubleValue, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(x))
+	case int64:
+		b = protowire.AppendTag(b, recordIntValue, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(x))
+	case uint64:
+		b = protowire.AppendTag(b, recordUintValue, protowire.VarintType)
+		b = protowire.AppendVarint(b, x)
+	case bool:
+		b = protowire.AppendTag(b, recordBoolValue, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeBool(x))
+	case string:
+		b = protowire.AppendTag(b, recordStringValue, protowire.BytesType)
+		b = protowire.AppendString(b, x)
+	case []byte:
+		b = protowire.AppendTag(b, recordBytesValue, protowire.BytesType)
+		b = protowire.AppendBytes(b, x)
+	default:
+		return nil, false
+	}
+
+	b = protowire.AppendTag(b, recordTimestampMS, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(timestampMS(u.Timestamp)))
+	b = protowire.AppendTag(b, recordSequence, protowire.VarintType)
+	b = protowire.AppendVarint(b, u.Sequence)
+
+	return b, true
+}
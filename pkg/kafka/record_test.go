@@ -0,0 +1,109 @@
+package kafka
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/output"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	ts := time.Unix(1600000000, 123000000)
+
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected string
+		wantFail bool
+	}{
+		{
+			name:     "Uint",
+			value:    uint64(18446744073709551615),
+			expected: `{"device":"r1","path":"/interfaces/interface[name='xe-0/0/0']/state/counters/in-octets","labels":{"interface_name":"xe-0/0/0"},"type":"uint","value":18446744073709551615,"timestamp_ms":1600000000123,"sequence":42}`,
+		},
+		{
+			name:     "String",
+			value:    "UP",
+			expected: `{"device":"r1","path":"/interfaces/interface[name='xe-0/0/0']/state/counters/in-octets","labels":{"interface_name":"xe-0/0/0"},"type":"string","value":"UP","timestamp_ms":1600000000123,"sequence":42}`,
+		},
+		{
+			name:     "Bytes",
+			value:    []byte{0x42, 0x28},
+			expected: `{"device":"r1","path":"/interfaces/interface[name='xe-0/0/0']/state/counters/in-octets","labels":{"interface_name":"xe-0/0/0"},"type":"bytes","value":"Qig=","timestamp_ms":1600000000123,"sequence":42}`,
+		},
+		{
+			name:     "NaN",
+			value:    math.NaN(),
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		b, ok := marshalJSON(&output.Update{
+			Target:    "r1",
+			Path:      "/interfaces/interface/state/counters/in-octets",
+			FullPath:  "/interfaces/interface[name='xe-0/0/0']/state/counters/in-octets",
+			Labels:    map[string]string{"device": "r1", "interface_name": "xe-0/0/0"},
+			Value:     test.value,
+			Timestamp: ts,
+			Sequence:  42,
+		})
+		if test.wantFail {
+			assert.False(t, ok, test.name)
+			continue
+		}
+
+		assert.True(t, ok, test.name)
+		assert.Equal(t, test.expected, string(b), test.name)
+	}
+}
+
+func TestMarshalProtobuf(t *testing.T) {
+	b, ok := marshalProtobuf(&output.Update{
+		Target:    "r1",
+		Path:      "/interfaces/interface/state/counters/in-octets",
+		FullPath:  "/interfaces/interface[name='xe-0/0/0']/state/counters/in-octets",
+		Labels:    map[string]string{"device": "r1", "interface_name": "xe-0/0/0", "customer": "foo"},
+		Value:     int64(-1),
+		Timestamp: time.Unix(1600000000, 123000000),
+		Sequence:  42,
+	})
+	assert.True(t, ok)
+
+	type field struct {
+		num   protowire.Number
+		value interface{}
+	}
+
+	fields := make([]field, 0)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		b = b[n:]
+		switch typ {
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			fields = append(fields, field{num, string(v)})
+			b = b[n:]
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			fields = append(fields, field{num, v})
+			b = b[n:]
+		default:
+			t.Fatalf("unexpected wire type %v", typ)
+		}
+	}
+
+	expected := []field{
+		{recordDevice, "r1"},
+		{recordPath, "/interfaces/interface[name='xe-0/0/0']/state/counters/in-octets"},
+		{recordLabels, "\n\x08customer\x12\x03foo"},
+		{recordLabels, "\n\x0einterface_name\x12\x08xe-0/0/0"},
+		{recordIntValue, uint64(math.MaxUint64)},
+		{recordTimestampMS, uint64(1600000000123)},
+		{recordSequence, uint64(42)},
+	}
+	assert.Equal(t, expected, fields)
+}
@@ -0,0 +1,32 @@
+package kafka
+
+import (
+	"github.com/Shopify/sarama"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/output"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// stats are the internal metrics of the producer
+type stats struct {
+	*output.Stats
+	producedRecords prometheus.Counter
+	failedRecords   prometheus.Counter
+	droppedRecords  prometheus.Counter
+	skippedRecords  prometheus.Counter
+}
+
+func newStats(queue chan *sarama.ProducerMessage) *stats {
+	s := &stats{
+		Stats: output.NewStats("kafka"),
+	}
+
+	s.producedRecords = s.Counter("produced_records_total", "Number of records acknowledged by the brokers")
+	s.failedRecords = s.Counter("failed_records_total", "Number of records that could not be produced")
+	s.droppedRecords = s.Counter("dropped_records_total", "Number of records dropped because the queue was full")
+	s.skippedRecords = s.Counter("skipped_records_total", "Number of updates that could not be encoded")
+	s.QueueLength("Number of records waiting to be passed to the producer", func() int {
+		return len(queue)
+	})
+
+	return s
+}
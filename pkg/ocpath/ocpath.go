@@ -0,0 +1,285 @@
+// Package ocpath parses OpenConfig telemetry paths, e.g. /interfaces/interface[name='xe-0/0/0']/state
+package ocpath
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Key is a list key of a path element, e.g. name='xe-0/0/0'
+type Key struct {
+	Name  string
+	Value string
+}
+
+// Element is an element of a path, e.g. interface[name='xe-0/0/0']
+type Element struct {
+	Name string
+	Keys []Key
+}
+
+// Parse parses a telemetry path into its elements. The grammar is:
+//
+//	path      = [ "/" ] [ element { "/" element } ] [ "/" ]
+//	element   = name { "[" keys "]" }
+//	keys      = key { ( "," | "and" ) key }
+//	key       = [ "@" ] keyname "=" value
+//	value     = "'" { char } "'" | `"` { char } `"` | { char except whitespace, "," and "]" }
+//
+// Quoted values may contain any character. The quote character itself and the backslash can be escaped
+// by a backslash. Whitespace around keys, values and separators is ignored. Empty elements are skipped.
+func Parse(p string) ([]Element, error) {
+	if !utf8.ValidString(p) {
+		return nil, fmt.Errorf("invalid path %q: not valid UTF-8", p)
+	}
+
+	ps := &pathScanner{input: p}
+	res, err := ps.path()
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %q: %v", p, err)
+	}
+
+	return res, nil
+}
+
+// ParseKeys parses key expressions as found within the brackets of an element, e.g. name='xe-0/0/0' and unit='0'
+func ParseKeys(s string) ([]Key, error) {
+	ps := &pathScanner{input: s}
+	keys, err := ps.keys(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ps.eof() {
+		return nil, fmt.Errorf("unexpected %q at offset %d", ps.peek(), ps.pos)
+	}
+
+	return keys, nil
+}
+
+// FormatKeys formats keys in the canonical form understood by ParseKeys
+func FormatKeys(keys []Key) string {
+	var sb strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteString(" and ")
+		}
+
+		sb.WriteString(k.Name)
+		sb.WriteString("=")
+		sb.WriteString(quoteKeyValue(k.Value))
+	}
+
+	return sb.String()
+}
+
+func quoteKeyValue(v string) string {
+	quote := "'"
+	if strings.Contains(v, "'") && !strings.Contains(v, `"`) {
+		quote = `"`
+	}
+
+	v = strings.Replace(v, `\`, `\\`, -1)
+	v = strings.Replace(v, quote, `\`+quote, -1)
+
+	return quote + v + quote
+}
+
+type pathScanner struct {
+	input string
+	pos   int
+}
+
+func (ps *pathScanner) eof() bool {
+	return ps.pos >= len(ps.input)
+}
+
+func (ps *pathScanner) peek() byte {
+	return ps.input[ps.pos]
+}
+
+func (ps *pathScanner) skipWhitespace() {
+	for !ps.eof() && isWhitespace(ps.peek()) {
+		ps.pos++
+	}
+}
+
+func (ps *pathScanner) path() ([]Element, error) {
+	res := make([]Element, 0, strings.Count(ps.input, "/")+1)
+	for !ps.eof() {
+		if ps.peek() == '/' {
+			ps.pos++
+			continue
+		}
+
+		e, err := ps.element()
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, e)
+	}
+
+	return res, nil
+}
+
+func (ps *pathScanner) element() (Element, error) {
+	start := ps.pos
+	for !ps.eof() && ps.peek() != '/' && ps.peek() != '[' {
+		if ps.peek() == ']' {
+			return Element{}, fmt.Errorf("unexpected ']' at offset %d", ps.pos)
+		}
+
+		ps.pos++
+	}
+
+	e := Element{
+		Name: ps.input[start:ps.pos],
+	}
+
+	if e.Name == "" {
+		return e, fmt.Errorf("missing element name at offset %d", ps.pos)
+	}
+
+	for !ps.eof() && ps.peek() == '[' {
+		ps.pos++
+
+		var err error
+		e.Keys, err = ps.keys(e.Keys)
+		if err != nil {
+			return e, err
+		}
+
+		if ps.eof() || ps.peek() != ']' {
+			return e, fmt.Errorf("missing ']' at offset %d", ps.pos)
+		}
+
+		ps.pos++
+	}
+
+	if !ps.eof() && ps.peek() != '/' {
+		return e, fmt.Errorf("unexpected %q at offset %d", ps.peek(), ps.pos)
+	}
+
+	return e, nil
+}
+
+// keys parses key expressions and appends them to keys. It stops before a closing bracket or at the end of input.
+func (ps *pathScanner) keys(keys []Key) ([]Key, error) {
+	for {
+		k, err := ps.key()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, x := range keys {
+			if x.Name == k.Name {
+				return nil, fmt.Errorf("duplicate key %q", k.Name)
+			}
+		}
+
+		keys = append(keys, k)
+
+		hadWhitespace := !ps.eof() && isWhitespace(ps.peek())
+		ps.skipWhitespace()
+		if ps.eof() || ps.peek() == ']' {
+			return keys, nil
+		}
+
+		if ps.peek() == ',' {
+			ps.pos++
+			continue
+		}
+
+		if hadWhitespace && strings.HasPrefix(ps.input[ps.pos:], "and") && ps.pos+3 < len(ps.input) && isWhitespace(ps.input[ps.pos+3]) {
+			ps.pos += 3
+			continue
+		}
+
+		return nil, fmt.Errorf("unexpected %q at offset %d", ps.peek(), ps.pos)
+	}
+}
+
+func (ps *pathScanner) key() (Key, error) {
+	ps.skipWhitespace()
+	if !ps.eof() && ps.peek() == '@' {
+		ps.pos++
+	}
+
+	start := ps.pos
+	for !ps.eof() && isKeyNameChar(ps.peek()) {
+		ps.pos++
+	}
+
+	k := Key{
+		Name: ps.input[start:ps.pos],
+	}
+
+	if k.Name == "" {
+		return k, fmt.Errorf("missing key name at offset %d", ps.pos)
+	}
+
+	ps.skipWhitespace()
+	if ps.eof() || ps.peek() != '=' {
+		return k, fmt.Errorf("missing '=' after key %q at offset %d", k.Name, ps.pos)
+	}
+
+	ps.pos++
+	ps.skipWhitespace()
+
+	var err error
+	k.Value, err = ps.value()
+	return k, err
+}
+
+func (ps *pathScanner) value() (string, error) {
+	if ps.eof() {
+		return "", nil
+	}
+
+	quote := ps.peek()
+	if quote != '\'' && quote != '"' {
+		start := ps.pos
+		for !ps.eof() && !isWhitespace(ps.peek()) && ps.peek() != ',' && ps.peek() != ']' {
+			ps.pos++
+		}
+
+		return ps.input[start:ps.pos], nil
+	}
+
+	start := ps.pos
+	ps.pos++
+
+	var sb strings.Builder
+	for !ps.eof() {
+		c := ps.peek()
+		ps.pos++
+
+		if c == quote {
+			return sb.String(), nil
+		}
+
+		if c == '\\' && !ps.eof() && (ps.peek() == quote || ps.peek() == '\\') {
+			c = ps.peek()
+			ps.pos++
+		}
+
+		sb.WriteByte(c)
+	}
+
+	return "", fmt.Errorf("unterminated quoted value at offset %d", start)
+}
+
+func isWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isKeyNameChar(c byte) bool {
+	switch c {
+	case '=', '[', ']', '/', ',', '\'', '"', '@':
+		return false
+	}
+
+	return !isWhitespace(c)
+}
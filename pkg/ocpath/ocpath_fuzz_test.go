@@ -0,0 +1,38 @@
+//go:build go1.18
+// +build go1.18
+
+package ocpath
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func FuzzParsePath(f *testing.F) {
+	f.Add("/interfaces/interface[name='xe-0/0/0']/state/counters/in-octets")
+	f.Add("/interfaces/interface[name='xe-0/0/0' and unit='0']/state")
+	f.Add("/junos/system/linecard/interface[@name='xe-0/0/0'][queue=3]/")
+	f.Add(`/filter[name='a]b=c/d' and counter="it's"][term='x\'y\\z']`)
+	f.Add("/neighbors/neighbor[neighbor-address='2001:db8::1']/state")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		elements, err := Parse(input)
+		if err != nil {
+			return
+		}
+
+		for _, e := range elements {
+			if len(e.Keys) == 0 {
+				continue
+			}
+
+			keys, err := ParseKeys(FormatKeys(e.Keys))
+			if err != nil {
+				t.Fatalf("Unable to parse formatted keys of %q: %v", input, err)
+			}
+
+			assert.Equal(t, e.Keys, keys, input)
+		}
+	})
+}
@@ -0,0 +1,206 @@
+package ocpath
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []Element
+		wantFail bool
+	}{
+		{
+			name:     "Empty",
+			input:    "",
+			expected: []Element{},
+		},
+		{
+			name:  "Plain",
+			input: "/interfaces/interface/state/",
+			expected: []Element{
+				{Name: "interfaces"},
+				{Name: "interface"},
+				{Name: "state"},
+			},
+		},
+		{
+			name:  "Quoted value with slashes",
+			input: "/interfaces/interface[name='xe-0/0/0']/state",
+			expected: []Element{
+				{Name: "interfaces"},
+				{Name: "interface", Keys: []Key{{Name: "name", Value: "xe-0/0/0"}}},
+				{Name: "state"},
+			},
+		},
+		{
+			name:  "Unquoted value",
+			input: "out-queue[queue-number=0]/bytes",
+			expected: []Element{
+				{Name: "out-queue", Keys: []Key{{Name: "queue-number", Value: "0"}}},
+				{Name: "bytes"},
+			},
+		},
+		{
+			name:  "And syntax",
+			input: "/interfaces/interface[name='xe-0/0/0' and unit='0']/state",
+			expected: []Element{
+				{Name: "interfaces"},
+				{Name: "interface", Keys: []Key{{Name: "name", Value: "xe-0/0/0"}, {Name: "unit", Value: "0"}}},
+				{Name: "state"},
+			},
+		},
+		{
+			name:  "Multiple key groups",
+			input: "/interfaces/interface[name='xe-0/0/0'][unit='0']",
+			expected: []Element{
+				{Name: "interfaces"},
+				{Name: "interface", Keys: []Key{{Name: "name", Value: "xe-0/0/0"}, {Name: "unit", Value: "0"}}},
+			},
+		},
+		{
+			name:  "Comma separated keys",
+			input: "foo[a=1, b='2']",
+			expected: []Element{
+				{Name: "foo", Keys: []Key{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}}},
+			},
+		},
+		{
+			name:  "IPv6 address",
+			input: "/neighbors/neighbor[neighbor-address='2001:db8::1']/state",
+			expected: []Element{
+				{Name: "neighbors"},
+				{Name: "neighbor", Keys: []Key{{Name: "neighbor-address", Value: "2001:db8::1"}}},
+				{Name: "state"},
+			},
+		},
+		{
+			name:  "XPath attribute",
+			input: "/junos/system/linecard/interface[@name='xe-0/0/0']/",
+			expected: []Element{
+				{Name: "junos"},
+				{Name: "system"},
+				{Name: "linecard"},
+				{Name: "interface", Keys: []Key{{Name: "name", Value: "xe-0/0/0"}}},
+			},
+		},
+		{
+			name:  "Special characters in quoted values",
+			input: `/filter[name='a]b=c/d' and counter="it's"][term='x\'y\\z']`,
+			expected: []Element{
+				{Name: "filter", Keys: []Key{
+					{Name: "name", Value: "a]b=c/d"},
+					{Name: "counter", Value: "it's"},
+					{Name: "term", Value: `x'y\z`},
+				}},
+			},
+		},
+		{
+			name:  "Empty elements",
+			input: "foo//bar",
+			expected: []Element{
+				{Name: "foo"},
+				{Name: "bar"},
+			},
+		},
+		{
+			name:     "Unterminated quote",
+			input:    "/interface[name='xe-0/0/0]/state",
+			wantFail: true,
+		},
+		{
+			name:     "Missing bracket",
+			input:    "/interface[name='xe-0/0/0'/state",
+			wantFail: true,
+		},
+		{
+			name:     "Missing key name",
+			input:    "/interface[='xe-0/0/0']",
+			wantFail: true,
+		},
+		{
+			name:     "Missing equal sign",
+			input:    "/interface[name]",
+			wantFail: true,
+		},
+		{
+			name:     "Duplicate key",
+			input:    "/interface[name='a'][name='b']",
+			wantFail: true,
+		},
+		{
+			name:     "Garbage after predicate",
+			input:    "/interface[name='a']foo/state",
+			wantFail: true,
+		},
+		{
+			name:     "Invalid UTF-8",
+			input:    "/interface[name='\xff']",
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		res, err := Parse(test.input)
+		if test.wantFail {
+			if err != nil {
+				continue
+			}
+
+			t.Errorf("Unexpected success for test %q", test.name)
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		assert.Equal(t, test.expected, res, test.name)
+	}
+}
+
+func TestFormatKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []Key
+		expected string
+	}{
+		{
+			name:     "Single key",
+			input:    []Key{{Name: "name", Value: "xe-0/0/0"}},
+			expected: "name='xe-0/0/0'",
+		},
+		{
+			name:     "Multiple keys",
+			input:    []Key{{Name: "name", Value: "xe-0/0/0"}, {Name: "unit", Value: "0"}},
+			expected: "name='xe-0/0/0' and unit='0'",
+		},
+		{
+			name:     "Single quote",
+			input:    []Key{{Name: "name", Value: "it's"}},
+			expected: `name="it's"`,
+		},
+		{
+			name:     "Both quotes",
+			input:    []Key{{Name: "name", Value: `it's "x"\`}},
+			expected: `name='it\'s "x"\\'`,
+		},
+	}
+
+	for _, test := range tests {
+		res := FormatKeys(test.input)
+		assert.Equal(t, test.expected, res, test.name)
+
+		keys, err := ParseKeys(res)
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		assert.Equal(t, test.input, keys, test.name)
+	}
+}
@@ -0,0 +1,223 @@
+// Package otlp periodically exports the series of all targets to an OpenTelemetry collector
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/collector"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	exportMethod = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+	userAgent    = "openconfig-streaming-telemetry-exporter"
+)
+
+// Snapshotter provides the series to export
+type Snapshotter interface {
+	Snapshot() []*collector.TargetSnapshot
+}
+
+// Exporter periodically exports all series of a Snapshotter
+type Exporter struct {
+	cfg    *config.OTLP
+	source Snapshotter
+	conn   *grpc.ClientConn
+	client *http.Client
+	stats  *stats
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// New creates a new Exporter
+func New(cfg *config.OTLP, source Snapshotter) (*Exporter, error) {
+	e := &Exporter{
+		cfg:    cfg,
+		source: source,
+		stats:  newStats(),
+		stopCh: make(chan struct{}),
+	}
+
+	var tlsConfig *tls.Config
+	if cfg.TLS != nil {
+		var err error
+		tlsConfig, err = cfg.TLS.ClientConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Protocol == config.OTLPProtocolHTTP {
+		e.client = &http.Client{
+			Timeout: time.Duration(cfg.TimeoutS) * time.Second,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+		}
+
+		return e, nil
+	}
+
+	transport := grpc.WithInsecure()
+	if tlsConfig != nil {
+		transport = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
+	}
+
+	conn, err := grpc.Dial(cfg.Endpoint, transport, grpc.WithUserAgent(userAgent))
+	if err != nil {
+		return nil, fmt.Errorf("unable to dial %s: %v", cfg.Endpoint, err)
+	}
+
+	e.conn = conn
+	return e, nil
+}
+
+// Start starts exporting
+func (e *Exporter) Start() {
+	e.wg.Add(1)
+	go e.loop()
+}
+
+// Stop stops exporting
+func (e *Exporter) Stop() {
+	close(e.stopCh)
+	e.wg.Wait()
+
+	if e.conn != nil {
+		e.conn.Close()
+	}
+}
+
+// Stats returns a prometheus collector exposing the internal metrics of the exporter
+func (e *Exporter) Stats() prometheus.Collector {
+	return e.stats
+}
+
+func (e *Exporter) loop() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(time.Duration(e.cfg.IntervalS) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.stopCh:
+			return
+		case <-ticker.C:
+			e.export(time.Now())
+		}
+	}
+}
+
+// export sends the current series of all targets with timestamp now
+func (e *Exporter) export(now time.Time) {
+	targets := e.source.Snapshot()
+
+	points := 0
+	for _, t := range targets {
+		points += len(t.Series)
+	}
+
+	if points == 0 {
+		return
+	}
+
+	body := marshalExportRequest(targets, e.cfg.ResourceAttributes, now)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(e.cfg.TimeoutS)*time.Second)
+	defer cancel()
+
+	var err error
+	if e.conn != nil {
+		err = e.sendGRPC(ctx, body)
+	} else {
+		err = e.sendHTTP(ctx, body)
+	}
+
+	if err != nil {
+		log.Errorf("OTLP: dropping %d points: %v", points, err)
+		e.stats.failedExports.Inc()
+		e.stats.failedPoints.Add(float64(points))
+		return
+	}
+
+	e.stats.exportedPoints.Add(float64(points))
+}
+
+func (e *Exporter) sendGRPC(ctx context.Context, body []byte) error {
+	if len(e.cfg.Headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(e.cfg.Headers))
+	}
+
+	var resp []byte
+	return e.conn.Invoke(ctx, exportMethod, body, &resp, grpc.ForceCodec(rawCodec{}))
+}
+
+func (e *Exporter) sendHTTP(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", userAgent)
+	for k, v := range e.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 256))
+	return fmt.Errorf("server returned HTTP status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// rawCodec passes already encoded protobuf messages through
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unable to marshal %T", v)
+	}
+
+	return b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unable to unmarshal into %T", v)
+	}
+
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
@@ -0,0 +1,364 @@
+package otlp
+
+import (
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/collector"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type staticSnapshotter []*collector.TargetSnapshot
+
+func (s staticSnapshotter) Snapshot() []*collector.TargetSnapshot {
+	return s
+}
+
+// point is a decoded data point along with its resource and metric
+type point struct {
+	resource map[string]string
+	metric   string
+	help     string
+	sum      bool
+	labels   map[string]string
+	value    float64
+	start    uint64
+	time     uint64
+}
+
+func unmarshalExportRequest(b []byte) []point {
+	res := make([]point, 0)
+	forEachField(b, func(_ protowire.Number, rm []byte, _ uint64) {
+		resource := make(map[string]string)
+		var metrics [][]byte
+		forEachField(rm, func(num protowire.Number, v []byte, _ uint64) {
+			if num == resourceMetricsResource {
+				forEachField(v, func(_ protowire.Number, kv []byte, _ uint64) {
+					k, v := unmarshalKeyValue(kv)
+					resource[k] = v
+				})
+				return
+			}
+
+			forEachField(v, func(num protowire.Number, v []byte, _ uint64) {
+				if num == scopeMetricsMetrics {
+					metrics = append(metrics, v)
+				}
+			})
+		})
+
+		for _, m := range metrics {
+			p := point{resource: resource}
+			var data []byte
+			forEachField(m, func(num protowire.Number, v []byte, _ uint64) {
+				switch num {
+				case metricName:
+					p.metric = string(v)
+				case metricDescription:
+					p.help = string(v)
+				case metricSum:
+					p.sum = true
+					data = v
+				case metricGauge:
+					data = v
+				}
+			})
+
+			var dataPoints protowire.Number = gaugeDataPoints
+			if p.sum {
+				dataPoints = sumDataPoints
+			}
+
+			forEachField(data, func(num protowire.Number, v []byte, _ uint64) {
+				if num != dataPoints {
+					return
+				}
+
+				dp := p
+				dp.labels = make(map[string]string)
+				forEachField(v, func(num protowire.Number, v []byte, x uint64) {
+					switch num {
+					case dataPointStartTime:
+						dp.start = x
+					case dataPointTime:
+						dp.time = x
+					case dataPointAsDouble:
+						dp.value = math.Float64frombits(x)
+					case dataPointAttributes:
+						k, v := unmarshalKeyValue(v)
+						dp.labels[k] = v
+					}
+				})
+				res = append(res, dp)
+			})
+		}
+	})
+
+	return res
+}
+
+func unmarshalKeyValue(b []byte) (string, string) {
+	var k, v string
+	forEachField(b, func(num protowire.Number, x []byte, _ uint64) {
+		if num == keyValueKey {
+			k = string(x)
+			return
+		}
+
+		forEachField(x, func(_ protowire.Number, s []byte, _ uint64) {
+			v = string(s)
+		})
+	})
+
+	return k, v
+}
+
+func forEachField(b []byte, f func(num protowire.Number, v []byte, x uint64)) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		b = b[n:]
+		switch typ {
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			f(num, v, 0)
+			b = b[n:]
+		case protowire.Fixed64Type:
+			x, n := protowire.ConsumeFixed64(b)
+			f(num, nil, x)
+			b = b[n:]
+		case protowire.VarintType:
+			x, n := protowire.ConsumeVarint(b)
+			f(num, nil, x)
+			b = b[n:]
+		default:
+			return
+		}
+	}
+}
+
+// serverCodec is the grpc.Codec counterpart of rawCodec for the receiver
+type serverCodec struct {
+	rawCodec
+}
+
+func (serverCodec) String() string {
+	return "proto"
+}
+
+// receiver is an OTLP endpoint stand-in accepting requests over gRPC or HTTP
+type receiver struct {
+	body    []byte
+	headers map[string]string
+	done    chan struct{}
+	mu      sync.Mutex
+}
+
+func (r *receiver) receive(body []byte, headers map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.body = body
+	r.headers = headers
+	close(r.done)
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	b, _ := ioutil.ReadAll(req.Body)
+	r.receive(b, map[string]string{
+		"content-type":  req.Header.Get("Content-Type"),
+		"authorization": req.Header.Get("Authorization"),
+	})
+}
+
+func (r *receiver) handleStream(_ interface{}, stream grpc.ServerStream) error {
+	method, _ := grpc.MethodFromServerStream(stream)
+	if method != exportMethod {
+		return nil
+	}
+
+	var b []byte
+	err := stream.RecvMsg(&b)
+	if err != nil {
+		return err
+	}
+
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	r.receive(b, map[string]string{
+		"content-type":  md.Get("content-type")[0],
+		"authorization": md.Get("authorization")[0],
+	})
+
+	return stream.SendMsg([]byte{})
+}
+
+func TestExporter(t *testing.T) {
+	created := time.Unix(1500000000, 0)
+	now := time.Unix(1600000000, 0)
+	source := staticSnapshotter{
+		{
+			Target: "r1",
+			Series: []*collector.Series{
+				{
+					Name:    "interfaces_interface_state_counters_in_octets",
+					Help:    "Octets received",
+					Counter: true,
+					Labels:  map[string]string{"interface_name": "et-0/0/0"},
+					Value:   100,
+					Start:   created,
+				},
+				{
+					Name:   "components_component_temperature",
+					Help:   "Temperature",
+					Labels: map[string]string{"component_name": "FPC0"},
+					Value:  42.5,
+				},
+				{
+					Name:    "interfaces_interface_state_counters_in_octets",
+					Help:    "Octets received",
+					Counter: true,
+					Labels:  map[string]string{"interface_name": "et-0/0/1"},
+					Value:   200,
+					Start:   created,
+				},
+			},
+		},
+	}
+
+	expected := []point{
+		{
+			resource: map[string]string{"device": "r1", "site": "fra1"},
+			metric:   "interfaces_interface_state_counters_in_octets",
+			help:     "Octets received",
+			sum:      true,
+			labels:   map[string]string{"interface_name": "et-0/0/0"},
+			value:    100,
+			start:    uint64(created.UnixNano()),
+			time:     uint64(now.UnixNano()),
+		},
+		{
+			resource: map[string]string{"device": "r1", "site": "fra1"},
+			metric:   "interfaces_interface_state_counters_in_octets",
+			help:     "Octets received",
+			sum:      true,
+			labels:   map[string]string{"interface_name": "et-0/0/1"},
+			value:    200,
+			start:    uint64(created.UnixNano()),
+			time:     uint64(now.UnixNano()),
+		},
+		{
+			resource: map[string]string{"device": "r1", "site": "fra1"},
+			metric:   "components_component_temperature",
+			help:     "Temperature",
+			labels:   map[string]string{"component_name": "FPC0"},
+			value:    42.5,
+			time:     uint64(now.UnixNano()),
+		},
+	}
+
+	tests := []struct {
+		name        string
+		protocol    string
+		contentType string
+	}{
+		{
+			name:        "gRPC",
+			protocol:    config.OTLPProtocolGRPC,
+			contentType: "application/grpc",
+		},
+		{
+			name:        "HTTP",
+			protocol:    config.OTLPProtocolHTTP,
+			contentType: "application/x-protobuf",
+		},
+	}
+
+	for _, test := range tests {
+		rcv := &receiver{
+			done: make(chan struct{}),
+		}
+
+		cfg := &config.OTLP{
+			Protocol:           test.protocol,
+			IntervalS:          3600,
+			TimeoutS:           5,
+			Headers:            map[string]string{"authorization": "Bearer secret"},
+			ResourceAttributes: map[string]string{"site": "fra1"},
+		}
+
+		var stop func()
+		if test.protocol == config.OTLPProtocolHTTP {
+			srv := httptest.NewServer(rcv)
+			cfg.Endpoint = srv.URL + "/v1/metrics"
+			stop = srv.Close
+		} else {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			srv := grpc.NewServer(grpc.CustomCodec(serverCodec{}), grpc.UnknownServiceHandler(rcv.handleStream))
+			go srv.Serve(l)
+			cfg.Endpoint = l.Addr().String()
+			stop = srv.Stop
+		}
+
+		e, err := New(cfg, source)
+		if err != nil {
+			t.Fatalf("Unexpected error for test %q: %v", test.name, err)
+		}
+
+		e.export(now)
+
+		select {
+		case <-rcv.done:
+		case <-time.After(5 * time.Second):
+			t.Errorf("Timeout for test %q", test.name)
+		}
+
+		e.Stop()
+		stop()
+
+		assert.Equal(t, test.contentType, rcv.headers["content-type"], test.name)
+		assert.Equal(t, "Bearer secret", rcv.headers["authorization"], test.name)
+		assert.Equal(t, expected, unmarshalExportRequest(rcv.body), test.name)
+		assert.Equal(t, float64(3), testutil.ToFloat64(e.stats.exportedPoints), test.name)
+	}
+}
+
+func TestExportFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	e, err := New(&config.OTLP{
+		Endpoint: srv.URL,
+		Protocol: config.OTLPProtocolHTTP,
+		TimeoutS: 5,
+	}, staticSnapshotter{
+		{
+			Target: "r1",
+			Series: []*collector.Series{{Name: "up", Value: 1}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e.export(time.Now())
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(e.stats.failedExports))
+	assert.Equal(t, float64(1), testutil.ToFloat64(e.stats.failedPoints))
+	assert.Equal(t, float64(0), testutil.ToFloat64(e.stats.exportedPoints))
+}
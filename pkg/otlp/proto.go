@@ -0,0 +1,186 @@
+package otlp
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/collector"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of the OTLP metrics protocol (opentelemetry/proto/metrics/v1)
+const (
+	exportRequestResourceMetrics = 1
+	resourceMetricsResource      = 1
+	resourceMetricsScopeMetrics  = 2
+	resourceAttributes           = 1
+	scopeMetricsScope            = 1
+	scopeMetricsMetrics          = 2
+	scopeName                    = 1
+	metricName                   = 1
+	metricDescription            = 2
+	metricGauge                  = 5
+	metricSum                    = 7
+	gaugeDataPoints              = 1
+	sumDataPoints                = 1
+	sumAggregationTemporality    = 2
+	sumIsMonotonic               = 3
+	dataPointStartTime           = 2
+	dataPointTime                = 3
+	dataPointAsDouble            = 4
+	dataPointAttributes          = 7
+	keyValueKey                  = 1
+	keyValueValue                = 2
+	anyValueString               = 1
+)
+
+// aggregationTemporalityCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE
+const aggregationTemporalityCumulative = 2
+
+const scope = "openconfig-streaming-telemetry-exporter"
+
+// marshalExportRequest encodes the series of targets as ExportMetricsServiceRequest. The target becomes the resource
+// attribute device, counters become cumulative monotonic sums and all other series gauges.
+func marshalExportRequest(targets []*collector.TargetSnapshot, attributes map[string]string, now time.Time) []byte {
+	var b []byte
+	for _, t := range targets {
+		attrs := make(map[string]string, len(attributes)+1)
+		for k, v := range attributes {
+			attrs[k] = v
+		}
+
+		attrs["device"] = t.Target
+
+		b = appendMessage(b, exportRequestResourceMetrics, marshalResourceMetrics(t, attrs, now))
+	}
+
+	return b
+}
+
+func marshalResourceMetrics(t *collector.TargetSnapshot, attributes map[string]string, now time.Time) []byte {
+	var resource []byte
+	resource = appendAttributes(resource, resourceAttributes, attributes)
+
+	var sm []byte
+	sm = appendMessage(sm, scopeMetricsScope, protowire.AppendString(protowire.AppendTag(nil, scopeName, protowire.BytesType), scope))
+	for _, m := range groupSeries(t.Series) {
+		sm = appendMessage(sm, scopeMetricsMetrics, marshalMetric(m, now))
+	}
+
+	var b []byte
+	b = appendMessage(b, resourceMetricsResource, resource)
+	b = appendMessage(b, resourceMetricsScopeMetrics, sm)
+
+	return b
+}
+
+// metricGroup are the series of one metric
+type metricGroup struct {
+	name    string
+	help    string
+	counter bool
+	series  []*collector.Series
+}
+
+// groupSeries groups series by name and type keeping the order of their first occurrence
+func groupSeries(series []*collector.Series) []*metricGroup {
+	type groupKey struct {
+		name    string
+		counter bool
+	}
+
+	index := make(map[groupKey]*metricGroup)
+	res := make([]*metricGroup, 0)
+	for _, s := range series {
+		k := groupKey{name: s.Name, counter: s.Counter}
+		g, ok := index[k]
+		if !ok {
+			g = &metricGroup{
+				name:    s.Name,
+				help:    s.Help,
+				counter: s.Counter,
+			}
+
+			index[k] = g
+			res = append(res, g)
+		}
+
+		g.series = append(g.series, s)
+	}
+
+	return res
+}
+
+func marshalMetric(m *metricGroup, now time.Time) []byte {
+	var b []byte
+	b = protowire.AppendTag(b, metricName, protowire.BytesType)
+	b = protowire.AppendString(b, m.name)
+	b = protowire.AppendTag(b, metricDescription, protowire.BytesType)
+	b = protowire.AppendString(b, m.help)
+
+	var dataPoints protowire.Number = gaugeDataPoints
+	if m.counter {
+		dataPoints = sumDataPoints
+	}
+
+	var data []byte
+	for _, s := range m.series {
+		data = appendMessage(data, dataPoints, marshalDataPoint(s, m.counter, now))
+	}
+
+	if !m.counter {
+		return appendMessage(b, metricGauge, data)
+	}
+
+	data = protowire.AppendTag(data, sumAggregationTemporality, protowire.VarintType)
+	data = protowire.AppendVarint(data, aggregationTemporalityCumulative)
+	data = protowire.AppendTag(data, sumIsMonotonic, protowire.VarintType)
+	data = protowire.AppendVarint(data, 1)
+
+	return appendMessage(b, metricSum, data)
+}
+
+func marshalDataPoint(s *collector.Series, counter bool, now time.Time) []byte {
+	var b []byte
+	if counter {
+		b = protowire.AppendTag(b, dataPointStartTime, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, uint64(s.Start.UnixNano()))
+	}
+
+	b = protowire.AppendTag(b, dataPointTime, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, uint64(now.UnixNano()))
+	b = protowire.AppendTag(b, dataPointAsDouble, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, math.Float64bits(s.Value))
+
+	return appendAttributes(b, dataPointAttributes, s.Labels)
+}
+
+// appendAttributes appends attributes as repeated KeyValue field num sorted by key
+func appendAttributes(b []byte, num protowire.Number, attributes map[string]string) []byte {
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		var value []byte
+		value = protowire.AppendTag(value, anyValueString, protowire.BytesType)
+		value = protowire.AppendString(value, attributes[k])
+
+		var kv []byte
+		kv = protowire.AppendTag(kv, keyValueKey, protowire.BytesType)
+		kv = protowire.AppendString(kv, k)
+		kv = appendMessage(kv, keyValueValue, value)
+
+		b = appendMessage(b, num, kv)
+	}
+
+	return b
+}
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
@@ -0,0 +1,26 @@
+package otlp
+
+import (
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/output"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// stats are the internal metrics of the exporter
+type stats struct {
+	*output.Stats
+	exportedPoints prometheus.Counter
+	failedPoints   prometheus.Counter
+	failedExports  prometheus.Counter
+}
+
+func newStats() *stats {
+	s := &stats{
+		Stats: output.NewStats("otlp"),
+	}
+
+	s.exportedPoints = s.Counter("exported_points_total", "Number of data points exported successfully")
+	s.failedPoints = s.Counter("failed_points_total", "Number of data points that could not be exported")
+	s.failedExports = s.Counter("failed_exports_total", "Number of failed export requests")
+
+	return s
+}
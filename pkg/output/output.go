@@ -0,0 +1,27 @@
+// Package output defines sinks receiving every update stored by the collector
+package output
+
+import "time"
+
+// Update is a single value received from a target
+type Update struct {
+	// Target is the hostname of the target
+	Target string
+	// Path is the path of the leaf without list keys, e.g. /interfaces/interface/state/counters/in-octets
+	Path string
+	// FullPath is the path as received with list keys, e.g. /interfaces/interface[name='xe-0/0/0']/state/counters/in-octets
+	FullPath string
+	// Labels are the list keys, description labels and the device label
+	Labels map[string]string
+	// Value is a float64, int64, uint64, bool, string or []byte
+	Value interface{}
+	// Timestamp is the time the device sampled the value
+	Timestamp time.Time
+	// Sequence is the sequence number of the message the update was received in
+	Sequence uint64
+}
+
+// Sink receives updates. Write is called for all updates of a message and must not block.
+type Sink interface {
+	Write(updates []*Update)
+}
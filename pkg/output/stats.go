@@ -0,0 +1,60 @@
+package output
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const statsNamespace = "openconfig_exporter"
+
+// Stats are the internal metrics of a sink named openconfig_exporter_<subsystem>_<name>
+type Stats struct {
+	subsystem  string
+	collectors []prometheus.Collector
+}
+
+// NewStats creates the stats of the sink subsystem
+func NewStats(subsystem string) *Stats {
+	return &Stats{
+		subsystem: subsystem,
+	}
+}
+
+// Counter adds a counter
+func (s *Stats) Counter(name string, help string) prometheus.Counter {
+	c := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: statsNamespace,
+		Subsystem: s.subsystem,
+		Name:      name,
+		Help:      help,
+	})
+
+	s.collectors = append(s.collectors, c)
+	return c
+}
+
+// QueueLength adds the gauge queue_length reporting the result of length
+func (s *Stats) QueueLength(help string, length func() int) prometheus.GaugeFunc {
+	g := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: statsNamespace,
+		Subsystem: s.subsystem,
+		Name:      "queue_length",
+		Help:      help,
+	}, func() float64 {
+		return float64(length())
+	})
+
+	s.collectors = append(s.collectors, g)
+	return g
+}
+
+// Describe is required by prometheus interface
+func (s *Stats) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range s.collectors {
+		c.Describe(ch)
+	}
+}
+
+// Collect collects the internal metrics
+func (s *Stats) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range s.collectors {
+		c.Collect(ch)
+	}
+}
@@ -0,0 +1,27 @@
+package output
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	s := NewStats("test")
+	s.Counter("sent_total", "Number of sent updates").Add(3)
+	s.QueueLength("Number of queued updates", func() int {
+		return 2
+	})
+
+	expected := `
+# HELP openconfig_exporter_test_queue_length Number of queued updates
+# TYPE openconfig_exporter_test_queue_length gauge
+openconfig_exporter_test_queue_length 2
+# HELP openconfig_exporter_test_sent_total Number of sent updates
+# TYPE openconfig_exporter_test_sent_total counter
+openconfig_exporter_test_sent_total 3
+`
+	assert.NoError(t, testutil.CollectAndCompare(s, strings.NewReader(expected)))
+}
@@ -0,0 +1,123 @@
+// Package pathmatch matches telemetry paths against exact, glob and regular expression patterns.
+package pathmatch
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const regexpPrefix = "~"
+
+// Matcher matches telemetry paths against a pattern
+type Matcher struct {
+	pattern string
+	literal string
+	re      *regexp.Regexp
+}
+
+// Compile compiles a pattern into a Matcher.
+// Patterns starting with `~` are regular expressions matched against the whole path.
+// Patterns containing `*` or `?` are globs: `*` matches any characters within a single path element,
+// `?` matches a single character within a path element and a `**` element matches any number of elements.
+// All other patterns have to match exactly. Trailing slashes are ignored.
+func Compile(pattern string) (*Matcher, error) {
+	m := &Matcher{
+		pattern: pattern,
+	}
+
+	if strings.HasPrefix(pattern, regexpPrefix) {
+		re, err := regexp.Compile("^(?:" + strings.TrimPrefix(pattern, regexpPrefix) + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %v", pattern, err)
+		}
+
+		m.re = re
+		return m, nil
+	}
+
+	p := trimTrailingSlash(pattern)
+	if !strings.ContainsAny(p, "*?") {
+		m.literal = p
+		return m, nil
+	}
+
+	re, err := regexp.Compile(globToRegexp(p))
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %v", pattern, err)
+	}
+
+	m.re = re
+	return m, nil
+}
+
+// MustCompile is like Compile but panics if the pattern can not be compiled
+func MustCompile(pattern string) *Matcher {
+	m, err := Compile(pattern)
+	if err != nil {
+		panic(err)
+	}
+
+	return m
+}
+
+// Match reports whether path matches the pattern
+func (m *Matcher) Match(path string) bool {
+	if m.re != nil {
+		return m.re.MatchString(trimTrailingSlash(path))
+	}
+
+	return m.literal == trimTrailingSlash(path)
+}
+
+// IsLiteral reports whether the pattern matches exactly one path
+func (m *Matcher) IsLiteral() bool {
+	return m.re == nil
+}
+
+// String returns the pattern the matcher was compiled from
+func (m *Matcher) String() string {
+	return m.pattern
+}
+
+func trimTrailingSlash(p string) string {
+	if len(p) > 1 && strings.HasSuffix(p, "/") {
+		return p[:len(p)-1]
+	}
+
+	return p
+}
+
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+
+	for i, element := range strings.Split(glob, "/") {
+		if element == "**" {
+			if i == 0 {
+				sb.WriteString("[^/]*")
+			}
+
+			sb.WriteString("(?:/[^/]*)*")
+			continue
+		}
+
+		if i > 0 {
+			sb.WriteString("/")
+		}
+
+		for _, r := range element {
+			switch r {
+			case '*':
+				sb.WriteString("[^/]*")
+			case '?':
+				sb.WriteString("[^/]")
+			default:
+				sb.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+	}
+
+	sb.WriteString("$")
+	return sb.String()
+}
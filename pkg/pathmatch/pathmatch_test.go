@@ -0,0 +1,149 @@
+package pathmatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		path     string
+		expected bool
+	}{
+		{
+			name:     "Exact match",
+			pattern:  "/interfaces/interface/state/oper-status",
+			path:     "/interfaces/interface/state/oper-status",
+			expected: true,
+		},
+		{
+			name:     "Exact match with trailing slash",
+			pattern:  "/interfaces/interface/state/oper-status/",
+			path:     "/interfaces/interface/state/oper-status",
+			expected: true,
+		},
+		{
+			name:     "Exact mismatch",
+			pattern:  "/interfaces/interface/state/oper-status",
+			path:     "/interfaces/interface/state/admin-status",
+			expected: false,
+		},
+		{
+			name:     "Single element wildcard",
+			pattern:  "/interfaces/*/state/oper-status",
+			path:     "/interfaces/interface/state/oper-status",
+			expected: true,
+		},
+		{
+			name:     "Single element wildcard does not cross elements",
+			pattern:  "/interfaces/*/oper-status",
+			path:     "/interfaces/interface/state/oper-status",
+			expected: false,
+		},
+		{
+			name:     "Partial element wildcard",
+			pattern:  "/interfaces/interface/state/*-status",
+			path:     "/interfaces/interface/state/admin-status",
+			expected: true,
+		},
+		{
+			name:     "Question mark",
+			pattern:  "/interfaces/interface/state/?dmin-status",
+			path:     "/interfaces/interface/state/admin-status",
+			expected: true,
+		},
+		{
+			name:     "Double star matches many elements",
+			pattern:  "/interfaces/**/oper-status",
+			path:     "/interfaces/interface/subinterfaces/subinterface/state/oper-status",
+			expected: true,
+		},
+		{
+			name:     "Double star matches zero elements",
+			pattern:  "/interfaces/**/oper-status",
+			path:     "/interfaces/oper-status",
+			expected: true,
+		},
+		{
+			name:     "Double star at the end",
+			pattern:  "/interfaces/**",
+			path:     "/interfaces/interface/state/counters/in-octets",
+			expected: true,
+		},
+		{
+			name:     "Double star does not match other prefix",
+			pattern:  "/interfaces/**/oper-status",
+			path:     "/components/component/state/oper-status",
+			expected: false,
+		},
+		{
+			name:     "Regular expression",
+			pattern:  "~/interfaces/.*/(oper|admin)-status",
+			path:     "/interfaces/interface/state/admin-status",
+			expected: true,
+		},
+		{
+			name:     "Regular expression is anchored",
+			pattern:  "~/interfaces/.*/oper-status",
+			path:     "/foo/interfaces/interface/state/oper-status",
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		m, err := Compile(test.pattern)
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		assert.Equal(t, test.expected, m.Match(test.path), test.name)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		literal  bool
+		wantFail bool
+	}{
+		{
+			name:    "Literal",
+			pattern: "/interfaces/interface/state/oper-status",
+			literal: true,
+		},
+		{
+			name:    "Glob",
+			pattern: "/interfaces/**",
+		},
+		{
+			name:     "Invalid regular expression",
+			pattern:  "~/interfaces/(",
+			wantFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		m, err := Compile(test.pattern)
+		if test.wantFail {
+			if err != nil {
+				continue
+			}
+
+			t.Errorf("Unexpected success for test %q", test.name)
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		assert.Equal(t, test.literal, m.IsLiteral(), test.name)
+		assert.Equal(t, test.pattern, m.String(), test.name)
+	}
+}
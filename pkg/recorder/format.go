@@ -0,0 +1,172 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	pb "github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/telemetry"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// A recording is a sequence of entries, each prefixed by its length as varint. An entry is the protobuf message
+//
+//	message Entry {
+//	  fixed64 receive_time_unix_nano = 1;
+//	  telemetry.OpenConfigData data = 2;
+//	}
+const (
+	entryReceiveTime = 1
+	entryData        = 2
+)
+
+// maxEntrySize protects readers from allocating huge buffers for corrupted length prefixes
+const maxEntrySize = 64 << 20
+
+// appendEntry appends the length-delimited entry of data received at receivedAt to b
+func appendEntry(b []byte, receivedAt time.Time, data *pb.OpenConfigData) ([]byte, error) {
+	d, err := proto.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry []byte
+	entry = protowire.AppendTag(entry, entryReceiveTime, protowire.Fixed64Type)
+	entry = protowire.AppendFixed64(entry, uint64(receivedAt.UnixNano()))
+	entry = protowire.AppendTag(entry, entryData, protowire.BytesType)
+	entry = protowire.AppendBytes(entry, d)
+
+	return protowire.AppendBytes(b, entry), nil
+}
+
+// Reader reads the entries of a recording
+type Reader struct {
+	r      *bufio.Reader
+	closer []io.Closer
+}
+
+// Open opens the recording at path. Files ending in .gz are decompressed.
+func Open(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rd := &Reader{
+		closer: []io.Closer{f},
+	}
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(bufio.NewReader(f))
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("unable to open %s: %v", path, err)
+		}
+
+		rd.closer = append([]io.Closer{gz}, rd.closer...)
+		r = gz
+	}
+
+	rd.r = bufio.NewReader(r)
+	return rd, nil
+}
+
+// NewReader creates a reader reading an uncompressed recording from r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		r: bufio.NewReader(r),
+	}
+}
+
+// Next returns the next message and the time it has been received. It returns io.EOF at the end of the recording.
+func (r *Reader) Next() (time.Time, *pb.OpenConfigData, error) {
+	// ReadUvarint returns io.EOF only if no byte has been read
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+
+	if size > maxEntrySize {
+		return time.Time{}, nil, fmt.Errorf("entry size %d exceeds limit", size)
+	}
+
+	buf := make([]byte, size)
+	_, err = io.ReadFull(r.r, buf)
+	if err != nil {
+		return time.Time{}, nil, unexpectedEOF(err)
+	}
+
+	return unmarshalEntry(buf)
+}
+
+// Close closes the underlying files
+func (r *Reader) Close() error {
+	var res error
+	for _, c := range r.closer {
+		err := c.Close()
+		if err != nil && res == nil {
+			res = err
+		}
+	}
+
+	return res
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+func unmarshalEntry(b []byte) (time.Time, *pb.OpenConfigData, error) {
+	var receivedAt time.Time
+	data := &pb.OpenConfigData{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return time.Time{}, nil, protowire.ParseError(n)
+		}
+
+		b = b[n:]
+		switch {
+		case num == entryReceiveTime && typ == protowire.Fixed64Type:
+			x, n := protowire.ConsumeFixed64(b)
+			if n < 0 {
+				return time.Time{}, nil, protowire.ParseError(n)
+			}
+
+			receivedAt = time.Unix(0, int64(x))
+			b = b[n:]
+		case num == entryData && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return time.Time{}, nil, protowire.ParseError(n)
+			}
+
+			err := proto.Unmarshal(v, data)
+			if err != nil {
+				return time.Time{}, nil, fmt.Errorf("invalid message: %v", err)
+			}
+
+			b = b[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return time.Time{}, nil, protowire.ParseError(n)
+			}
+
+			b = b[n:]
+		}
+	}
+
+	return receivedAt, data, nil
+}
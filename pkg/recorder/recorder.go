@@ -0,0 +1,221 @@
+// Package recorder records the raw telemetry stream of a target to rotating files
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	pb "github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/telemetry"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	fileExtension   = ".rec"
+	timestampFormat = "20060102T150405.000000000Z"
+)
+
+// Recorder writes all messages of a target to files in a directory. A new file is started once the current one
+// exceeds the configured size or age.
+type Recorder struct {
+	cfg     *config.Record
+	target  string
+	mu      sync.Mutex
+	enabled bool
+	file    *os.File
+	gz      *gzip.Writer
+	w       *bufio.Writer
+	size    uint64
+	opened  time.Time
+	buf     []byte
+}
+
+// New creates a recorder for target. Recording starts if enabled by cfg.
+func New(target string, cfg *config.Record) *Recorder {
+	return &Recorder{
+		cfg:     cfg,
+		target:  strings.ReplaceAll(target, string(os.PathSeparator), "_"),
+		enabled: cfg.Enabled == nil || *cfg.Enabled,
+	}
+}
+
+// Enabled returns whether messages are recorded
+func (r *Recorder) Enabled() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.enabled
+}
+
+// SetEnabled starts or stops recording. Stopping closes the current file, the next start opens a new one.
+func (r *Recorder) SetEnabled(enabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.enabled = enabled
+	if enabled {
+		return nil
+	}
+
+	return r.closeFile()
+}
+
+// Record writes data received at receivedAt
+func (r *Recorder) Record(receivedAt time.Time, data *pb.OpenConfigData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.enabled {
+		return nil
+	}
+
+	var err error
+	r.buf, err = appendEntry(r.buf[:0], receivedAt, data)
+	if err != nil {
+		return fmt.Errorf("unable to marshal message: %v", err)
+	}
+
+	if r.file != nil && r.rotationDue(receivedAt) {
+		err = r.closeFile()
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.file == nil {
+		err = r.openFile(receivedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = r.w.Write(r.buf)
+	if err != nil {
+		return err
+	}
+
+	r.size += uint64(len(r.buf))
+
+	// Uncompressed files are kept complete, gzip files are only complete once closed
+	return r.w.Flush()
+}
+
+// Close closes the current file
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.closeFile()
+}
+
+func (r *Recorder) rotationDue(now time.Time) bool {
+	if r.size >= r.cfg.MaxFileSizeMB<<20 {
+		return true
+	}
+
+	return now.Sub(r.opened) >= time.Duration(r.cfg.MaxFileAgeS)*time.Second
+}
+
+func (r *Recorder) openFile(now time.Time) error {
+	err := os.MkdirAll(r.cfg.Dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s%s", r.target, now.UTC().Format(timestampFormat), fileExtension)
+	if r.cfg.Gzip {
+		name += ".gz"
+	}
+
+	f, err := os.OpenFile(filepath.Join(r.cfg.Dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = f
+	if r.cfg.Gzip {
+		r.gz = gzip.NewWriter(f)
+		w = r.gz
+	}
+
+	r.file = f
+	r.w = bufio.NewWriter(w)
+	r.size = 0
+	r.opened = now
+
+	r.removeOldFiles()
+	return nil
+}
+
+func (r *Recorder) closeFile() error {
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.w.Flush()
+	if err == nil && r.gz != nil {
+		err = r.gz.Close()
+	}
+
+	closeErr := r.file.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	r.file = nil
+	r.gz = nil
+	r.w = nil
+
+	return err
+}
+
+// removeOldFiles removes the oldest files of the target exceeding MaxFiles
+func (r *Recorder) removeOldFiles() {
+	if r.cfg.MaxFiles == 0 {
+		return
+	}
+
+	files, err := Files(r.cfg.Dir, r.target)
+	if err != nil {
+		log.Errorf("Unable to list recordings of %s: %v", r.target, err)
+		return
+	}
+
+	for len(files) > r.cfg.MaxFiles {
+		err := os.Remove(files[0])
+		if err != nil {
+			log.Errorf("Unable to remove recording: %v", err)
+		}
+
+		files = files[1:]
+	}
+}
+
+// Files returns the recordings of target in dir ordered by time
+func Files(dir string, target string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, target+"-*"+fileExtension+"*"))
+	if err != nil {
+		return nil, err
+	}
+
+	// The pattern also matches the files of targets prefixed by target and a dash
+	files := make([]string, 0, len(matches))
+	for _, m := range matches {
+		ts := strings.TrimPrefix(filepath.Base(m), target+"-")
+		ts = ts[:strings.Index(ts, fileExtension)]
+		if _, err := time.Parse(timestampFormat, ts); err == nil {
+			files = append(files, m)
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,232 @@
+package recorder
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	pb "github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/telemetry"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func testMessage(seq uint64, size int) *pb.OpenConfigData {
+	return &pb.OpenConfigData{
+		SystemId:       "r1",
+		SequenceNumber: seq,
+		Timestamp:      1600000000000 + seq,
+		Kv: []*pb.KeyValue{
+			{
+				Key: "/system/state/description",
+				Value: &pb.KeyValue_StrValue{
+					StrValue: strings.Repeat("x", size),
+				},
+			},
+		},
+	}
+}
+
+func readAll(t *testing.T, files []string) ([]time.Time, []*pb.OpenConfigData) {
+	var times []time.Time
+	var msgs []*pb.OpenConfigData
+	for _, f := range files {
+		r, err := Open(f)
+		if err != nil {
+			t.Fatalf("Unable to open %s: %v", f, err)
+		}
+
+		for {
+			ts, data, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
+				t.Fatalf("Unable to read %s: %v", f, err)
+			}
+
+			times = append(times, ts)
+			msgs = append(msgs, data)
+		}
+
+		r.Close()
+	}
+
+	return times, msgs
+}
+
+func TestRecorder(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+
+	tests := []struct {
+		name string
+		cfg  config.Record
+		// offsets are the receive times of the messages relative to start
+		offsets []time.Duration
+		size    int
+		files   int
+		// kept are the sequence numbers of the messages in the kept files
+		kept []uint64
+	}{
+		{
+			name:    "Single file",
+			cfg:     config.Record{MaxFileSizeMB: 1, MaxFileAgeS: 60},
+			offsets: []time.Duration{0, time.Second, 2 * time.Second},
+			size:    10,
+			files:   1,
+			kept:    []uint64{0, 1, 2},
+		},
+		{
+			name:    "Rotation by age",
+			cfg:     config.Record{MaxFileSizeMB: 1, MaxFileAgeS: 60},
+			offsets: []time.Duration{0, 30 * time.Second, 60 * time.Second, 90 * time.Second},
+			size:    10,
+			files:   2,
+			kept:    []uint64{0, 1, 2, 3},
+		},
+		{
+			name:    "Rotation by size with gzip",
+			cfg:     config.Record{MaxFileSizeMB: 1, MaxFileAgeS: 60, Gzip: true},
+			offsets: []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second},
+			size:    600 << 10,
+			files:   2,
+			kept:    []uint64{0, 1, 2, 3},
+		},
+		{
+			name:    "Max files",
+			cfg:     config.Record{MaxFileSizeMB: 1, MaxFileAgeS: 60, MaxFiles: 2},
+			offsets: []time.Duration{0, 60 * time.Second, 120 * time.Second},
+			size:    10,
+			files:   2,
+			kept:    []uint64{1, 2},
+		},
+	}
+
+	for _, test := range tests {
+		dir, err := ioutil.TempDir("", "recorder")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cfg := test.cfg
+		cfg.Dir = dir
+		r := New("r1", &cfg)
+
+		for i, o := range test.offsets {
+			err := r.Record(start.Add(o), testMessage(uint64(i), test.size))
+			if err != nil {
+				t.Errorf("Unexpected error for test %q: %v", test.name, err)
+			}
+		}
+
+		r.Close()
+
+		files, err := Files(dir, "r1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, test.files, len(files), test.name)
+		if cfg.Gzip {
+			for _, f := range files {
+				assert.True(t, strings.HasSuffix(f, ".rec.gz"), test.name)
+			}
+		}
+
+		times, msgs := readAll(t, files)
+		expectedTimes := make([]time.Time, 0, len(test.kept))
+		for i, seq := range test.kept {
+			expectedTimes = append(expectedTimes, start.Add(test.offsets[seq]))
+			if i < len(msgs) {
+				assert.True(t, proto.Equal(testMessage(seq, test.size), msgs[i]), test.name)
+			}
+		}
+
+		assert.Equal(t, len(test.kept), len(msgs), test.name)
+		assert.Equal(t, expectedTimes, times, test.name)
+
+		os.RemoveAll(dir)
+	}
+}
+
+func TestRecorderSetEnabled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	disabled := false
+	r := New("r1", &config.Record{
+		Dir:           dir,
+		Enabled:       &disabled,
+		MaxFileSizeMB: 1,
+		MaxFileAgeS:   60,
+	})
+
+	start := time.Unix(1600000000, 0)
+	r.Record(start, testMessage(0, 10))
+	assert.False(t, r.Enabled())
+
+	r.SetEnabled(true)
+	r.Record(start.Add(time.Second), testMessage(1, 10))
+	r.SetEnabled(false)
+	r.Record(start.Add(2*time.Second), testMessage(2, 10))
+	r.SetEnabled(true)
+	r.Record(start.Add(3*time.Second), testMessage(3, 10))
+	r.Close()
+
+	files, err := Files(dir, "r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Stopping closes the file, so every start opens a new one
+	assert.Equal(t, 2, len(files))
+
+	_, msgs := readAll(t, files)
+	seqs := make([]uint64, 0, len(msgs))
+	for _, m := range msgs {
+		seqs = append(seqs, m.SequenceNumber)
+	}
+
+	assert.Equal(t, []uint64{1, 3}, seqs)
+}
+
+func TestFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{
+		"r1-20200913T122640.000000000Z.rec.gz",
+		"r1-20200913T122540.000000000Z.rec",
+		"r1-b-20200913T122540.000000000Z.rec",
+		"r1.log",
+	} {
+		err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := Files(dir, "r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		filepath.Join(dir, "r1-20200913T122540.000000000Z.rec"),
+		filepath.Join(dir, "r1-20200913T122640.000000000Z.rec.gz"),
+	}
+	assert.Equal(t, expected, files)
+}
@@ -0,0 +1,171 @@
+// Package relabel implements Prometheus style relabeling of label sets.
+package relabel
+
+import (
+	"crypto/md5"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/prometheus/common/model"
+)
+
+// Label is a label name/value pair
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Labels is a set of labels
+type Labels []Label
+
+// Get returns the value of the label name or an empty string
+func (ls Labels) Get(name string) string {
+	for _, l := range ls {
+		if l.Name == name {
+			return l.Value
+		}
+	}
+
+	return ""
+}
+
+// Set sets the label name to value. Labels with an empty value are removed.
+func (ls Labels) Set(name string, value string) Labels {
+	if value == "" {
+		return ls.Del(name)
+	}
+
+	for i := range ls {
+		if ls[i].Name == name {
+			ls[i].Value = value
+			return ls
+		}
+	}
+
+	return append(ls, Label{Name: name, Value: value})
+}
+
+// Del removes the label name
+func (ls Labels) Del(name string) Labels {
+	for i := range ls {
+		if ls[i].Name == name {
+			return append(ls[:i], ls[i+1:]...)
+		}
+	}
+
+	return ls
+}
+
+// Rule is a compiled relabel rule
+type Rule struct {
+	cfg   *config.RelabelConfig
+	regex *regexp.Regexp
+}
+
+// Compile compiles relabel configurations into rules
+func Compile(cfgs []*config.RelabelConfig) ([]*Rule, error) {
+	rules := make([]*Rule, 0, len(cfgs))
+	for _, c := range cfgs {
+		re, err := regexp.Compile("^(?:" + c.Regex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %v", c.Regex, err)
+		}
+
+		rules = append(rules, &Rule{
+			cfg:   c,
+			regex: re,
+		})
+	}
+
+	return rules, nil
+}
+
+// Process applies rules to ls. It returns nil if the label set is dropped.
+// ls may be modified.
+func Process(ls Labels, rules []*Rule) Labels {
+	for _, r := range rules {
+		ls = r.apply(ls)
+		if ls == nil {
+			return nil
+		}
+	}
+
+	return ls
+}
+
+func (r *Rule) apply(ls Labels) Labels {
+	values := make([]string, 0, len(r.cfg.SourceLabels))
+	for _, name := range r.cfg.SourceLabels {
+		values = append(values, ls.Get(name))
+	}
+	val := strings.Join(values, r.cfg.Separator)
+
+	switch r.cfg.Action {
+	case config.RelabelDrop:
+		if r.regex.MatchString(val) {
+			return nil
+		}
+	case config.RelabelKeep:
+		if !r.regex.MatchString(val) {
+			return nil
+		}
+	case config.RelabelReplace:
+		indexes := r.regex.FindStringSubmatchIndex(val)
+		if indexes == nil {
+			break
+		}
+
+		target := model.LabelName(r.regex.ExpandString([]byte{}, r.cfg.TargetLabel, val, indexes))
+		if !target.IsValid() {
+			break
+		}
+
+		res := r.regex.ExpandString([]byte{}, r.cfg.Replacement, val, indexes)
+		ls = ls.Set(string(target), string(res))
+	case config.RelabelHashMod:
+		mod := sum64(md5.Sum([]byte(val))) % r.cfg.Modulus
+		ls = ls.Set(r.cfg.TargetLabel, fmt.Sprintf("%d", mod))
+	case config.RelabelLabelMap:
+		res := make(Labels, len(ls))
+		copy(res, ls)
+		for _, l := range ls {
+			if r.regex.MatchString(l.Name) {
+				res = res.Set(r.regex.ReplaceAllString(l.Name, r.cfg.Replacement), l.Value)
+			}
+		}
+		ls = res
+	case config.RelabelLabelDrop:
+		res := ls[:0]
+		for _, l := range ls {
+			if !r.regex.MatchString(l.Name) {
+				res = append(res, l)
+			}
+		}
+		ls = res
+	case config.RelabelLabelKeep:
+		res := ls[:0]
+		for _, l := range ls {
+			if r.regex.MatchString(l.Name) {
+				res = append(res, l)
+			}
+		}
+		ls = res
+	}
+
+	return ls
+}
+
+// sum64 sums the md5 hash to an uint64
+func sum64(hash [md5.Size]byte) uint64 {
+	var s uint64
+
+	for i, b := range hash {
+		shift := uint64((md5.Size - 1 - i) * 8)
+
+		s |= uint64(b) << shift
+	}
+
+	return s
+}
@@ -0,0 +1,192 @@
+package relabel
+
+import (
+	"testing"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func relabelConfig(f func(c *config.RelabelConfig)) *config.RelabelConfig {
+	c := config.DefaultRelabelConfig
+	f(&c)
+	return &c
+}
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    Labels
+		cfgs     []*config.RelabelConfig
+		expected Labels
+	}{
+		{
+			name: "Replace metric name",
+			input: Labels{
+				{Name: "__name__", Value: "interfaces_interface_state_counters_in_octets"},
+				{Name: "interface_name", Value: "xe-0/0/0"},
+			},
+			cfgs: []*config.RelabelConfig{
+				relabelConfig(func(c *config.RelabelConfig) {
+					c.SourceLabels = []string{"__name__"}
+					c.Regex = "interfaces_interface_state_counters_(.*)"
+					c.TargetLabel = "__name__"
+					c.Replacement = "interface_${1}"
+				}),
+			},
+			expected: Labels{
+				{Name: "__name__", Value: "interface_in_octets"},
+				{Name: "interface_name", Value: "xe-0/0/0"},
+			},
+		},
+		{
+			name: "Replace extracts label from value",
+			input: Labels{
+				{Name: "__name__", Value: "foo"},
+				{Name: "interface_name", Value: "xe-0/0/0"},
+			},
+			cfgs: []*config.RelabelConfig{
+				relabelConfig(func(c *config.RelabelConfig) {
+					c.SourceLabels = []string{"interface_name"}
+					c.Regex = "([a-z]+)-(.*)"
+					c.TargetLabel = "interface_type"
+				}),
+			},
+			expected: Labels{
+				{Name: "__name__", Value: "foo"},
+				{Name: "interface_name", Value: "xe-0/0/0"},
+				{Name: "interface_type", Value: "xe"},
+			},
+		},
+		{
+			name: "Replace with non matching regex",
+			input: Labels{
+				{Name: "__name__", Value: "foo"},
+			},
+			cfgs: []*config.RelabelConfig{
+				relabelConfig(func(c *config.RelabelConfig) {
+					c.SourceLabels = []string{"__name__"}
+					c.Regex = "bar"
+					c.TargetLabel = "baz"
+				}),
+			},
+			expected: Labels{
+				{Name: "__name__", Value: "foo"},
+			},
+		},
+		{
+			name: "Keep",
+			input: Labels{
+				{Name: "__name__", Value: "foo"},
+			},
+			cfgs: []*config.RelabelConfig{
+				relabelConfig(func(c *config.RelabelConfig) {
+					c.Action = config.RelabelKeep
+					c.SourceLabels = []string{"__name__"}
+					c.Regex = "bar"
+				}),
+			},
+			expected: nil,
+		},
+		{
+			name: "Drop",
+			input: Labels{
+				{Name: "__name__", Value: "foo"},
+				{Name: "a", Value: "1"},
+			},
+			cfgs: []*config.RelabelConfig{
+				relabelConfig(func(c *config.RelabelConfig) {
+					c.Action = config.RelabelDrop
+					c.SourceLabels = []string{"__name__", "a"}
+					c.Regex = "foo;1"
+				}),
+			},
+			expected: nil,
+		},
+		{
+			name: "Labelmap",
+			input: Labels{
+				{Name: "__name__", Value: "foo"},
+				{Name: "interface_name", Value: "xe-0/0/0"},
+			},
+			cfgs: []*config.RelabelConfig{
+				relabelConfig(func(c *config.RelabelConfig) {
+					c.Action = config.RelabelLabelMap
+					c.Regex = "interface_(.*)"
+					c.Replacement = "if_$1"
+				}),
+			},
+			expected: Labels{
+				{Name: "__name__", Value: "foo"},
+				{Name: "interface_name", Value: "xe-0/0/0"},
+				{Name: "if_name", Value: "xe-0/0/0"},
+			},
+		},
+		{
+			name: "Labeldrop",
+			input: Labels{
+				{Name: "__name__", Value: "foo"},
+				{Name: "a", Value: "1"},
+				{Name: "b", Value: "2"},
+			},
+			cfgs: []*config.RelabelConfig{
+				relabelConfig(func(c *config.RelabelConfig) {
+					c.Action = config.RelabelLabelDrop
+					c.Regex = "a"
+				}),
+			},
+			expected: Labels{
+				{Name: "__name__", Value: "foo"},
+				{Name: "b", Value: "2"},
+			},
+		},
+		{
+			name: "Labelkeep",
+			input: Labels{
+				{Name: "__name__", Value: "foo"},
+				{Name: "a", Value: "1"},
+				{Name: "b", Value: "2"},
+			},
+			cfgs: []*config.RelabelConfig{
+				relabelConfig(func(c *config.RelabelConfig) {
+					c.Action = config.RelabelLabelKeep
+					c.Regex = "__name__|b"
+				}),
+			},
+			expected: Labels{
+				{Name: "__name__", Value: "foo"},
+				{Name: "b", Value: "2"},
+			},
+		},
+		{
+			name: "Hashmod",
+			input: Labels{
+				{Name: "__name__", Value: "foo"},
+				{Name: "a", Value: "baz"},
+			},
+			cfgs: []*config.RelabelConfig{
+				relabelConfig(func(c *config.RelabelConfig) {
+					c.Action = config.RelabelHashMod
+					c.SourceLabels = []string{"a"}
+					c.Modulus = 1000
+					c.TargetLabel = "hash"
+				}),
+			},
+			expected: Labels{
+				{Name: "__name__", Value: "foo"},
+				{Name: "a", Value: "baz"},
+				{Name: "hash", Value: "976"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		rules, err := Compile(test.cfgs)
+		if err != nil {
+			t.Errorf("Unexpected failure for test %q: %v", test.name, err)
+			continue
+		}
+
+		assert.Equal(t, test.expected, Process(test.input, rules), test.name)
+	}
+}
@@ -0,0 +1,66 @@
+package remotewrite
+
+import (
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of the Prometheus remote write protocol (prompb)
+const (
+	writeRequestTimeseries = 1
+	timeSeriesLabels       = 1
+	timeSeriesSamples      = 2
+	labelName              = 1
+	labelValue             = 2
+	sampleValue            = 1
+	sampleTimestamp        = 2
+)
+
+type label struct {
+	name  string
+	value string
+}
+
+// timeSeries is a series with a single sample
+type timeSeries struct {
+	labels    []label
+	value     float64
+	timestamp int64
+}
+
+// marshalWriteRequest encodes series as prompb.WriteRequest
+func marshalWriteRequest(series []timeSeries) []byte {
+	var b []byte
+	for _, ts := range series {
+		b = protowire.AppendTag(b, writeRequestTimeseries, protowire.BytesType)
+		b = protowire.AppendBytes(b, marshalTimeSeries(ts))
+	}
+
+	return b
+}
+
+func marshalTimeSeries(ts timeSeries) []byte {
+	var b []byte
+	for _, l := range ts.labels {
+		var lb []byte
+		lb = protowire.AppendTag(lb, labelName, protowire.BytesType)
+		lb = protowire.AppendString(lb, l.name)
+		lb = protowire.AppendTag(lb, labelValue, protowire.BytesType)
+		lb = protowire.AppendString(lb, l.value)
+
+		b = protowire.AppendTag(b, timeSeriesLabels, protowire.BytesType)
+		b = protowire.AppendBytes(b, lb)
+	}
+
+	var sb []byte
+	sb = protowire.AppendTag(sb, sampleValue, protowire.Fixed64Type)
+	sb = protowire.AppendFixed64(sb, math.Float64bits(ts.value))
+	sb = protowire.AppendTag(sb, sampleTimestamp, protowire.VarintType)
+	sb = protowire.AppendVarint(sb, uint64(ts.timestamp))
+
+	b = protowire.AppendTag(b, timeSeriesSamples, protowire.BytesType)
+	b = protowire.AppendBytes(b, sb)
+
+	return b
+}
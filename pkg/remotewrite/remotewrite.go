@@ -0,0 +1,281 @@
+// Package remotewrite pushes metrics to a Prometheus remote write endpoint
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	log "github.com/sirupsen/logrus"
+)
+
+const userAgent = "openconfig-streaming-telemetry-exporter"
+
+// Writer periodically pushes all metrics of a gatherer to a remote write endpoint
+type Writer struct {
+	cfg      *config.RemoteWrite
+	gatherer prometheus.Gatherer
+	client   *http.Client
+	queue    chan timeSeries
+	stats    *stats
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// New creates a new Writer
+func New(cfg *config.RemoteWrite, gatherer prometheus.Gatherer) *Writer {
+	w := &Writer{
+		cfg:      cfg,
+		gatherer: gatherer,
+		client: &http.Client{
+			Timeout: time.Duration(cfg.TimeoutS) * time.Second,
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{
+					InsecureSkipVerify: cfg.InsecureSkipVerify,
+				},
+			},
+		},
+		queue:  make(chan timeSeries, cfg.QueueCapacity),
+		stopCh: make(chan struct{}),
+	}
+
+	w.stats = newStats(w.queue)
+	return w
+}
+
+// Start starts pushing
+func (w *Writer) Start() {
+	w.wg.Add(2)
+	go w.gatherLoop()
+	go w.sendLoop()
+}
+
+// Stop stops pushing. Queued samples are discarded.
+func (w *Writer) Stop() {
+	close(w.stopCh)
+	w.wg.Wait()
+}
+
+// Stats returns a prometheus collector exposing the internal metrics of the writer
+func (w *Writer) Stats() prometheus.Collector {
+	return w.stats
+}
+
+func (w *Writer) gatherLoop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(time.Duration(w.cfg.IntervalS) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopCh:
+			return
+		case <-ticker.C:
+			w.enqueue(time.Now())
+		}
+	}
+}
+
+// enqueue gathers all metrics and adds them to the queue
+func (w *Writer) enqueue(now time.Time) {
+	mfs, err := w.gatherer.Gather()
+	if err != nil {
+		// Gather returns all metrics it could gather along with the error
+		log.Warningf("Remote write: gathering metrics failed: %v", err)
+	}
+
+	for _, ts := range toTimeSeries(mfs, now) {
+		select {
+		case w.queue <- ts:
+		default:
+			w.stats.droppedSamples.Inc()
+		}
+	}
+}
+
+// toTimeSeries converts the counters, gauges and untyped metrics of mfs to time series with timestamp now
+func toTimeSeries(mfs []*dto.MetricFamily, now time.Time) []timeSeries {
+	timestamp := now.UnixNano() / int64(time.Millisecond)
+
+	res := make([]timeSeries, 0)
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			var v float64
+			switch {
+			case m.Counter != nil:
+				v = m.Counter.GetValue()
+			case m.Gauge != nil:
+				v = m.Gauge.GetValue()
+			case m.Untyped != nil:
+				v = m.Untyped.GetValue()
+			default:
+				continue
+			}
+
+			labels := make([]label, 0, len(m.Label)+1)
+			labels = append(labels, label{name: model.MetricNameLabel, value: mf.GetName()})
+			for _, l := range m.Label {
+				labels = append(labels, label{name: l.GetName(), value: l.GetValue()})
+			}
+
+			ts := timeSeries{
+				labels:    labels,
+				value:     v,
+				timestamp: timestamp,
+			}
+
+			if m.TimestampMs != nil {
+				ts.timestamp = m.GetTimestampMs()
+			}
+
+			res = append(res, ts)
+		}
+	}
+
+	return res
+}
+
+func (w *Writer) sendLoop() {
+	defer w.wg.Done()
+
+	for {
+		var first timeSeries
+		select {
+		case <-w.stopCh:
+			return
+		case first = <-w.queue:
+		}
+
+		batch := append(make([]timeSeries, 0, w.cfg.BatchSize), first)
+	fill:
+		for len(batch) < w.cfg.BatchSize {
+			select {
+			case ts := <-w.queue:
+				batch = append(batch, ts)
+			default:
+				break fill
+			}
+		}
+
+		w.sendBatch(batch)
+	}
+}
+
+// sendBatch sends batch. Recoverable errors are retried with exponential backoff.
+func (w *Writer) sendBatch(batch []timeSeries) {
+	body := snappy.Encode(nil, marshalWriteRequest(batch))
+	backoff := time.Duration(w.cfg.MinBackoffMS) * time.Millisecond
+	maxBackoff := time.Duration(w.cfg.MaxBackoffMS) * time.Millisecond
+
+	for try := 0; ; try++ {
+		err := w.send(body)
+		if err == nil {
+			w.stats.sentSamples.Add(float64(len(batch)))
+			return
+		}
+
+		if _, ok := err.(recoverableError); !ok || try >= *w.cfg.MaxRetries {
+			log.Errorf("Remote write: dropping %d samples: %v", len(batch), err)
+			w.stats.failedSamples.Add(float64(len(batch)))
+			return
+		}
+
+		w.stats.retries.Inc()
+		select {
+		case <-w.stopCh:
+			log.Errorf("Remote write: dropping %d samples on shutdown: %v", len(batch), err)
+			w.stats.failedSamples.Add(float64(len(batch)))
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// recoverableError is an error that is worth retrying
+type recoverableError struct {
+	error
+}
+
+func (w *Writer) send(body []byte) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	err = w.authorize(req)
+	if err != nil {
+		return err
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return recoverableError{err}
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 256))
+	err = fmt.Errorf("server returned HTTP status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return recoverableError{err}
+	}
+
+	return err
+}
+
+func (w *Writer) authorize(req *http.Request) error {
+	switch {
+	case w.cfg.BasicAuth != nil:
+		password := w.cfg.BasicAuth.Password
+		if w.cfg.BasicAuth.PasswordFile != "" {
+			b, err := ioutil.ReadFile(w.cfg.BasicAuth.PasswordFile)
+			if err != nil {
+				return fmt.Errorf("unable to read password file: %v", err)
+			}
+
+			password = strings.TrimSpace(string(b))
+		}
+
+		req.SetBasicAuth(w.cfg.BasicAuth.Username, password)
+	case w.cfg.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+w.cfg.BearerToken)
+	case w.cfg.BearerTokenFile != "":
+		b, err := ioutil.ReadFile(w.cfg.BearerTokenFile)
+		if err != nil {
+			return fmt.Errorf("unable to read bearer token file: %v", err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(b)))
+	}
+
+	return nil
+}
@@ -0,0 +1,301 @@
+package remotewrite
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// receiver is a remote write endpoint stand-in
+type receiver struct {
+	statusCodes []int
+	requests    int
+	auth        []string
+	series      []timeSeries
+	done        chan struct{}
+	mu          sync.Mutex
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := http.StatusNoContent
+	if r.requests < len(r.statusCodes) {
+		status = r.statusCodes[r.requests]
+	}
+
+	r.requests++
+	r.auth = append(r.auth, req.Header.Get("Authorization"))
+
+	if status/100 == 2 {
+		b, _ := ioutil.ReadAll(req.Body)
+		decoded, err := snappy.Decode(nil, b)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		r.series = append(r.series, unmarshalWriteRequest(decoded)...)
+	}
+
+	w.WriteHeader(status)
+	if status/100 == 2 || status == http.StatusBadRequest {
+		close(r.done)
+	}
+}
+
+func unmarshalWriteRequest(b []byte) []timeSeries {
+	res := make([]timeSeries, 0)
+	forEachField(b, func(num protowire.Number, v []byte, _ uint64) {
+		ts := timeSeries{}
+		forEachField(v, func(num protowire.Number, v []byte, _ uint64) {
+			switch num {
+			case timeSeriesLabels:
+				l := label{}
+				forEachField(v, func(num protowire.Number, v []byte, _ uint64) {
+					if num == labelName {
+						l.name = string(v)
+						return
+					}
+
+					l.value = string(v)
+				})
+				ts.labels = append(ts.labels, l)
+			case timeSeriesSamples:
+				forEachField(v, func(num protowire.Number, _ []byte, x uint64) {
+					if num == sampleValue {
+						ts.value = math.Float64frombits(x)
+						return
+					}
+
+					ts.timestamp = int64(x)
+				})
+			}
+		})
+		res = append(res, ts)
+	})
+
+	return res
+}
+
+func forEachField(b []byte, f func(num protowire.Number, v []byte, x uint64)) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		b = b[n:]
+		switch typ {
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			f(num, v, 0)
+			b = b[n:]
+		case protowire.Fixed64Type:
+			x, n := protowire.ConsumeFixed64(b)
+			f(num, nil, x)
+			b = b[n:]
+		case protowire.VarintType:
+			x, n := protowire.ConsumeVarint(b)
+			f(num, nil, x)
+			b = b[n:]
+		default:
+			return
+		}
+	}
+}
+
+func intAddr(v int) *int {
+	return &v
+}
+
+func TestWriter(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+
+	tests := []struct {
+		name        string
+		cfg         config.RemoteWrite
+		statusCodes []int
+		auth        []string
+		sent        float64
+		failed      float64
+		retries     float64
+	}{
+		{
+			name: "Bearer token",
+			cfg: config.RemoteWrite{
+				BearerToken: "secret",
+			},
+			auth: []string{"Bearer secret"},
+			sent: 2,
+		},
+		{
+			name: "Basic auth",
+			cfg: config.RemoteWrite{
+				BasicAuth: &config.BasicAuth{
+					Username: "user",
+					Password: "pass",
+				},
+			},
+			auth: []string{"Basic dXNlcjpwYXNz"},
+			sent: 2,
+		},
+		{
+			name:        "Retry",
+			statusCodes: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests},
+			auth:        []string{"", "", ""},
+			sent:        2,
+			retries:     2,
+		},
+		{
+			name:        "Unrecoverable",
+			statusCodes: []int{http.StatusBadRequest},
+			auth:        []string{""},
+			failed:      2,
+		},
+	}
+
+	for _, test := range tests {
+		rcv := &receiver{
+			statusCodes: test.statusCodes,
+			done:        make(chan struct{}),
+		}
+
+		srv := httptest.NewServer(rcv)
+
+		reg := prometheus.NewRegistry()
+		g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "temperature"}, []string{"device"})
+		g.WithLabelValues("r1").Set(42.5)
+		c := prometheus.NewCounter(prometheus.CounterOpts{Name: "packets_total"})
+		c.Add(10)
+		reg.MustRegister(g, c)
+
+		cfg := test.cfg
+		cfg.URL = srv.URL
+		cfg.IntervalS = 3600
+		cfg.BatchSize = 10
+		cfg.QueueCapacity = 10
+		cfg.MaxRetries = intAddr(3)
+		cfg.MinBackoffMS = 1
+		cfg.MaxBackoffMS = 2
+		cfg.TimeoutS = 5
+
+		w := New(&cfg, reg)
+		w.Start()
+		w.enqueue(now)
+
+		select {
+		case <-rcv.done:
+		case <-time.After(5 * time.Second):
+			t.Errorf("Timeout for test %q", test.name)
+		}
+
+		w.Stop()
+		srv.Close()
+
+		assert.Equal(t, test.auth, rcv.auth, test.name)
+		assert.Equal(t, test.sent, testutil.ToFloat64(w.stats.sentSamples), test.name)
+		assert.Equal(t, test.failed, testutil.ToFloat64(w.stats.failedSamples), test.name)
+		assert.Equal(t, test.retries, testutil.ToFloat64(w.stats.retries), test.name)
+
+		if test.sent == 0 {
+			continue
+		}
+
+		expected := []timeSeries{
+			{
+				labels:    []label{{name: "__name__", value: "packets_total"}},
+				value:     10,
+				timestamp: 1600000000000,
+			},
+			{
+				labels:    []label{{name: "__name__", value: "temperature"}, {name: "device", value: "r1"}},
+				value:     42.5,
+				timestamp: 1600000000000,
+			},
+		}
+		assert.Equal(t, expected, rcv.series, test.name)
+	}
+}
+
+func TestEnqueueQueueFull(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "temperature"}, []string{"device"})
+	for _, d := range []string{"r1", "r2", "r3"} {
+		g.WithLabelValues(d).Set(1)
+	}
+
+	reg.MustRegister(g)
+
+	w := New(&config.RemoteWrite{
+		URL:           "http://127.0.0.1:0",
+		IntervalS:     3600,
+		TimeoutS:      1,
+		BatchSize:     2,
+		QueueCapacity: 2,
+	}, reg)
+	w.enqueue(time.Now())
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(w.stats.droppedSamples))
+	assert.Equal(t, 2, len(w.queue))
+}
+
+func TestSendBatchFailed(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRetries int
+		stop       bool
+		requests   int
+		retries    float64
+	}{
+		{
+			name:       "Retries disabled",
+			maxRetries: 0,
+			requests:   1,
+		},
+		{
+			name:       "Stopped during backoff",
+			maxRetries: 3,
+			stop:       true,
+			requests:   1,
+			retries:    1,
+		},
+	}
+
+	for _, test := range tests {
+		rcv := &receiver{
+			statusCodes: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+			done:        make(chan struct{}),
+		}
+
+		srv := httptest.NewServer(rcv)
+
+		w := New(&config.RemoteWrite{
+			URL:           srv.URL,
+			TimeoutS:      5,
+			BatchSize:     10,
+			QueueCapacity: 10,
+			MaxRetries:    intAddr(test.maxRetries),
+			MinBackoffMS:  3600000,
+			MaxBackoffMS:  3600000,
+		}, prometheus.NewRegistry())
+		if test.stop {
+			close(w.stopCh)
+		}
+
+		w.sendBatch([]timeSeries{{}, {}})
+		srv.Close()
+
+		assert.Equal(t, test.requests, rcv.requests, test.name)
+		assert.Equal(t, float64(2), testutil.ToFloat64(w.stats.failedSamples), test.name)
+		assert.Equal(t, test.retries, testutil.ToFloat64(w.stats.retries), test.name)
+	}
+}
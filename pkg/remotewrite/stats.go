@@ -0,0 +1,31 @@
+package remotewrite
+
+import (
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/output"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// stats are the internal metrics of the writer
+type stats struct {
+	*output.Stats
+	sentSamples    prometheus.Counter
+	failedSamples  prometheus.Counter
+	droppedSamples prometheus.Counter
+	retries        prometheus.Counter
+}
+
+func newStats(queue chan timeSeries) *stats {
+	s := &stats{
+		Stats: output.NewStats("remote_write"),
+	}
+
+	s.sentSamples = s.Counter("sent_samples_total", "Number of samples sent successfully")
+	s.failedSamples = s.Counter("failed_samples_total", "Number of samples that could not be sent")
+	s.droppedSamples = s.Counter("dropped_samples_total", "Number of samples dropped because the queue was full")
+	s.retries = s.Counter("retries_total", "Number of retried requests")
+	s.QueueLength("Number of samples waiting to be sent", func() int {
+		return len(queue)
+	})
+
+	return s
+}
@@ -0,0 +1,163 @@
+// Package replay serves recorded telemetry streams like a device would
+package replay
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/recorder"
+	pb "github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/telemetry"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Options control the replay
+type Options struct {
+	// Speedup divides the time between messages. 0 sends all messages without delay.
+	Speedup float64
+	// Loop restarts the replay at the end of the recording
+	Loop bool
+	// RewriteTimestamps replaces the device timestamps by the time the messages are sent
+	RewriteTimestamps bool
+}
+
+// Server implements OpenConfigTelemetryServer streaming the messages of a recording to every subscriber
+type Server struct {
+	files []string
+	opts  Options
+}
+
+// New creates a server replaying files in the given order
+func New(files []string, opts Options) *Server {
+	return &Server{
+		files: files,
+		opts:  opts,
+	}
+}
+
+// TelemetrySubscribe streams the recording with its original timing. The paths of the request are ignored.
+func (s *Server) TelemetrySubscribe(req *pb.SubscriptionRequest, stream pb.OpenConfigTelemetry_TelemetrySubscribeServer) error {
+	for {
+		sent, err := s.replay(stream.Context(), stream)
+		if err != nil {
+			return err
+		}
+
+		// An empty recording would loop forever
+		if !s.opts.Loop || sent == 0 {
+			return nil
+		}
+	}
+}
+
+// replay sends all messages of the recording once and returns the number of messages sent
+func (s *Server) replay(ctx context.Context, stream pb.OpenConfigTelemetry_TelemetrySubscribeServer) (int, error) {
+	start := time.Now()
+	var first time.Time
+	sent := 0
+
+	for _, f := range s.files {
+		r, err := recorder.Open(f)
+		if err != nil {
+			return sent, status.Errorf(codes.Internal, "unable to open recording: %v", err)
+		}
+
+		for {
+			receivedAt, data, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
+				// Recordings may end with a partial entry, e.g. if the exporter has been killed
+				log.Warningf("Skipping rest of %s: %v", f, err)
+				break
+			}
+
+			if first.IsZero() {
+				first = receivedAt
+			}
+
+			err = s.wait(ctx, start, receivedAt.Sub(first))
+			if err == nil {
+				if s.opts.RewriteTimestamps {
+					data.Timestamp = uint64(time.Now().UnixNano() / int64(time.Millisecond))
+				}
+
+				err = stream.Send(data)
+			}
+
+			if err != nil {
+				r.Close()
+				return sent, err
+			}
+
+			sent++
+		}
+
+		r.Close()
+	}
+
+	return sent, nil
+}
+
+// wait waits until offset (divided by the speedup) has passed since start
+func (s *Server) wait(ctx context.Context, start time.Time, offset time.Duration) error {
+	if s.opts.Speedup <= 0 {
+		return ctx.Err()
+	}
+
+	d := time.Until(start.Add(time.Duration(float64(offset) / s.opts.Speedup)))
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// CancelTelemetrySubscription is not supported
+func (s *Server) CancelTelemetrySubscription(context.Context, *pb.CancelSubscriptionRequest) (*pb.CancelSubscriptionReply, error) {
+	return nil, status.Error(codes.Unimplemented, "not supported by replay")
+}
+
+// GetTelemetrySubscriptions is not supported
+func (s *Server) GetTelemetrySubscriptions(context.Context, *pb.GetSubscriptionsRequest) (*pb.GetSubscriptionsReply, error) {
+	return nil, status.Error(codes.Unimplemented, "not supported by replay")
+}
+
+// GetTelemetryOperationalState is not supported
+func (s *Server) GetTelemetryOperationalState(context.Context, *pb.GetOperationalStateRequest) (*pb.GetOperationalStateReply, error) {
+	return nil, status.Error(codes.Unimplemented, "not supported by replay")
+}
+
+// GetDataEncodings returns protobuf, the only encoding of recordings
+func (s *Server) GetDataEncodings(context.Context, *pb.DataEncodingRequest) (*pb.DataEncodingReply, error) {
+	return &pb.DataEncodingReply{
+		EncodingList: []pb.EncodingType{pb.EncodingType_PROTO3},
+	}, nil
+}
+
+// Validate checks that all files can be opened
+func (s *Server) Validate() error {
+	for _, f := range s.files {
+		r, err := recorder.Open(f)
+		if err != nil {
+			return fmt.Errorf("invalid recording: %v", err)
+		}
+
+		r.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package replay
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/recorder"
+	pb "github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/telemetry"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+// writeRecording records messages with sequence numbers 0 to n-1 received interval apart
+func writeRecording(t *testing.T, dir string, n int, interval time.Duration) []string {
+	r := recorder.New("r1", &config.Record{
+		Dir:           dir,
+		MaxFileSizeMB: 1,
+		MaxFileAgeS:   3600,
+	})
+
+	start := time.Unix(1600000000, 0)
+	for i := 0; i < n; i++ {
+		err := r.Record(start.Add(time.Duration(i)*interval), &pb.OpenConfigData{
+			SystemId:       "r1",
+			SequenceNumber: uint64(i),
+			Timestamp:      1600000000000 + uint64(i),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r.Close()
+
+	files, err := recorder.Files(dir, "r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return files
+}
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	files := writeRecording(t, dir, 3, time.Second)
+
+	tests := []struct {
+		name        string
+		opts        Options
+		sequence    []uint64
+		minDuration time.Duration
+		maxDuration time.Duration
+	}{
+		{
+			name:        "Speedup",
+			opts:        Options{Speedup: 10},
+			sequence:    []uint64{0, 1, 2},
+			minDuration: 200 * time.Millisecond,
+			maxDuration: 2 * time.Second,
+		},
+		{
+			name:        "Loop without delay",
+			opts:        Options{Loop: true},
+			sequence:    []uint64{0, 1, 2, 0, 1, 2, 0},
+			maxDuration: time.Second,
+		},
+		{
+			name:        "Rewrite timestamps",
+			opts:        Options{RewriteTimestamps: true},
+			sequence:    []uint64{0, 1, 2},
+			maxDuration: time.Second,
+		},
+	}
+
+	for _, test := range tests {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s := grpc.NewServer()
+		pb.RegisterOpenConfigTelemetryServer(s, New(files, test.opts))
+		go s.Serve(lis)
+
+		conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		start := time.Now()
+		stream, err := pb.NewOpenConfigTelemetryClient(conn).TelemetrySubscribe(ctx, &pb.SubscriptionRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sequence := make([]uint64, 0)
+		for len(sequence) < len(test.sequence) {
+			data, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error for test %q: %v", test.name, err)
+			}
+
+			sequence = append(sequence, data.SequenceNumber)
+			if test.opts.RewriteTimestamps {
+				assert.True(t, data.Timestamp >= uint64(start.UnixNano()/int64(time.Millisecond)), test.name)
+			} else {
+				assert.Equal(t, 1600000000000+data.SequenceNumber, data.Timestamp, test.name)
+			}
+		}
+
+		d := time.Since(start)
+		cancel()
+		conn.Close()
+		s.Stop()
+
+		assert.Equal(t, test.sequence, sequence, test.name)
+		assert.True(t, d >= test.minDuration, "%s: replay took %v", test.name, d)
+		assert.True(t, d <= test.maxDuration, "%s: replay took %v", test.name, d)
+	}
+}
@@ -0,0 +1,242 @@
+// Package schema derives metric metadata from YANG modules.
+package schema
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/openconfig/goyang/pkg/yang"
+)
+
+var (
+	counterTypes = map[string]struct{}{
+		"counter32":            {},
+		"counter64":            {},
+		"zero-based-counter32": {},
+		"zero-based-counter64": {},
+	}
+
+	// units maps YANG units to Prometheus base units and the factor values have to be scaled by
+	units = map[string]unit{
+		"bytes":        {suffix: "bytes", scale: 1},
+		"octets":       {suffix: "bytes", scale: 1},
+		"bits":         {suffix: "bits", scale: 1},
+		"seconds":      {suffix: "seconds", scale: 1},
+		"milliseconds": {suffix: "seconds", scale: 1e-3},
+		"microseconds": {suffix: "seconds", scale: 1e-6},
+		"nanoseconds":  {suffix: "seconds", scale: 1e-9},
+		"celsius":      {suffix: "celsius", scale: 1},
+		"dbm":          {suffix: "dbm", scale: 1},
+		"db":           {suffix: "db", scale: 1},
+		"percent":      {suffix: "percent", scale: 1},
+		"watts":        {suffix: "watts", scale: 1},
+		"volts":        {suffix: "volts", scale: 1},
+		"amps":         {suffix: "amperes", scale: 1},
+		"hertz":        {suffix: "hertz", scale: 1},
+	}
+)
+
+type unit struct {
+	suffix string
+	scale  float64
+}
+
+// Leaf is the metadata of a YANG leaf
+type Leaf struct {
+	// Path is the schema path of the leaf without list keys, e.g. /interfaces/interface/state/counters/in-octets
+	Path        string
+	Description string
+	Units       string
+	// Counter is true for counter32/counter64 typed leafs
+	Counter bool
+	// Enum maps the names of an enumeration to their values
+	Enum map[string]float64
+}
+
+// Schema holds the metadata of all leafs of a set of YANG modules
+type Schema struct {
+	leafs map[string]*Leaf
+}
+
+// Load loads all YANG modules (*.yang) found in dir and its subdirectories
+func Load(dir string) (*Schema, error) {
+	ms := yang.NewModules()
+
+	files := make([]string, 0)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			ms.AddPath(path)
+			return nil
+		}
+
+		if filepath.Ext(path) == ".yang" {
+			files = append(files, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read YANG directory %q: %v", dir, err)
+	}
+
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		err = ms.Parse(string(data), f)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse %q: %v", f, err)
+		}
+	}
+
+	errs := ms.Process()
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("unable to process YANG modules: %v", errs[0])
+	}
+
+	s := &Schema{
+		leafs: make(map[string]*Leaf),
+	}
+
+	for _, m := range ms.Modules {
+		e := yang.ToEntry(m)
+		for _, child := range e.Dir {
+			s.addEntry("", child)
+		}
+	}
+
+	return s, nil
+}
+
+func (s *Schema) addEntry(parent string, e *yang.Entry) {
+	path := parent
+	// choice and case statements are not part of the data tree
+	if !e.IsChoice() && !e.IsCase() {
+		path = parent + "/" + e.Name
+	}
+
+	if e.IsDir() {
+		for _, child := range e.Dir {
+			s.addEntry(path, child)
+		}
+
+		return
+	}
+
+	if e.Type == nil {
+		return
+	}
+
+	l := &Leaf{
+		Path:        path,
+		Description: strings.Join(strings.Fields(e.Description), " "),
+		Units:       e.Units,
+		Counter:     isCounter(e.Type),
+	}
+
+	if l.Units == "" {
+		l.Units = nodeUnits(e.Node)
+	}
+
+	if l.Units == "" {
+		l.Units = e.Type.Units
+	}
+
+	if e.Type.Kind == yang.Yenum && e.Type.Enum != nil {
+		l.Enum = make(map[string]float64)
+		for name, v := range e.Type.Enum.NameMap() {
+			l.Enum[name] = float64(v)
+		}
+	}
+
+	s.leafs[path] = l
+}
+
+// nodeUnits returns the units statement of a leaf or leaf-list as goyang doesn't copy it to the entry
+func nodeUnits(n yang.Node) string {
+	switch leaf := n.(type) {
+	case *yang.Leaf:
+		if leaf.Units != nil {
+			return leaf.Units.Name
+		}
+	case *yang.LeafList:
+		if leaf.Units != nil {
+			return leaf.Units.Name
+		}
+	}
+
+	return ""
+}
+
+func isCounter(t *yang.YangType) bool {
+	for ; t != nil; t = baseType(t) {
+		name := t.Name
+		if i := strings.LastIndex(name, ":"); i >= 0 {
+			name = name[i+1:]
+		}
+
+		if _, ok := counterTypes[name]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func baseType(t *yang.YangType) *yang.YangType {
+	if t.Base == nil || t.Base.YangType == t {
+		return nil
+	}
+
+	return t.Base.YangType
+}
+
+// Leaf returns the metadata of the leaf at path or nil if it is unknown
+func (s *Schema) Leaf(path string) *Leaf {
+	if s == nil {
+		return nil
+	}
+
+	return s.leafs[path]
+}
+
+// StringValueMapping returns a string value mapping for all enumeration leafs
+func (s *Schema) StringValueMapping() config.StringValueMapping {
+	res := make(config.StringValueMapping)
+	for path, l := range s.leafs {
+		if l.Enum == nil {
+			continue
+		}
+
+		res[path] = &config.StringValueMap{
+			Values: l.Enum,
+		}
+	}
+
+	return res
+}
+
+// UnitSuffix returns the Prometheus unit suffix for the leaf or an empty string
+func (l *Leaf) UnitSuffix() string {
+	return units[strings.ToLower(l.Units)].suffix
+}
+
+// Scale returns the factor values of the leaf have to be multiplied with to match the base unit
+func (l *Leaf) Scale() float64 {
+	u, ok := units[strings.ToLower(l.Units)]
+	if !ok {
+		return 1
+	}
+
+	return u.scale
+}
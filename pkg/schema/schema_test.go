@@ -0,0 +1,119 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	s, err := Load("testdata")
+	if err != nil {
+		t.Fatalf("Unable to load schema: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		expected *Leaf
+	}{
+		{
+			name: "Counter",
+			path: "/interfaces/interface/state/counters/in-octets",
+			expected: &Leaf{
+				Path:        "/interfaces/interface/state/counters/in-octets",
+				Description: "The total number of octets received on the interface.",
+				Counter:     true,
+			},
+		},
+		{
+			name: "Gauge with units",
+			path: "/interfaces/interface/state/counters/last-clear",
+			expected: &Leaf{
+				Path:        "/interfaces/interface/state/counters/last-clear",
+				Description: "Time the counters were last cleared",
+				Units:       "nanoseconds",
+			},
+		},
+		{
+			name: "Enumeration",
+			path: "/interfaces/interface/state/oper-status",
+			expected: &Leaf{
+				Path:        "/interfaces/interface/state/oper-status",
+				Description: "The current operational state of the interface",
+				Enum: map[string]float64{
+					"UP":               1,
+					"DOWN":             2,
+					"LOWER_LAYER_DOWN": 7,
+				},
+			},
+		},
+		{
+			name:     "Unknown",
+			path:     "/interfaces/interface/state/foo",
+			expected: nil,
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, s.Leaf(test.path), test.name)
+	}
+}
+
+func TestUnits(t *testing.T) {
+	tests := []struct {
+		name           string
+		leaf           *Leaf
+		expectedSuffix string
+		expectedScale  float64
+	}{
+		{
+			name:           "Octets",
+			leaf:           &Leaf{Units: "octets"},
+			expectedSuffix: "bytes",
+			expectedScale:  1,
+		},
+		{
+			name:           "Nanoseconds",
+			leaf:           &Leaf{Units: "nanoseconds"},
+			expectedSuffix: "seconds",
+			expectedScale:  1e-9,
+		},
+		{
+			name:           "Unknown unit",
+			leaf:           &Leaf{Units: "furlongs"},
+			expectedSuffix: "",
+			expectedScale:  1,
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expectedSuffix, test.leaf.UnitSuffix(), test.name)
+		assert.Equal(t, test.expectedScale, test.leaf.Scale(), test.name)
+	}
+}
+
+func TestStringValueMapping(t *testing.T) {
+	s, err := Load("testdata")
+	if err != nil {
+		t.Fatalf("Unable to load schema: %v", err)
+	}
+
+	assert.Equal(t, config.StringValueMapping{
+		"/interfaces/interface/state/oper-status": {
+			Values: map[string]float64{
+				"UP":               1,
+				"DOWN":             2,
+				"LOWER_LAYER_DOWN": 7,
+			},
+		},
+		"/interfaces/interface/state/admin-status": {
+			Values: map[string]float64{
+				"UP":      0,
+				"DOWN":    1,
+				"TESTING": 2,
+			},
+		},
+	}, s.StringValueMapping())
+}
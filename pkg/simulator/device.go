@@ -0,0 +1,197 @@
+// Package simulator simulates devices streaming interface and BGP telemetry for load tests
+package simulator
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	pb "github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/telemetry"
+)
+
+// Options describe the simulated devices
+type Options struct {
+	Interfaces int
+	Neighbors  int
+	// SampleFrequencyMS is used for subscription paths without sample frequency
+	SampleFrequencyMS uint32
+	Seed              int64
+}
+
+// Device is the simulated state of a device. Counters advance with the time passed between samples.
+type Device struct {
+	name       string
+	mu         sync.Mutex
+	rng        *rand.Rand
+	interfaces []*iface
+	neighbors  []*neighbor
+	last       time.Time
+}
+
+type iface struct {
+	name        string
+	description string
+	mtu         uint64
+	operStatus  string
+	// rate is the number of octets per second received and sent
+	rate      float64
+	inOctets  uint64
+	outOctets uint64
+	inPkts    uint64
+	outPkts   uint64
+	inErrors  uint64
+}
+
+type neighbor struct {
+	address           string
+	peerAS            uint64
+	sessionState      string
+	prefixesReceived  uint64
+	prefixesSent      uint64
+	prefixesInstalled uint64
+}
+
+// leafGroup are the leaves below a common prefix which are sent in one message
+type leafGroup struct {
+	// prefix is the path of the group including list keys
+	prefix string
+	leaves []leaf
+}
+
+type leaf struct {
+	key   string
+	value interface{}
+}
+
+// NewDevice creates a device named name
+func NewDevice(name string, opts Options) *Device {
+	d := &Device{
+		name: name,
+		rng:  rand.New(rand.NewSource(opts.Seed)),
+	}
+
+	for i := 0; i < opts.Interfaces; i++ {
+		d.interfaces = append(d.interfaces, &iface{
+			name:        fmt.Sprintf("et-0/0/%d", i),
+			description: fmt.Sprintf("customer=cust%d,role=access", i%10),
+			mtu:         9192,
+			operStatus:  "UP",
+			rate:        d.rng.Float64() * 1e9,
+		})
+	}
+
+	for i := 0; i < opts.Neighbors; i++ {
+		prefixes := uint64(d.rng.Intn(100000))
+		d.neighbors = append(d.neighbors, &neighbor{
+			address:           fmt.Sprintf("2001:db8::%x", i+1),
+			peerAS:            uint64(64512 + i),
+			sessionState:      "ESTABLISHED",
+			prefixesReceived:  prefixes,
+			prefixesSent:      uint64(d.rng.Intn(1000)),
+			prefixesInstalled: prefixes,
+		})
+	}
+
+	return d
+}
+
+// advance updates the state to now
+func (d *Device) advance(now time.Time) {
+	if d.last.IsZero() {
+		d.last = now
+		return
+	}
+
+	elapsed := now.Sub(d.last).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+
+	d.last = now
+	for _, i := range d.interfaces {
+		if i.operStatus != "UP" {
+			continue
+		}
+
+		in := uint64(i.rate * elapsed * (0.5 + d.rng.Float64()))
+		out := uint64(i.rate * elapsed * (0.5 + d.rng.Float64()))
+		i.inOctets += in
+		i.outOctets += out
+		i.inPkts += in / 800
+		i.outPkts += out / 800
+		if d.rng.Intn(100) == 0 {
+			i.inErrors++
+		}
+	}
+
+	for _, n := range d.neighbors {
+		// Routes change occasionally, so most samples of a neighbor are unchanged
+		if d.rng.Intn(10) != 0 {
+			continue
+		}
+
+		delta := uint64(d.rng.Intn(10))
+		if d.rng.Intn(2) == 0 && n.prefixesReceived >= delta {
+			n.prefixesReceived -= delta
+		} else {
+			n.prefixesReceived += delta
+		}
+
+		n.prefixesInstalled = n.prefixesReceived
+	}
+}
+
+// sample advances the state to now and returns all leaves
+func (d *Device) sample(now time.Time) []*leafGroup {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.advance(now)
+
+	res := make([]*leafGroup, 0, len(d.interfaces)+len(d.neighbors))
+	for _, i := range d.interfaces {
+		res = append(res, &leafGroup{
+			prefix: fmt.Sprintf("/interfaces/interface[name='%s']/", i.name),
+			leaves: []leaf{
+				{key: "state/description", value: i.description},
+				{key: "state/mtu", value: i.mtu},
+				{key: "state/admin-status", value: "UP"},
+				{key: "state/oper-status", value: i.operStatus},
+				{key: "state/counters/in-octets", value: i.inOctets},
+				{key: "state/counters/out-octets", value: i.outOctets},
+				{key: "state/counters/in-pkts", value: i.inPkts},
+				{key: "state/counters/out-pkts", value: i.outPkts},
+				{key: "state/counters/in-errors", value: i.inErrors},
+			},
+		})
+	}
+
+	for _, n := range d.neighbors {
+		res = append(res, &leafGroup{
+			prefix: fmt.Sprintf("/network-instances/network-instance[name='master']/protocols/protocol[identifier='BGP'][name='bgp']"+
+				"/bgp/neighbors/neighbor[neighbor-address='%s']/", n.address),
+			leaves: []leaf{
+				{key: "state/peer-as", value: n.peerAS},
+				{key: "state/session-state", value: n.sessionState},
+				{key: "afi-safis/afi-safi[afi-safi-name='IPV4_UNICAST']/state/prefixes/received", value: n.prefixesReceived},
+				{key: "afi-safis/afi-safi[afi-safi-name='IPV4_UNICAST']/state/prefixes/sent", value: n.prefixesSent},
+				{key: "afi-safis/afi-safi[afi-safi-name='IPV4_UNICAST']/state/prefixes/installed", value: n.prefixesInstalled},
+			},
+		})
+	}
+
+	return res
+}
+
+// Messages advances the state to now and returns the messages a subscription of paths without suppression receives
+func (d *Device) Messages(paths []string, now time.Time) []*pb.OpenConfigData {
+	groups := d.sample(now)
+
+	res := make([]*pb.OpenConfigData, 0, len(groups))
+	for _, p := range paths {
+		res = append(res, newSubscription(&pb.Path{Path: p}).messages(d.name, groups, now)...)
+	}
+
+	return res
+}
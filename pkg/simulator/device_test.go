@@ -0,0 +1,19 @@
+package simulator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDeviceNeighborAddresses(t *testing.T) {
+	d := NewDevice("r1", Options{Neighbors: 1000})
+
+	addresses := make(map[string]struct{})
+	for _, n := range d.neighbors {
+		addresses[n.address] = struct{}{}
+	}
+
+	assert.Equal(t, 1000, len(addresses))
+	assert.Equal(t, "2001:db8::1", d.neighbors[0].address)
+}
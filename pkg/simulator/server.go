@@ -0,0 +1,201 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/telemetry"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultSampleFrequencyMS is used if neither the path nor the options specify a sample frequency
+const defaultSampleFrequencyMS = 1000
+
+// allSubscriptions is the subscription id requesting all subscriptions
+const allSubscriptions = 0xFFFFFFFF
+
+// Server implements OpenConfigTelemetryServer for a simulated device
+type Server struct {
+	device *Device
+	opts   Options
+	mu     sync.Mutex
+	nextID uint32
+	active map[uint32]*activeSubscription
+}
+
+type activeSubscription struct {
+	paths  []*pb.Path
+	cancel context.CancelFunc
+}
+
+// NewServer creates a server simulating a device named name
+func NewServer(name string, opts Options) *Server {
+	return &Server{
+		device: NewDevice(name, opts),
+		opts:   opts,
+		active: make(map[uint32]*activeSubscription),
+	}
+}
+
+// TelemetrySubscribe streams the subscribed paths until the client disconnects or the subscription is cancelled
+func (s *Server) TelemetrySubscribe(req *pb.SubscriptionRequest, stream pb.OpenConfigTelemetry_TelemetrySubscribeServer) error {
+	if len(req.PathList) == 0 {
+		return status.Error(codes.InvalidArgument, "no paths requested")
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	id := s.register(req.PathList, cancel)
+	defer s.unregister(id)
+
+	var sendMu sync.Mutex
+	errCh := make(chan error, len(req.PathList))
+	var wg sync.WaitGroup
+	for _, p := range req.PathList {
+		wg.Add(1)
+		go func(sub *subscription) {
+			defer wg.Done()
+
+			err := s.stream(ctx, sub, func(data *pb.OpenConfigData) error {
+				sendMu.Lock()
+				defer sendMu.Unlock()
+
+				return stream.Send(data)
+			})
+			if err != nil {
+				errCh <- err
+				cancel()
+			}
+		}(newSubscription(p))
+	}
+
+	wg.Wait()
+
+	select {
+	case err := <-errCh:
+		return err
+	default:
+		return nil
+	}
+}
+
+// stream sends the messages of sub every sample interval until ctx is done
+func (s *Server) stream(ctx context.Context, sub *subscription, send func(data *pb.OpenConfigData) error) error {
+	freq := sub.path.SampleFrequency
+	if freq == 0 {
+		freq = s.opts.SampleFrequencyMS
+	}
+
+	if freq == 0 {
+		freq = defaultSampleFrequencyMS
+	}
+
+	ticker := time.NewTicker(time.Duration(freq) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		for _, data := range sub.messages(s.device.name, s.device.sample(now), now) {
+			err := send(data)
+			if err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) register(paths []*pb.Path, cancel context.CancelFunc) uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	s.active[s.nextID] = &activeSubscription{
+		paths:  paths,
+		cancel: cancel,
+	}
+
+	return s.nextID
+}
+
+func (s *Server) unregister(id uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.active, id)
+}
+
+// CancelTelemetrySubscription ends the stream of a subscription
+func (s *Server) CancelTelemetrySubscription(ctx context.Context, req *pb.CancelSubscriptionRequest) (*pb.CancelSubscriptionReply, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.active[req.SubscriptionId]
+	if !ok {
+		return &pb.CancelSubscriptionReply{
+			Code:    pb.ReturnCode_NO_SUBSCRIPTION_ENTRY,
+			CodeStr: fmt.Sprintf("subscription %d not found", req.SubscriptionId),
+		}, nil
+	}
+
+	sub.cancel()
+	return &pb.CancelSubscriptionReply{
+		Code: pb.ReturnCode_SUCCESS,
+	}, nil
+}
+
+// GetTelemetrySubscriptions returns the active subscriptions or the one requested by id
+func (s *Server) GetTelemetrySubscriptions(ctx context.Context, req *pb.GetSubscriptionsRequest) (*pb.GetSubscriptionsReply, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]uint32, 0, len(s.active))
+	for id := range s.active {
+		if req.SubscriptionId == 0 || req.SubscriptionId == allSubscriptions || req.SubscriptionId == id {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	res := &pb.GetSubscriptionsReply{}
+	for _, id := range ids {
+		res.SubscriptionList = append(res.SubscriptionList, &pb.SubscriptionReply{
+			Response: &pb.SubscriptionResponse{SubscriptionId: id},
+			PathList: s.active[id].paths,
+		})
+	}
+
+	return res, nil
+}
+
+// GetTelemetryOperationalState returns the number of simulated objects and active subscriptions
+func (s *Server) GetTelemetryOperationalState(ctx context.Context, req *pb.GetOperationalStateRequest) (*pb.GetOperationalStateReply, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return &pb.GetOperationalStateReply{
+		Kv: []*pb.KeyValue{
+			newKeyValue("interfaces", uint64(len(s.device.interfaces))),
+			newKeyValue("neighbors", uint64(len(s.device.neighbors))),
+			newKeyValue("subscriptions", uint64(len(s.active))),
+		},
+	}, nil
+}
+
+// GetDataEncodings returns protobuf, the only supported encoding
+func (s *Server) GetDataEncodings(ctx context.Context, req *pb.DataEncodingRequest) (*pb.DataEncodingReply, error) {
+	return &pb.DataEncodingReply{
+		EncodingList: []pb.EncodingType{pb.EncodingType_PROTO3},
+	}, nil
+}
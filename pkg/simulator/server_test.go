@@ -0,0 +1,91 @@
+package simulator
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	pb "github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/telemetry"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestServer(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := grpc.NewServer()
+	pb.RegisterOpenConfigTelemetryServer(s, NewServer("r1", Options{Interfaces: 4, Neighbors: 2, SampleFrequencyMS: 10}))
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	client := pb.NewOpenConfigTelemetryClient(conn)
+	ctx := context.Background()
+	stream, err := client.TelemetrySubscribe(ctx, &pb.SubscriptionRequest{
+		PathList: []*pb.Path{{Path: "/interfaces/"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two samples of all interfaces
+	for i := 0; i < 8; i++ {
+		data, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.True(t, strings.HasPrefix(data.Kv[0].GetStrValue(), "/interfaces/interface[name='et-0/0/"))
+		assert.Equal(t, 10, len(data.Kv))
+	}
+
+	subs, err := client.GetTelemetrySubscriptions(ctx, &pb.GetSubscriptionsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, len(subs.SubscriptionList))
+	assert.Equal(t, uint32(1), subs.SubscriptionList[0].Response.SubscriptionId)
+	assert.Equal(t, "/interfaces/", subs.SubscriptionList[0].PathList[0].Path)
+
+	reply, err := client.CancelTelemetrySubscription(ctx, &pb.CancelSubscriptionRequest{SubscriptionId: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, pb.ReturnCode_SUCCESS, reply.Code)
+
+	for {
+		_, err := stream.Recv()
+		if err != nil {
+			break
+		}
+	}
+
+	reply, err = client.CancelTelemetrySubscription(ctx, &pb.CancelSubscriptionRequest{SubscriptionId: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, pb.ReturnCode_NO_SUBSCRIPTION_ENTRY, reply.Code)
+
+	stream, err = client.TelemetrySubscribe(ctx, &pb.SubscriptionRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = stream.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,145 @@
+package simulator
+
+import (
+	"time"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/ocpath"
+	pb "github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/telemetry"
+)
+
+// subscription is the state of a single path of a subscription request
+type subscription struct {
+	path     *pb.Path
+	elements []ocpath.Element
+	// invalid is set if the path can not be parsed, it matches nothing
+	invalid  bool
+	matches  map[string]bool
+	sent     map[string]interface{}
+	lastFull time.Time
+	sequence uint64
+}
+
+func newSubscription(path *pb.Path) *subscription {
+	elements, err := ocpath.Parse(path.Path)
+
+	return &subscription{
+		path:     path,
+		elements: elements,
+		invalid:  err != nil,
+		matches:  make(map[string]bool),
+		sent:     make(map[string]interface{}),
+	}
+}
+
+// match returns whether the leaf at path p (with list keys) is subscribed. Elements of the subscribed path without
+// list keys match all list entries, list keys of the subscribed path have to match.
+func (s *subscription) match(p string) bool {
+	m, ok := s.matches[p]
+	if ok {
+		return m
+	}
+
+	m = !s.invalid && matchElements(s.elements, p)
+	s.matches[p] = m
+	return m
+}
+
+func matchElements(sub []ocpath.Element, p string) bool {
+	elements, err := ocpath.Parse(p)
+	if err != nil || len(elements) < len(sub) {
+		return false
+	}
+
+	for i, e := range sub {
+		if e.Name != elements[i].Name {
+			return false
+		}
+
+		for _, k := range e.Keys {
+			if !hasKey(elements[i].Keys, k) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func hasKey(keys []ocpath.Key, k ocpath.Key) bool {
+	for _, x := range keys {
+		if x == k {
+			return true
+		}
+	}
+
+	return false
+}
+
+// messages returns the messages for the subscribed leaves of groups. With suppress_unchanged only changed leaves
+// are sent unless the max silent interval has passed.
+func (s *subscription) messages(systemID string, groups []*leafGroup, now time.Time) []*pb.OpenConfigData {
+	full := !s.path.SuppressUnchanged
+	if s.path.SuppressUnchanged && (s.lastFull.IsZero() ||
+		s.path.MaxSilentInterval > 0 && now.Sub(s.lastFull) >= time.Duration(s.path.MaxSilentInterval)*time.Millisecond) {
+		full = true
+		s.lastFull = now
+	}
+
+	res := make([]*pb.OpenConfigData, 0, len(groups))
+	for _, g := range groups {
+		var kvs []*pb.KeyValue
+		for _, l := range g.leaves {
+			id := g.prefix + l.key
+			if !s.match(id) {
+				continue
+			}
+
+			if !full && s.sent[id] == l.value {
+				continue
+			}
+
+			if s.path.SuppressUnchanged {
+				s.sent[id] = l.value
+			}
+
+			if kvs == nil {
+				kvs = append(kvs, &pb.KeyValue{
+					Key:   "__prefix__",
+					Value: &pb.KeyValue_StrValue{StrValue: g.prefix},
+				})
+			}
+
+			kvs = append(kvs, newKeyValue(l.key, l.value))
+		}
+
+		if kvs == nil {
+			continue
+		}
+
+		s.sequence++
+		res = append(res, &pb.OpenConfigData{
+			SystemId:       systemID,
+			Path:           s.path.Path,
+			SequenceNumber: s.sequence,
+			Timestamp:      uint64(now.UnixNano() / int64(time.Millisecond)),
+			Kv:             kvs,
+		})
+	}
+
+	return res
+}
+
+func newKeyValue(key string, v interface{}) *pb.KeyValue {
+	kv := &pb.KeyValue{
+		Key: key,
+	}
+
+	switch x := v.(type) {
+	case uint64:
+		kv.Value = &pb.KeyValue_UintValue{UintValue: x}
+	case string:
+		kv.Value = &pb.KeyValue_StrValue{StrValue: x}
+	}
+
+	return kv
+}
@@ -0,0 +1,143 @@
+package simulator
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/telemetry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriptionMatch(t *testing.T) {
+	tests := []struct {
+		path     string
+		leaf     string
+		expected bool
+	}{
+		{
+			path:     "/interfaces/",
+			leaf:     "/interfaces/interface[name='et-0/0/0']/state/mtu",
+			expected: true,
+		},
+		{
+			path:     "interfaces/interface/state/counters/",
+			leaf:     "/interfaces/interface[name='et-0/0/0']/state/counters/in-octets",
+			expected: true,
+		},
+		{
+			path:     "/interfaces/interface[name='et-0/0/1']/",
+			leaf:     "/interfaces/interface[name='et-0/0/1']/state/mtu",
+			expected: true,
+		},
+		{
+			path:     "/interfaces/interface[name='et-0/0/1']/",
+			leaf:     "/interfaces/interface[name='et-0/0/0']/state/mtu",
+			expected: false,
+		},
+		{
+			path:     "/network-instances/network-instance/protocols/protocol[name='bgp']/bgp/",
+			leaf:     "/network-instances/network-instance[name='master']/protocols/protocol[identifier='BGP'][name='bgp']/bgp/neighbors/neighbor[neighbor-address='2001:db8::1']/state/peer-as",
+			expected: true,
+		},
+		{
+			path:     "/interfaces/interface/state/mtu",
+			leaf:     "/interfaces/interface[name='et-0/0/0']/state/mtu-foo",
+			expected: false,
+		},
+		{
+			path:     "/",
+			leaf:     "/interfaces/interface[name='et-0/0/0']/state/mtu",
+			expected: true,
+		},
+		{
+			path:     "/interfaces/interface[name='et-0/0/0'/",
+			leaf:     "/interfaces/interface[name='et-0/0/0']/state/mtu",
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		s := newSubscription(&pb.Path{Path: test.path})
+		assert.Equal(t, test.expected, s.match(test.leaf), "%s %s", test.path, test.leaf)
+	}
+}
+
+// keys returns the keys (without __prefix__) of all messages
+func keys(msgs []*pb.OpenConfigData) []string {
+	res := make([]string, 0)
+	for _, m := range msgs {
+		for _, kv := range m.Kv {
+			if kv.Key != "__prefix__" {
+				res = append(res, kv.Key)
+			}
+		}
+	}
+
+	return res
+}
+
+func TestSubscriptionMessages(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+
+	tests := []struct {
+		name string
+		path *pb.Path
+		// samples are the times of the samples relative to start
+		samples  []time.Duration
+		expected [][]string
+	}{
+		{
+			name:    "Counters without suppression",
+			path:    &pb.Path{Path: "/interfaces/interface/state/counters/in-octets"},
+			samples: []time.Duration{0, time.Second},
+			expected: [][]string{
+				{"state/counters/in-octets", "state/counters/in-octets"},
+				{"state/counters/in-octets", "state/counters/in-octets"},
+			},
+		},
+		{
+			name:    "Suppress unchanged",
+			path:    &pb.Path{Path: "/interfaces/interface[name='et-0/0/0']/state/", SuppressUnchanged: true, MaxSilentInterval: 10000},
+			samples: []time.Duration{0, time.Second, 10 * time.Second},
+			expected: [][]string{
+				{
+					"state/description", "state/mtu", "state/admin-status", "state/oper-status", "state/counters/in-octets",
+					"state/counters/out-octets", "state/counters/in-pkts", "state/counters/out-pkts", "state/counters/in-errors",
+				},
+				{"state/counters/in-octets", "state/counters/out-octets", "state/counters/in-pkts", "state/counters/out-pkts"},
+				{
+					"state/description", "state/mtu", "state/admin-status", "state/oper-status", "state/counters/in-octets",
+					"state/counters/out-octets", "state/counters/in-pkts", "state/counters/out-pkts", "state/counters/in-errors",
+				},
+			},
+		},
+		{
+			name:    "BGP",
+			path:    &pb.Path{Path: "/network-instances/network-instance/protocols/protocol/bgp/neighbors/neighbor/afi-safis/"},
+			samples: []time.Duration{0},
+			expected: [][]string{
+				{
+					"afi-safis/afi-safi[afi-safi-name='IPV4_UNICAST']/state/prefixes/received",
+					"afi-safis/afi-safi[afi-safi-name='IPV4_UNICAST']/state/prefixes/sent",
+					"afi-safis/afi-safi[afi-safi-name='IPV4_UNICAST']/state/prefixes/installed",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		d := NewDevice("r1", Options{Interfaces: 2, Neighbors: 1, Seed: 1})
+		sub := newSubscription(test.path)
+
+		for i, offset := range test.samples {
+			now := start.Add(offset)
+			msgs := sub.messages("r1", d.sample(now), now)
+			assert.Equal(t, test.expected[i], keys(msgs), "%s: sample %d", test.name, i)
+
+			for _, m := range msgs {
+				assert.Equal(t, "r1", m.SystemId, test.name)
+				assert.Equal(t, uint64(now.UnixNano()/int64(time.Millisecond)), m.Timestamp, test.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/replay"
+	pb "github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/telemetry"
+)
+
+// runReplay serves recordings to the exporter like a device
+func runReplay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	listenAddress := fs.String("listen-address", ":50051", "Address to accept subscriptions on")
+	speedup := fs.Float64("speedup", 1, "Replay speed factor, 0 sends all messages without delay")
+	loop := fs.Bool("loop", false, "Restart the replay at the end of the recording")
+	rewriteTimestamps := fs.Bool("rewrite-timestamps", false, "Replace device timestamps by the time messages are sent")
+	fs.Usage = func() {
+		fmt.Println("Usage: openconfig-streaming-telemetry-exporter replay [ ... ] recording...\n\nParameters:")
+		fmt.Println()
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	srv := replay.New(fs.Args(), replay.Options{
+		Speedup:           *speedup,
+		Loop:              *loop,
+		RewriteTimestamps: *rewriteTimestamps,
+	})
+
+	err := srv.Validate()
+	if err != nil {
+		log.Fatalf("could not open recording. %v", err)
+	}
+
+	lis, err := net.Listen("tcp", *listenAddress)
+	if err != nil {
+		log.Fatalf("could not listen. %v", err)
+	}
+
+	s := grpc.NewServer()
+	pb.RegisterOpenConfigTelemetryServer(s, srv)
+
+	log.Infof("Replaying %d recordings on %s", fs.NArg(), *listenAddress)
+	log.Fatal(s.Serve(lis))
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+
+	"github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/simulator"
+	pb "github.com/exaring/openconfig-streaming-telemetry-exporter/pkg/telemetry"
+)
+
+// runSimulate serves simulated devices on consecutive ports
+func runSimulate(args []string) {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	listenHost := fs.String("listen-host", "127.0.0.1", "Address the devices listen on")
+	basePort := fs.Int("base-port", 50051, "Port of the first device, further devices use the following ports")
+	devices := fs.Int("devices", 1, "Number of simulated devices")
+	interfaces := fs.Int("interfaces", 48, "Number of interfaces per device")
+	neighbors := fs.Int("neighbors", 16, "Number of BGP neighbors per device")
+	sampleFrequencyMS := fs.Uint("sample-frequency-ms", 1000, "Sample frequency of paths requested without sample frequency")
+	fs.Usage = func() {
+		fmt.Println("Usage: openconfig-streaming-telemetry-exporter simulate [ ... ]\n\nParameters:")
+		fmt.Println()
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+	if fs.NArg() != 0 || *devices < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	errCh := make(chan error)
+	for i := 0; i < *devices; i++ {
+		address := net.JoinHostPort(*listenHost, fmt.Sprint(*basePort+i))
+		lis, err := net.Listen("tcp", address)
+		if err != nil {
+			log.Fatalf("could not listen. %v", err)
+		}
+
+		s := grpc.NewServer()
+		pb.RegisterOpenConfigTelemetryServer(s, simulator.NewServer(fmt.Sprintf("sim%d", i), simulator.Options{
+			Interfaces:        *interfaces,
+			Neighbors:         *neighbors,
+			SampleFrequencyMS: uint32(*sampleFrequencyMS),
+			Seed:              int64(i),
+		}))
+
+		go func() {
+			errCh <- s.Serve(lis)
+		}()
+	}
+
+	log.Infof("Simulating %d devices with %d interfaces and %d BGP neighbors on %s ports %d-%d",
+		*devices, *interfaces, *neighbors, *listenHost, *basePort, *basePort+*devices-1)
+	log.Fatal(<-errCh)
+}